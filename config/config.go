@@ -8,7 +8,10 @@ import (
 // Settings contains values loaded from a config file
 type Settings struct {
 	Service struct {
-		GracefulShutdownDelaySeconds string `json:"gracefulShutdownDelaySeconds" yaml:"gracefulShutdownDelaySeconds" mapstructure:"gracefulShutdownDelaySeconds"`
+		GracefulShutdownDelaySeconds string `json:"gracefulShutdownDelaySeconds" yaml:"gracefulShutdownDelaySeconds" mapstructure:"gracefulShutdownDelaySeconds"` // draining delay before the listeners close, readiness is already failing
+		DrainTimeout                 string `json:"drainTimeout" yaml:"drainTimeout" mapstructure:"drainTimeout"`                                                 // deadline for in flight requests once the listeners close, 5s when empty
+		UnknownHostStatus            int    `json:"unknownHostStatus" yaml:"unknownHostStatus" mapstructure:"unknownHostStatus"`                                  // status for hosts not matching a virtual host, 421 (default) or 404
+		ConfigWatchInterval          string `json:"configWatchInterval" yaml:"configWatchInterval" mapstructure:"configWatchInterval"`                            // how often the config file is checked for changes, 5s when empty, 0s disables
 		HTTP                         struct {
 			Server struct {
 				IPv4Address string `json:"ipv4address" yaml:"ipv4address" mapstructure:"ipv4address"`
@@ -23,12 +26,256 @@ type Settings struct {
 				Port        uint16 `json:"port" yaml:"port" mapstructure:"port"`
 			} `json:"server" yaml:"server" mapstructure:"server"`
 		} `json:"http" yaml:"http" mapstructure:"http"`
-		HealthcheckEnabled bool `json:"healthcheckEnabled" yaml:"healthcheckEnabled" mapstructure:"healthcheckEnabled"`
-		PPRofEnabled       bool `json:"pprofEnabled" yaml:"pprofEnabled" mapstructure:"pprofEnabled"`
+		HealthcheckEnabled bool     `json:"healthcheckEnabled" yaml:"healthcheckEnabled" mapstructure:"healthcheckEnabled"`
+		PPRofEnabled       bool     `json:"pprofEnabled" yaml:"pprofEnabled" mapstructure:"pprofEnabled"`
+		PrometheusEnabled  bool     `json:"prometheusEnabled" yaml:"prometheusEnabled" mapstructure:"prometheusEnabled"` // /metrics in the Prometheus text format
+		RouteMaxSeries     int      `json:"routeMaxSeries" yaml:"routeMaxSeries" mapstructure:"routeMaxSeries"`          // route timers before new routes are counted as other, 100 when zero, negative disables
+		PProf              PProf    `json:"pprof" yaml:"pprof" mapstructure:"pprof"`
+		StatsD             StatsD   `json:"statsd" yaml:"statsd" mapstructure:"statsd"`
+		Graphite           Graphite `json:"graphite" yaml:"graphite" mapstructure:"graphite"`
 	} `json:"metrics" yaml:"metrics" mapstructure:"metrics"`
 	Logging struct {
 		Level string `json:"level" yaml:"level" mapstructure:"level"`
 	} `json:"logging" yaml:"logging" mapstructure:"logging"`
+	Upstreams    []UpstreamPool       `json:"upstreams" yaml:"upstreams" mapstructure:"upstreams"`
+	Routes       []Route              `json:"routes" yaml:"routes" mapstructure:"routes"`
+	Outbound     Outbound             `json:"outbound" yaml:"outbound" mapstructure:"outbound"`
+	Mirror       Mirror               `json:"mirror" yaml:"mirror" mapstructure:"mirror"`
+	VirtualHosts []VirtualHost        `json:"virtualHosts" yaml:"virtualHosts" mapstructure:"virtualHosts"`
+	JWT          JWT                  `json:"jwt" yaml:"jwt" mapstructure:"jwt"`
+	CORS         *CORS                `json:"cors" yaml:"cors" mapstructure:"cors"` // default policy, virtual hosts and routes can override
+	Compression  Compression          `json:"compression" yaml:"compression" mapstructure:"compression"`
+	Static       []Static             `json:"static" yaml:"static" mapstructure:"static"`
+	Templates    Templates            `json:"templates" yaml:"templates" mapstructure:"templates"`
+	ErrorPages   map[string]ErrorPage `json:"errorPages" yaml:"errorPages" mapstructure:"errorPages"` // keyed by status ("404") or class ("5xx"), statuses win over classes
+	Admin        Admin                `json:"admin" yaml:"admin" mapstructure:"admin"`
+	Health       Health               `json:"health" yaml:"health" mapstructure:"health"`
+	Profiler     Profiler             `json:"profiler" yaml:"profiler" mapstructure:"profiler"`
+}
+
+// Health contains dependency checks, failing critical checks fail readiness
+type Health struct {
+	Checks             []HealthCheck `json:"checks" yaml:"checks" mapstructure:"checks"`
+	HealthyThreshold   int           `json:"healthyThreshold" yaml:"healthyThreshold" mapstructure:"healthyThreshold"`       // consecutive passes before a failing check passes, 2 when zero
+	UnhealthyThreshold int           `json:"unhealthyThreshold" yaml:"unhealthyThreshold" mapstructure:"unhealthyThreshold"` // consecutive failures before a passing check fails, 3 when zero
+	LivenessPath       string        `json:"livenessPath" yaml:"livenessPath" mapstructure:"livenessPath"`                   // /healthz/livenessZ76 when empty
+	ReadinessPath      string        `json:"readinessPath" yaml:"readinessPath" mapstructure:"readinessPath"`                // /healthz/readinessZ67 when empty
+	StartupPath        string        `json:"startupPath" yaml:"startupPath" mapstructure:"startupPath"`                      // /healthz/startup when empty
+	DetailedReports    bool          `json:"detailedReports" yaml:"detailedReports" mapstructure:"detailedReports"`          // service port reports list the checks, only the status when false
+	Watchdog           Watchdog      `json:"watchdog" yaml:"watchdog" mapstructure:"watchdog"`
+}
+
+// Watchdog fails liveness while the process looks wedged, triggers are disabled when zero or empty
+type Watchdog struct {
+	Interval           string `json:"interval" yaml:"interval" mapstructure:"interval"`                               // how often triggers are evaluated, 5s when empty
+	MaxGoroutines      int    `json:"maxGoroutines" yaml:"maxGoroutines" mapstructure:"maxGoroutines"`                // goroutine count threshold
+	HeartbeatInterval  string `json:"heartbeatInterval" yaml:"heartbeatInterval" mapstructure:"heartbeatInterval"`    // heartbeat goroutine tick, 1s when empty
+	MaxHeartbeatDelay  string `json:"maxHeartbeatDelay" yaml:"maxHeartbeatDelay" mapstructure:"maxHeartbeatDelay"`    // scheduler starvation when the heartbeat is this late
+	MaxGCPauseP99      string `json:"maxGCPauseP99" yaml:"maxGCPauseP99" mapstructure:"maxGCPauseP99"`                // p99 of recent GC pauses
+	HeapSoftLimitBytes uint64 `json:"heapSoftLimitBytes" yaml:"heapSoftLimitBytes" mapstructure:"heapSoftLimitBytes"` // allocated heap threshold
+	ProfileDirectory   string `json:"profileDirectory" yaml:"profileDirectory" mapstructure:"profileDirectory"`       // goroutine and heap profiles are written here when a trigger fires
+}
+
+// HealthCheck is a named dependency check run in the background
+type HealthCheck struct {
+	Name         string `json:"name" yaml:"name" mapstructure:"name"`
+	Type         string `json:"type" yaml:"type" mapstructure:"type"`                         // tcp, http or disk
+	Target       string `json:"target" yaml:"target" mapstructure:"target"`                   // host:port (tcp), URL (http) or path (disk)
+	MinFreeBytes uint64 `json:"minFreeBytes" yaml:"minFreeBytes" mapstructure:"minFreeBytes"` // disk checks fail below this
+	Timeout      string `json:"timeout" yaml:"timeout" mapstructure:"timeout"`                // 2s when empty
+	Interval     string `json:"interval" yaml:"interval" mapstructure:"interval"`             // 10s when empty
+	Critical     bool   `json:"critical" yaml:"critical" mapstructure:"critical"`             // failing critical checks fail readiness, others only warn
+}
+
+// Admin contains settings for the runtime admin API on the metrics port, the API is disabled without a token
+type Admin struct {
+	Token     string `json:"token" yaml:"token" mapstructure:"token"`             // bearer token for admin requests
+	TokenFile string `json:"tokenFile" yaml:"tokenFile" mapstructure:"tokenFile"` // file holding the bearer token, wins over Token
+}
+
+// PProf contains settings for the /debugz tree on the metrics port, only loopback clients are allowed without a token or an allowlist
+type PProf struct {
+	Token                string   `json:"token" yaml:"token" mapstructure:"token"`                                              // bearer token for /debugz requests
+	TokenFile            string   `json:"tokenFile" yaml:"tokenFile" mapstructure:"tokenFile"`                                  // file holding the bearer token, wins over Token
+	AllowedIPs           []string `json:"allowedIPs" yaml:"allowedIPs" mapstructure:"allowedIPs"`                               // client addresses or CIDRs, both must pass when a token is also set
+	BlockProfileRate     int      `json:"blockProfileRate" yaml:"blockProfileRate" mapstructure:"blockProfileRate"`             // see runtime.SetBlockProfileRate, 0 leaves block profiling off
+	MutexProfileFraction int      `json:"mutexProfileFraction" yaml:"mutexProfileFraction" mapstructure:"mutexProfileFraction"` // see runtime.SetMutexProfileFraction, 0 leaves mutex profiling off
+}
+
+// StatsD pushes the metrics registry to a StatsD or DogStatsD agent over UDP, it is disabled without an address
+type StatsD struct {
+	Address       string    `json:"address" yaml:"address" mapstructure:"address"`                   // agent host:port
+	FlushInterval string    `json:"flushInterval" yaml:"flushInterval" mapstructure:"flushInterval"` // 10s when empty
+	Prefix        string    `json:"prefix" yaml:"prefix" mapstructure:"prefix"`                      // added to every metric name
+	Tags          []string  `json:"tags" yaml:"tags" mapstructure:"tags"`                            // DogStatsD tags, e.g. "env:prod"
+	Percentiles   []float64 `json:"percentiles" yaml:"percentiles" mapstructure:"percentiles"`       // timer percentiles, 0.5, 0.75, 0.95, 0.99 and 0.999 when empty
+}
+
+// Graphite pushes the metrics registry to Graphite in the plaintext protocol over TCP, it is disabled without an address
+type Graphite struct {
+	Address       string    `json:"address" yaml:"address" mapstructure:"address"`                   // carbon host:port
+	FlushInterval string    `json:"flushInterval" yaml:"flushInterval" mapstructure:"flushInterval"` // 10s when empty
+	Prefix        string    `json:"prefix" yaml:"prefix" mapstructure:"prefix"`                      // added to every metric name
+	Percentiles   []float64 `json:"percentiles" yaml:"percentiles" mapstructure:"percentiles"`       // timer percentiles, 0.5, 0.75, 0.95, 0.99 and 0.999 when empty
+}
+
+// Profiler captures profiles to Directory periodically and when a trigger fires, it is disabled without a directory.
+// Triggers are disabled when zero or empty.
+type Profiler struct {
+	Directory       string   `json:"directory" yaml:"directory" mapstructure:"directory"`                   // profiles are written here
+	Interval        string   `json:"interval" yaml:"interval" mapstructure:"interval"`                      // periodic captures, off when empty
+	Profiles        []string `json:"profiles" yaml:"profiles" mapstructure:"profiles"`                      // cpu, heap, goroutine and mutex when empty
+	CPUDuration     string   `json:"cpuDuration" yaml:"cpuDuration" mapstructure:"cpuDuration"`             // length of cpu profiles, 10s when empty
+	MaxFiles        int      `json:"maxFiles" yaml:"maxFiles" mapstructure:"maxFiles"`                      // oldest profiles removed beyond this, 100 when zero
+	MaxBytes        int64    `json:"maxBytes" yaml:"maxBytes" mapstructure:"maxBytes"`                      // oldest profiles removed beyond this total size, no limit when zero
+	MaxAge          string   `json:"maxAge" yaml:"maxAge" mapstructure:"maxAge"`                            // profiles older than this are removed, 24h when empty
+	TriggerInterval string   `json:"triggerInterval" yaml:"triggerInterval" mapstructure:"triggerInterval"` // how often triggers are evaluated, 10s when empty
+	Cooldown        string   `json:"cooldown" yaml:"cooldown" mapstructure:"cooldown"`                      // minimum time between triggered captures, 5m when empty
+	CPUPercent      float64  `json:"cpuPercent" yaml:"cpuPercent" mapstructure:"cpuPercent"`                // host cpu usage threshold
+	RSSBytes        uint64   `json:"rssBytes" yaml:"rssBytes" mapstructure:"rssBytes"`                      // process resident set size threshold
+	LatencyP99      string   `json:"latencyP99" yaml:"latencyP99" mapstructure:"latencyP99"`                // p99 of the service request timer
+}
+
+// ErrorPage replaces the body of matching error responses with a template or a static body
+type ErrorPage struct {
+	Template    string            `json:"template" yaml:"template" mapstructure:"template"`          // template name in Templates.Directory or a template file
+	Body        string            `json:"body" yaml:"body" mapstructure:"body"`                      // static body, used when Template is empty
+	BodyFile    string            `json:"bodyFile" yaml:"bodyFile" mapstructure:"bodyFile"`          // read at startup, takes precedence over Body
+	ContentType string            `json:"contentType" yaml:"contentType" mapstructure:"contentType"` // text/html when empty
+	Headers     map[string]string `json:"headers" yaml:"headers" mapstructure:"headers"`
+}
+
+// Templates contains settings for html/template response pages loaded from a directory.
+// Legacy {{page_title}} and {{page_body}} placeholders are accepted and mapped to {{.Title}} and {{.Body}}.
+type Templates struct {
+	Directory      string `json:"directory" yaml:"directory" mapstructure:"directory"`                // *.html and *.tmpl files, the built in template is used when empty
+	Default        string `json:"default" yaml:"default" mapstructure:"default"`                      // template for server responses, default.html when empty
+	ReloadInterval string `json:"reloadInterval" yaml:"reloadInterval" mapstructure:"reloadInterval"` // directory poll interval for hot reload, disabled when empty
+}
+
+// Static serves files from Directory for requests whose path starts with PathPrefix
+type Static struct {
+	PathPrefix   string            `json:"pathPrefix" yaml:"pathPrefix" mapstructure:"pathPrefix"`
+	Directory    string            `json:"directory" yaml:"directory" mapstructure:"directory"`
+	Index        string            `json:"index" yaml:"index" mapstructure:"index"`       // index.html when empty
+	Listing      bool              `json:"listing" yaml:"listing" mapstructure:"listing"` // list directories without an index file
+	SPA          bool              `json:"spa" yaml:"spa" mapstructure:"spa"`             // missing paths without a file extension serve the root index
+	CacheControl []StaticCacheRule `json:"cacheControl" yaml:"cacheControl" mapstructure:"cacheControl"`
+}
+
+// StaticCacheRule sets Cache-Control for files matching Pattern, a path.Match glob against the file's path or name, first match wins
+type StaticCacheRule struct {
+	Pattern string `json:"pattern" yaml:"pattern" mapstructure:"pattern"`
+	Value   string `json:"value" yaml:"value" mapstructure:"value"`
+}
+
+// Compression contains settings for Accept-Encoding negotiated response compression
+type Compression struct {
+	Enabled      bool     `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	MinSize      int      `json:"minSize" yaml:"minSize" mapstructure:"minSize"`                // smaller bodies are not compressed, zero uses the default
+	ContentTypes []string `json:"contentTypes" yaml:"contentTypes" mapstructure:"contentTypes"` // media types to compress, "text/*" style wildcards allowed
+	Encodings    []string `json:"encodings" yaml:"encodings" mapstructure:"encodings"`          // br, gzip, deflate, server preference order when the client has no preference
+	Level        int      `json:"level" yaml:"level" mapstructure:"level"`                      // 1 - 9, zero uses the default
+}
+
+// CORS is a cross origin resource sharing policy.
+// Origins are exact ("https://app.example.test"), wildcard ("https://*.example.test", "*") or regex when starting with "^".
+type CORS struct {
+	AllowedOrigins   []string `json:"allowedOrigins" yaml:"allowedOrigins" mapstructure:"allowedOrigins"`
+	AllowedMethods   []string `json:"allowedMethods" yaml:"allowedMethods" mapstructure:"allowedMethods"` // GET, HEAD, POST when empty
+	AllowedHeaders   []string `json:"allowedHeaders" yaml:"allowedHeaders" mapstructure:"allowedHeaders"` // "*" allows any requested header
+	ExposedHeaders   []string `json:"exposedHeaders" yaml:"exposedHeaders" mapstructure:"exposedHeaders"`
+	AllowCredentials bool     `json:"allowCredentials" yaml:"allowCredentials" mapstructure:"allowCredentials"`
+	MaxAge           int      `json:"maxAge" yaml:"maxAge" mapstructure:"maxAge"` // preflight cache seconds, not sent when zero
+}
+
+// JWT contains settings for bearer token validation, validation is disabled when no key source is set
+type JWT struct {
+	JWKSFile     string   `json:"jwksFile" yaml:"jwksFile" mapstructure:"jwksFile"`             // local JWKS file (RSA, EC P-256 and oct keys)
+	PEMDirectory string   `json:"pemDirectory" yaml:"pemDirectory" mapstructure:"pemDirectory"` // *.pem public keys/certificates and *.secret HMAC keys, file name is the kid
+	Issuer       string   `json:"issuer" yaml:"issuer" mapstructure:"issuer"`                   // iss must match when set
+	Audience     []string `json:"audience" yaml:"audience" mapstructure:"audience"`             // aud must contain one of these when set
+	ClockSkew    string   `json:"clockSkew" yaml:"clockSkew" mapstructure:"clockSkew"`          // leeway for exp and nbf
+	Algorithms   []string `json:"algorithms" yaml:"algorithms" mapstructure:"algorithms"`       // RS256, ES256, HS256 (default all)
+}
+
+// VirtualHost gives requests for matching hosts their own routes, response template and headers.
+// Host patterns are exact ("api.example.test"), wildcard ("*.example.test", any subdomain) or default ("*").
+type VirtualHost struct {
+	Hosts    []string          `json:"hosts" yaml:"hosts" mapstructure:"hosts"`
+	Routes   []Route           `json:"routes" yaml:"routes" mapstructure:"routes"`
+	Template string            `json:"template" yaml:"template" mapstructure:"template"` // template name in Templates.Directory or a template file, server default when empty
+	Headers  map[string]string `json:"headers" yaml:"headers" mapstructure:"headers"`
+	CORS     *CORS             `json:"cors" yaml:"cors" mapstructure:"cors"`
+}
+
+// Mirror contains settings for copying incoming requests to a shadow target, the shadow response is discarded
+type Mirror struct {
+	URL                string  `json:"url" yaml:"url" mapstructure:"url"`                      // mirroring is disabled when empty
+	Percentage         float64 `json:"percentage" yaml:"percentage" mapstructure:"percentage"` // 0 - 100
+	Timeout            string  `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+	MaxBodyBytes       int64   `json:"maxBodyBytes" yaml:"maxBodyBytes" mapstructure:"maxBodyBytes"`                   // larger requests are not mirrored
+	MaxConcurrent      int     `json:"maxConcurrent" yaml:"maxConcurrent" mapstructure:"maxConcurrent"`                // in flight shadow requests, extra requests are dropped
+	ForwardCredentials bool    `json:"forwardCredentials" yaml:"forwardCredentials" mapstructure:"forwardCredentials"` // copy authorization, cookie and other secret headers to the shadow target
+}
+
+// Outbound contains settings for the outbound HTTP client used for forwarding and metadata calls
+type Outbound struct {
+	Timeout     string `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+	MaxRetries  int    `json:"maxRetries" yaml:"maxRetries" mapstructure:"maxRetries"` // zero uses the default, negative disables retries
+	BaseBackoff string `json:"baseBackoff" yaml:"baseBackoff" mapstructure:"baseBackoff"`
+	MaxBackoff  string `json:"maxBackoff" yaml:"maxBackoff" mapstructure:"maxBackoff"`
+	RetryBudget struct {
+		Percent    int    `json:"percent" yaml:"percent" mapstructure:"percent"`          // retries allowed as a percent of requests in the window
+		MinRetries int    `json:"minRetries" yaml:"minRetries" mapstructure:"minRetries"` // retries always allowed in the window
+		Window     string `json:"window" yaml:"window" mapstructure:"window"`
+	} `json:"retryBudget" yaml:"retryBudget" mapstructure:"retryBudget"`
+	CircuitBreaker struct {
+		FailureThreshold    int    `json:"failureThreshold" yaml:"failureThreshold" mapstructure:"failureThreshold"` // zero uses the default, negative disables breakers
+		OpenDuration        string `json:"openDuration" yaml:"openDuration" mapstructure:"openDuration"`
+		HalfOpenMaxRequests int    `json:"halfOpenMaxRequests" yaml:"halfOpenMaxRequests" mapstructure:"halfOpenMaxRequests"`
+	} `json:"circuitBreaker" yaml:"circuitBreaker" mapstructure:"circuitBreaker"`
+}
+
+// UpstreamPool is a named group of targets that requests can be forwarded to
+type UpstreamPool struct {
+	Name        string   `json:"name" yaml:"name" mapstructure:"name"`
+	Targets     []string `json:"targets" yaml:"targets" mapstructure:"targets"`
+	Strategy    string   `json:"strategy" yaml:"strategy" mapstructure:"strategy"`       // roundRobin (default), leastConnections, consistentHash
+	HashHeader  string   `json:"hashHeader" yaml:"hashHeader" mapstructure:"hashHeader"` // request header used by consistentHash
+	HealthCheck struct {
+		Path               string `json:"path" yaml:"path" mapstructure:"path"` // active probes are disabled when empty
+		Interval           string `json:"interval" yaml:"interval" mapstructure:"interval"`
+		Timeout            string `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+		ExpectedStatus     int    `json:"expectedStatus" yaml:"expectedStatus" mapstructure:"expectedStatus"`
+		HealthyThreshold   int    `json:"healthyThreshold" yaml:"healthyThreshold" mapstructure:"healthyThreshold"`
+		UnhealthyThreshold int    `json:"unhealthyThreshold" yaml:"unhealthyThreshold" mapstructure:"unhealthyThreshold"`
+	} `json:"healthCheck" yaml:"healthCheck" mapstructure:"healthCheck"`
+	OutlierDetection struct {
+		ConsecutiveFailures int    `json:"consecutiveFailures" yaml:"consecutiveFailures" mapstructure:"consecutiveFailures"` // passive ejection is disabled when zero
+		EjectionDuration    string `json:"ejectionDuration" yaml:"ejectionDuration" mapstructure:"ejectionDuration"`
+		MaxEjectionPercent  int    `json:"maxEjectionPercent" yaml:"maxEjectionPercent" mapstructure:"maxEjectionPercent"`
+	} `json:"outlierDetection" yaml:"outlierDetection" mapstructure:"outlierDetection"`
+}
+
+// Route forwards requests whose path starts with PathPrefix to the named upstream pool, or serves a canned Response.
+// Requests are handled locally when neither Upstream nor Response is set.
+type Route struct {
+	PathPrefix  string          `json:"pathPrefix" yaml:"pathPrefix" mapstructure:"pathPrefix"`
+	Upstream    string          `json:"upstream" yaml:"upstream" mapstructure:"upstream"`
+	StripPrefix bool            `json:"stripPrefix" yaml:"stripPrefix" mapstructure:"stripPrefix"`
+	CORS        *CORS           `json:"cors" yaml:"cors" mapstructure:"cors"`
+	Response    *CannedResponse `json:"response" yaml:"response" mapstructure:"response"` // takes precedence over Upstream
+}
+
+// CannedResponse is a fixed response, 200 responses support conditional and range requests
+type CannedResponse struct {
+	Status       int               `json:"status" yaml:"status" mapstructure:"status"` // 200 when zero
+	ContentType  string            `json:"contentType" yaml:"contentType" mapstructure:"contentType"`
+	Body         string            `json:"body" yaml:"body" mapstructure:"body"`
+	BodyFile     string            `json:"bodyFile" yaml:"bodyFile" mapstructure:"bodyFile"` // read at startup, takes precedence over Body
+	Headers      map[string]string `json:"headers" yaml:"headers" mapstructure:"headers"`
+	LastModified string            `json:"lastModified" yaml:"lastModified" mapstructure:"lastModified"` // RFC 3339, server start time when empty
 }
 
 // LoadSettings loads the Settings from JSON file.
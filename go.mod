@@ -3,6 +3,7 @@ module github.com/mdonahue-godaddy/go-http-server
 go 1.19
 
 require (
+	github.com/andybalholm/brotli v1.1.0
 	github.com/asaskevich/govalidator v0.0.0-20210307081110-f21760c49a8d
 	github.com/brunoscheufler/aws-ecs-metadata-go v0.0.0-20220812150832-b6b31c6eeeaf
 	github.com/golang/mock v1.6.0
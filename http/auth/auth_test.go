@@ -0,0 +1,311 @@
+package auth_test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/auth"
+	"github.com/mdonahue-godaddy/go-http-server/log"
+)
+
+var (
+	testRSAKey, _   = rsa.GenerateKey(rand.Reader, 2048)
+	testECKey, _    = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testHMACSecret  = []byte("unit-test-secret")
+	testWrongRSA, _ = rsa.GenerateKey(rand.Reader, 2048)
+)
+
+func encodeSegment(value interface{}) string {
+	content, _ := json.Marshal(value)
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+// signToken - compact JWT signed with key (*rsa.PrivateKey, *ecdsa.PrivateKey or []byte)
+func signToken(alg string, kid string, key interface{}, claims map[string]interface{}) string {
+	header := map[string]interface{}{"alg": alg, "typ": "JWT"}
+	if len(kid) > 0 {
+		header["kid"] = kid
+	}
+
+	signed := encodeSegment(header) + "." + encodeSegment(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch typed := key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, typed, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, _ := ecdsa.Sign(rand.Reader, typed, digest[:])
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case []byte:
+		mac := hmac.New(sha256.New, typed)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func createTestLogger() *log.Logger {
+	logger := log.NewLogger()
+	logger.Logger = logger.Logger.Output(&bytes.Buffer{})
+	return &logger
+}
+
+func createTestVerifier(t *testing.T, cfg config.JWT) *auth.Verifier {
+	keys := auth.NewKeySet()
+	assert.Nil(t, keys.Add("rsa", &testRSAKey.PublicKey))
+	assert.Nil(t, keys.Add("ec", &testECKey.PublicKey))
+	assert.Nil(t, keys.Add("hmac", testHMACSecret))
+
+	verifier, err := auth.NewVerifierWithKeys(keys, cfg, createTestLogger())
+	assert.Nil(t, err)
+
+	return verifier
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub": "unit-test",
+		"iss": "https://issuer.test",
+		"aud": []string{"other", "go-http-server"},
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"nbf": time.Now().Add(-time.Minute).Unix(),
+	}
+}
+
+func withClaim(key string, value interface{}) map[string]interface{} {
+	claims := validClaims()
+	if value == nil {
+		delete(claims, key)
+	} else {
+		claims[key] = value
+	}
+	return claims
+}
+
+func Test_Verify(t *testing.T) {
+	assert := assert.New(t)
+
+	verifier := createTestVerifier(t, config.JWT{
+		Issuer:    "https://issuer.test",
+		Audience:  []string{"go-http-server"},
+		ClockSkew: "30s",
+	})
+
+	testCases := []struct {
+		Token       string
+		Expected    error
+		Description string
+	}{
+		{
+			Token:       signToken(auth.AlgorithmRS256, "rsa", testRSAKey, validClaims()),
+			Expected:    nil,
+			Description: "RS256",
+		},
+		{
+			Token:       signToken(auth.AlgorithmES256, "ec", testECKey, validClaims()),
+			Expected:    nil,
+			Description: "ES256",
+		},
+		{
+			Token:       signToken(auth.AlgorithmHS256, "hmac", testHMACSecret, validClaims()),
+			Expected:    nil,
+			Description: "HS256",
+		},
+		{
+			Token:       signToken(auth.AlgorithmRS256, "", testRSAKey, validClaims()),
+			Expected:    nil,
+			Description: "no kid tries all keys for the algorithm",
+		},
+		{
+			Token:       signToken(auth.AlgorithmRS256, "rsa", testWrongRSA, validClaims()),
+			Expected:    auth.ErrInvalidSignature,
+			Description: "wrong key",
+		},
+		{
+			Token:       signToken(auth.AlgorithmRS256, "missing", testRSAKey, validClaims()),
+			Expected:    auth.ErrUnknownKey,
+			Description: "unknown kid",
+		},
+		{
+			Token:       signToken(auth.AlgorithmHS256, "rsa", testHMACSecret, validClaims()),
+			Expected:    auth.ErrUnknownKey,
+			Description: "kid key does not match algorithm",
+		},
+		{
+			Token:       signToken("none", "", nil, validClaims()),
+			Expected:    auth.ErrUnsupportedAlgorithm,
+			Description: "alg none",
+		},
+		{
+			Token:       "abc.def",
+			Expected:    auth.ErrMalformedToken,
+			Description: "malformed",
+		},
+		{
+			Token:       signToken(auth.AlgorithmHS256, "hmac", testHMACSecret, withClaim("exp", time.Now().Add(-time.Minute).Unix())),
+			Expected:    auth.ErrExpired,
+			Description: "expired beyond skew",
+		},
+		{
+			Token:       signToken(auth.AlgorithmHS256, "hmac", testHMACSecret, withClaim("exp", time.Now().Add(-10*time.Second).Unix())),
+			Expected:    nil,
+			Description: "expired within skew",
+		},
+		{
+			Token:       signToken(auth.AlgorithmHS256, "hmac", testHMACSecret, withClaim("nbf", time.Now().Add(time.Minute).Unix())),
+			Expected:    auth.ErrNotYetValid,
+			Description: "not yet valid",
+		},
+		{
+			Token:       signToken(auth.AlgorithmHS256, "hmac", testHMACSecret, withClaim("iss", "https://other.test")),
+			Expected:    auth.ErrInvalidIssuer,
+			Description: "wrong issuer",
+		},
+		{
+			Token:       signToken(auth.AlgorithmHS256, "hmac", testHMACSecret, withClaim("aud", "other")),
+			Expected:    auth.ErrInvalidAudience,
+			Description: "wrong audience",
+		},
+		{
+			Token:       signToken(auth.AlgorithmHS256, "hmac", testHMACSecret, withClaim("aud", "go-http-server")),
+			Expected:    nil,
+			Description: "string audience",
+		},
+		{
+			Token:       signToken(auth.AlgorithmHS256, "hmac", testHMACSecret, withClaim("aud", nil)),
+			Expected:    auth.ErrInvalidAudience,
+			Description: "missing audience",
+		},
+	}
+
+	for _, tc := range testCases {
+		claims, err := verifier.Verify(tc.Token)
+
+		if tc.Expected == nil {
+			assert.Nil(err, tc.Description)
+			assert.Equal("unit-test", claims.Subject(), tc.Description)
+		} else {
+			assert.True(errors.Is(err, tc.Expected), "%s: %v", tc.Description, err)
+			assert.Nil(claims, tc.Description)
+		}
+	}
+}
+
+func Test_AlgorithmAllowList(t *testing.T) {
+	assert := assert.New(t)
+
+	verifier := createTestVerifier(t, config.JWT{Algorithms: []string{auth.AlgorithmRS256}})
+
+	_, err := verifier.Verify(signToken(auth.AlgorithmHS256, "hmac", testHMACSecret, validClaims()))
+	assert.True(errors.Is(err, auth.ErrUnsupportedAlgorithm))
+
+	_, err = auth.NewVerifierWithKeys(auth.NewKeySet(), config.JWT{Algorithms: []string{"none"}}, nil)
+	assert.NotNil(err)
+}
+
+func Test_Authenticate(t *testing.T) {
+	assert := assert.New(t)
+
+	verifier := createTestVerifier(t, config.JWT{})
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err := verifier.Authenticate(request)
+	assert.True(errors.Is(err, auth.ErrMissingToken))
+
+	request.Header.Set("Authorization", "Bearer "+signToken(auth.AlgorithmHS256, "hmac", testHMACSecret, validClaims()))
+	claims, err := verifier.Authenticate(request)
+	assert.Nil(err)
+
+	ctx := auth.ContextWithClaims(request.Context(), claims)
+	fromContext, found := auth.ClaimsFromContext(ctx)
+	assert.True(found)
+	assert.Equal("unit-test", fromContext.Subject())
+
+	_, found = auth.ClaimsFromContext(request.Context())
+	assert.False(found)
+}
+
+func Test_LoadJWKS(t *testing.T) {
+	assert := assert.New(t)
+
+	encode := func(value *big.Int) string { return base64.RawURLEncoding.EncodeToString(value.Bytes()) }
+
+	jwks := map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(testRSAKey.N), "e": encode(big.NewInt(int64(testRSAKey.E)))},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(testECKey.X), "y": encode(testECKey.Y)},
+			{"kty": "oct", "kid": "hmac", "k": base64.RawURLEncoding.EncodeToString(testHMACSecret)},
+			{"kty": "RSA", "kid": "encryption", "use": "enc", "n": "", "e": ""},
+		},
+	}
+
+	content, _ := json.Marshal(jwks)
+	fileName := filepath.Join(t.TempDir(), "jwks.json")
+	assert.Nil(os.WriteFile(fileName, content, 0600))
+
+	verifier, err := auth.NewVerifier(config.JWT{JWKSFile: fileName}, createTestLogger())
+	assert.Nil(err)
+
+	for alg, key := range map[string]interface{}{auth.AlgorithmRS256: testRSAKey, auth.AlgorithmES256: testECKey, auth.AlgorithmHS256: testHMACSecret} {
+		kid := map[string]string{auth.AlgorithmRS256: "rsa", auth.AlgorithmES256: "ec", auth.AlgorithmHS256: "hmac"}[alg]
+		_, err = verifier.Verify(signToken(alg, kid, key, validClaims()))
+		assert.Nil(err, alg)
+	}
+
+	assert.Nil(os.WriteFile(fileName, []byte(`{"keys":[{"kty":"EC","crv":"P-384","x":"AA","y":"AA"}]}`), 0600))
+	_, err = auth.NewVerifier(config.JWT{JWKSFile: fileName}, createTestLogger())
+	assert.NotNil(err, "unsupported curve")
+}
+
+func Test_LoadPEMDirectory(t *testing.T) {
+	assert := assert.New(t)
+
+	directory := t.TempDir()
+
+	rsaDER, _ := x509.MarshalPKIXPublicKey(&testRSAKey.PublicKey)
+	ecDER, _ := x509.MarshalPKIXPublicKey(&testECKey.PublicKey)
+
+	assert.Nil(os.WriteFile(filepath.Join(directory, "rsa.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaDER}), 0600))
+	assert.Nil(os.WriteFile(filepath.Join(directory, "ec.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ecDER}), 0600))
+	assert.Nil(os.WriteFile(filepath.Join(directory, "hmac.secret"), append(testHMACSecret, '\n'), 0600))
+	assert.Nil(os.WriteFile(filepath.Join(directory, "README.txt"), []byte("ignored"), 0600))
+
+	verifier, err := auth.NewVerifier(config.JWT{PEMDirectory: directory}, createTestLogger())
+	assert.Nil(err)
+
+	_, err = verifier.Verify(signToken(auth.AlgorithmRS256, "rsa", testRSAKey, validClaims()))
+	assert.Nil(err, "RS256")
+	_, err = verifier.Verify(signToken(auth.AlgorithmES256, "ec", testECKey, validClaims()))
+	assert.Nil(err, "ES256")
+	_, err = verifier.Verify(signToken(auth.AlgorithmHS256, "hmac", testHMACSecret, validClaims()))
+	assert.Nil(err, "HS256")
+
+	_, err = auth.NewVerifier(config.JWT{PEMDirectory: t.TempDir()}, createTestLogger())
+	assert.True(strings.Contains(err.Error(), "no jwt verification keys"), "empty directory")
+}
@@ -0,0 +1,327 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/log"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmHS256 = "HS256"
+
+	DefaultClockSkew = 60 * time.Second
+
+	bearerPrefix = "bearer "
+)
+
+var (
+	ErrMissingToken         = errors.New("missing bearer token")
+	ErrMalformedToken       = errors.New("malformed token")
+	ErrUnsupportedAlgorithm = errors.New("unsupported algorithm")
+	ErrUnknownKey           = errors.New("no matching key")
+	ErrInvalidSignature     = errors.New("invalid signature")
+	ErrExpired              = errors.New("token expired")
+	ErrNotYetValid          = errors.New("token not yet valid")
+	ErrInvalidIssuer        = errors.New("invalid issuer")
+	ErrInvalidAudience      = errors.New("invalid audience")
+)
+
+// Claims are the verified JWT claims
+type Claims map[string]interface{}
+
+// Subject - sub claim, empty when missing
+func (c Claims) Subject() string {
+	value, _ := c["sub"].(string)
+	return value
+}
+
+type claimsContextKey struct{}
+
+// ContextWithClaims - ctx carrying verified claims
+func ContextWithClaims(ctx context.Context, claims Claims) context.Context {
+	return context.WithValue(ctx, claimsContextKey{}, claims)
+}
+
+// ClaimsFromContext - verified claims in ctx, false when the request was not authenticated
+func ClaimsFromContext(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(Claims)
+	return claims, ok
+}
+
+// Verifier validates JWT bearer tokens against a local key set
+type Verifier struct {
+	keys       *KeySet
+	issuer     string
+	audience   []string
+	clockSkew  time.Duration
+	algorithms map[string]bool
+	logger     *log.Logger
+	now        func() time.Time
+}
+
+// NewVerifier - create verifier from config, logger nil == log.DefaultLogger
+func NewVerifier(cfg config.JWT, logger *log.Logger) (*Verifier, error) {
+	keys := NewKeySet()
+
+	if len(cfg.JWKSFile) > 0 {
+		if err := keys.LoadJWKS(cfg.JWKSFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cfg.PEMDirectory) > 0 {
+		if err := keys.LoadPEMDirectory(cfg.PEMDirectory); err != nil {
+			return nil, err
+		}
+	}
+
+	if keys.Len() == 0 {
+		return nil, errors.New("no jwt verification keys loaded")
+	}
+
+	return NewVerifierWithKeys(keys, cfg, logger)
+}
+
+// NewVerifierWithKeys - create verifier for an existing key set, key sources in cfg are ignored
+func NewVerifierWithKeys(keys *KeySet, cfg config.JWT, logger *log.Logger) (*Verifier, error) {
+	if logger == nil {
+		logger = &log.DefaultLogger
+	}
+
+	v := Verifier{
+		keys:       keys,
+		issuer:     cfg.Issuer,
+		audience:   cfg.Audience,
+		clockSkew:  DefaultClockSkew,
+		algorithms: make(map[string]bool),
+		logger:     logger,
+		now:        time.Now,
+	}
+
+	if len(cfg.ClockSkew) > 0 {
+		skew, err := time.ParseDuration(cfg.ClockSkew)
+		if err != nil || skew < 0 {
+			return nil, fmt.Errorf("invalid clock skew '%s'", cfg.ClockSkew)
+		}
+		v.clockSkew = skew
+	}
+
+	algorithms := cfg.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{AlgorithmRS256, AlgorithmES256, AlgorithmHS256}
+	}
+
+	for _, alg := range algorithms {
+		switch alg {
+		case AlgorithmRS256, AlgorithmES256, AlgorithmHS256:
+			v.algorithms[alg] = true
+		default:
+			return nil, fmt.Errorf("%w '%s'", ErrUnsupportedAlgorithm, alg)
+		}
+	}
+
+	return &v, nil
+}
+
+// Authenticate - verify the request's bearer token, failures are logged as security events
+func (v *Verifier) Authenticate(request *http.Request) (Claims, error) {
+	token, err := BearerToken(request)
+	if err == nil {
+		var claims Claims
+		if claims, err = v.Verify(token); err == nil {
+			return claims, nil
+		}
+	}
+
+	v.logger.Warn().
+		Tags(log.SecurityTag).
+		Str("reason", err.Error()).
+		Str("path", request.URL.Path).
+		Str("remote_addr", request.RemoteAddr).
+		ECSEvent(log.Authentication, log.Failure, log.DeniedType).
+		Msg("jwt authentication failed")
+
+	return nil, err
+}
+
+// BearerToken - token from the Authorization header
+func BearerToken(request *http.Request) (string, error) {
+	authorization := request.Header.Get("Authorization")
+
+	if len(authorization) <= len(bearerPrefix) || !strings.EqualFold(authorization[:len(bearerPrefix)], bearerPrefix) {
+		return "", ErrMissingToken
+	}
+
+	token := strings.TrimSpace(authorization[len(bearerPrefix):])
+	if len(token) == 0 {
+		return "", ErrMissingToken
+	}
+
+	return token, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify - check signature, exp, nbf, iss and aud, returns the claims when valid
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var hdr header
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	if !v.algorithms[hdr.Alg] {
+		return nil, fmt.Errorf("%w '%s'", ErrUnsupportedAlgorithm, hdr.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	keys := v.keys.candidates(hdr.Kid, hdr.Alg)
+	if len(keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+
+	verified := false
+	for _, key := range keys {
+		if verifySignature(hdr.Alg, key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return nil, ErrInvalidSignature
+	}
+
+	var claims Claims
+	if err = decodeSegment(parts[1], &claims); err != nil || claims == nil {
+		return nil, ErrMalformedToken
+	}
+
+	if err = v.validateClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func decodeSegment(segment string, value interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(decoded, value)
+}
+
+func verifySignature(alg string, key interface{}, signed []byte, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+
+	switch alg {
+	case AlgorithmRS256:
+		return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	case AlgorithmES256:
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key.(*ecdsa.PublicKey), digest[:], r, s)
+	case AlgorithmHS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	}
+
+	return false
+}
+
+func (v *Verifier) validateClaims(claims Claims) error {
+	now := v.now()
+
+	if exp, found := claims["exp"]; found {
+		seconds, ok := exp.(float64)
+		if !ok {
+			return ErrMalformedToken
+		}
+		if now.After(time.Unix(int64(seconds), 0).Add(v.clockSkew)) {
+			return ErrExpired
+		}
+	}
+
+	if nbf, found := claims["nbf"]; found {
+		seconds, ok := nbf.(float64)
+		if !ok {
+			return ErrMalformedToken
+		}
+		if now.Add(v.clockSkew).Before(time.Unix(int64(seconds), 0)) {
+			return ErrNotYetValid
+		}
+	}
+
+	if len(v.issuer) > 0 {
+		if iss, _ := claims["iss"].(string); iss != v.issuer {
+			return ErrInvalidIssuer
+		}
+	}
+
+	if len(v.audience) > 0 && !audienceMatches(claims["aud"], v.audience) {
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
+
+// audienceMatches - aud is a string or an array of strings per RFC 7519
+func audienceMatches(aud interface{}, expected []string) bool {
+	values := make([]string, 0)
+
+	switch typed := aud.(type) {
+	case string:
+		values = append(values, typed)
+	case []interface{}:
+		for _, value := range typed {
+			if str, ok := value.(string); ok {
+				values = append(values, str)
+			}
+		}
+	}
+
+	for _, value := range values {
+		for _, want := range expected {
+			if value == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	pemExtension    = ".pem"
+	secretExtension = ".secret"
+)
+
+// KeySet holds verification keys by key id, keys are *rsa.PublicKey, *ecdsa.PublicKey (P-256) or []byte (HMAC)
+type KeySet struct {
+	keys map[string]interface{}
+}
+
+// NewKeySet - empty key set
+func NewKeySet() *KeySet {
+	return &KeySet{keys: make(map[string]interface{})}
+}
+
+// Add - add key under kid, replaces an existing key with the same kid
+func (k *KeySet) Add(kid string, key interface{}) error {
+	switch typed := key.(type) {
+	case *rsa.PublicKey:
+	case *ecdsa.PublicKey:
+		if typed.Curve != elliptic.P256() {
+			return fmt.Errorf("key '%s' unsupported curve %s", kid, typed.Curve.Params().Name)
+		}
+	case []byte:
+		if len(typed) == 0 {
+			return fmt.Errorf("key '%s' empty secret", kid)
+		}
+	default:
+		return fmt.Errorf("key '%s' unsupported type %T", kid, key)
+	}
+
+	k.keys[kid] = key
+
+	return nil
+}
+
+// Len - number of keys
+func (k *KeySet) Len() int {
+	return len(k.keys)
+}
+
+// candidates - keys usable for alg, only the kid key when kid is set
+func (k *KeySet) candidates(kid string, alg string) []interface{} {
+	results := make([]interface{}, 0)
+
+	if len(kid) > 0 {
+		if key, found := k.keys[kid]; found && keyMatchesAlgorithm(key, alg) {
+			results = append(results, key)
+		}
+		return results
+	}
+
+	for _, key := range k.keys {
+		if keyMatchesAlgorithm(key, alg) {
+			results = append(results, key)
+		}
+	}
+
+	return results
+}
+
+func keyMatchesAlgorithm(key interface{}, alg string) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return alg == AlgorithmRS256
+	case *ecdsa.PublicKey:
+		return alg == AlgorithmES256
+	case []byte:
+		return alg == AlgorithmHS256
+	}
+
+	return false
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// LoadJWKS - add the keys in a JWKS file, encryption keys (use "enc") are skipped
+func (k *KeySet) LoadJWKS(fileName string) error {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err = json.Unmarshal(content, &jwks); err != nil {
+		return fmt.Errorf("jwks '%s': %w", fileName, err)
+	}
+
+	for idx, jwk := range jwks.Keys {
+		if jwk.Use == "enc" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			return fmt.Errorf("jwks '%s' key %d: %w", fileName, idx, err)
+		}
+
+		if err = k.Add(jwk.Kid, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (j jsonWebKey) publicKey() (interface{}, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeBigInt(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(j.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("rsa exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if j.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve '%s'", j.Crv)
+		}
+		x, err := decodeBigInt(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("ec point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(j.K, "="))
+	}
+
+	return nil, fmt.Errorf("unsupported key type '%s'", j.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+
+	return new(big.Int).SetBytes(decoded), nil
+}
+
+// LoadPEMDirectory - add *.pem public keys/certificates and *.secret HMAC keys in directory, the file name (without extension) is the kid
+func (k *KeySet) LoadPEMDirectory(directory string) error {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		extension := filepath.Ext(entry.Name())
+		if extension != pemExtension && extension != secretExtension {
+			continue
+		}
+
+		fileName := filepath.Join(directory, entry.Name())
+		kid := strings.TrimSuffix(entry.Name(), extension)
+
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			return err
+		}
+
+		var key interface{}
+		if extension == secretExtension {
+			key = []byte(strings.TrimSpace(string(content)))
+		} else if key, err = parsePEMPublicKey(content); err != nil {
+			return fmt.Errorf("pem '%s': %w", fileName, err)
+		}
+
+		if err = k.Add(kid, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parsePEMPublicKey(content []byte) (interface{}, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return certificate.PublicKey, nil
+	}
+
+	return nil, fmt.Errorf("unsupported pem block '%s'", block.Type)
+}
@@ -0,0 +1,205 @@
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/gometrics"
+)
+
+const (
+	EncodingBrotli   = "br"
+	EncodingGzip     = "gzip"
+	EncodingDeflate  = "deflate"
+	EncodingIdentity = "identity"
+
+	// QueryParameter forces an encoding (?compress=gzip), no compression (?compress=identity)
+	// or a corrupt body with a valid Content-Encoding header (?compress=broken, ?compress=broken-br)
+	QueryParameter = "compress"
+	brokenPrefix   = "broken"
+
+	DefaultMinSize = 1024
+
+	HttpHeader_AcceptEncoding  = "Accept-Encoding"
+	HttpHeader_ContentEncoding = "Content-Encoding"
+	HttpHeader_ContentLength   = "Content-Length"
+	HttpHeader_ContentRange    = "Content-Range"
+	HttpHeader_ContentType     = "Content-Type"
+	HttpHeader_ETag            = "ETag"
+	HttpHeader_Vary            = "Vary"
+)
+
+var (
+	DefaultContentTypes = []string{"text/*", "application/json", "application/javascript", "application/xml", "application/problem+json", "application/health+json", "image/svg+xml"}
+	DefaultEncodings    = []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+)
+
+// Compressor compresses response bodies with the best encoding the client accepts
+type Compressor struct {
+	minSize      int
+	contentTypes []string
+	encodings    []string
+	level        int
+	metrics      gometrics.IGoMetrics
+}
+
+// New - create compressor from config, gm nil disables metrics
+func New(cfg config.Compression, gm gometrics.IGoMetrics) (*Compressor, error) {
+	c := Compressor{
+		minSize:      cfg.MinSize,
+		contentTypes: cfg.ContentTypes,
+		encodings:    cfg.Encodings,
+		level:        cfg.Level,
+		metrics:      gm,
+	}
+
+	if c.minSize == 0 {
+		c.minSize = DefaultMinSize
+	} else if c.minSize < 0 {
+		c.minSize = 0
+	}
+
+	if len(c.contentTypes) == 0 {
+		c.contentTypes = DefaultContentTypes
+	}
+
+	if len(c.encodings) == 0 {
+		c.encodings = DefaultEncodings
+	}
+
+	for _, encoding := range c.encodings {
+		if !isSupported(encoding) {
+			return nil, fmt.Errorf("unsupported encoding '%s'", encoding)
+		}
+	}
+
+	if c.level < 0 || c.level > 9 {
+		return nil, fmt.Errorf("invalid compression level %d", c.level)
+	}
+
+	return &c, nil
+}
+
+func isSupported(encoding string) bool {
+	return encoding == EncodingBrotli || encoding == EncodingGzip || encoding == EncodingDeflate
+}
+
+// Handler - wrap next so its responses are compressed
+func (c *Compressor) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		writer := &compressWriter{
+			ResponseWriter: responseWriter,
+			compressor:     c,
+			head:           request.Method == http.MethodHead,
+			encoding:       c.Negotiate(request.Header.Get(HttpHeader_AcceptEncoding)),
+		}
+
+		if override := request.URL.Query().Get(QueryParameter); len(override) > 0 {
+			writer.applyOverride(override)
+		}
+
+		defer writer.Close()
+
+		next.ServeHTTP(writer, request)
+	})
+}
+
+// Negotiate - best supported encoding for an Accept-Encoding header, server order breaks ties, identity when nothing matches
+func (c *Compressor) Negotiate(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+	wildcard := -1.0
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if len(coding) == 0 {
+			continue
+		}
+		if coding == "x-gzip" {
+			coding = EncodingGzip
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = value
+				}
+			}
+		}
+
+		if coding == "*" {
+			wildcard = quality
+		} else {
+			qualities[coding] = quality
+		}
+	}
+
+	best := EncodingIdentity
+	bestQuality := 0.0
+
+	for _, encoding := range c.encodings {
+		quality, found := qualities[encoding]
+		if !found {
+			quality = wildcard
+		}
+		if quality > bestQuality {
+			best = encoding
+			bestQuality = quality
+		}
+	}
+
+	return best
+}
+
+// allowsContentType - media type of contentType is in the allowlist
+func (c *Compressor) allowsContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range c.contentTypes {
+		if allowed == mediaType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newEncoder - encoder writing encoding to w
+func (c *Compressor) newEncoder(encoding string, w io.Writer) io.WriteCloser {
+	switch encoding {
+	case EncodingBrotli:
+		level := brotli.DefaultCompression
+		if c.level > 0 {
+			level = c.level
+		}
+		return brotli.NewWriterLevel(w, level)
+	case EncodingDeflate:
+		level := zlib.DefaultCompression
+		if c.level > 0 {
+			level = c.level
+		}
+		encoder, _ := zlib.NewWriterLevel(w, level)
+		return encoder
+	default:
+		level := gzip.DefaultCompression
+		if c.level > 0 {
+			level = c.level
+		}
+		encoder, _ := gzip.NewWriterLevel(w, level)
+		return encoder
+	}
+}
@@ -0,0 +1,272 @@
+package compress_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/compress"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/gometrics"
+)
+
+var largeBody = strings.Repeat("<p>go-http-server compression test body</p>", 100)
+
+func createTestCompressor(t *testing.T, cfg config.Compression) (*compress.Compressor, *gometrics.GoMetrics) {
+	gm := gometrics.NewGoMetrics(metrics.NewRegistry(), "unit.test")
+	gm.CreateMetrics()
+
+	compressor, err := compress.New(cfg, gm)
+	assert.Nil(t, err)
+
+	return compressor, gm
+}
+
+// serve - response for a handler writing body with contentType
+func serve(compressor *compress.Compressor, target string, acceptEncoding string, contentType string, body string) *httptest.ResponseRecorder {
+	handler := compressor.Handler(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		if len(contentType) > 0 {
+			responseWriter.Header().Set(compress.HttpHeader_ContentType, contentType)
+		}
+		responseWriter.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(responseWriter, body)
+	}))
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	if len(acceptEncoding) > 0 {
+		request.Header.Set(compress.HttpHeader_AcceptEncoding, acceptEncoding)
+	}
+
+	handler.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+func decode(t *testing.T, encoding string, body []byte) (string, error) {
+	var reader io.Reader
+	var err error
+
+	switch encoding {
+	case compress.EncodingGzip:
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case compress.EncodingDeflate:
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+	case compress.EncodingBrotli:
+		reader = brotli.NewReader(bytes.NewReader(body))
+	default:
+		reader = bytes.NewReader(body)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	decoded, err := io.ReadAll(reader)
+
+	return string(decoded), err
+}
+
+func Test_Negotiate(t *testing.T) {
+	assert := assert.New(t)
+	compressor, _ := createTestCompressor(t, config.Compression{})
+
+	testCases := []struct {
+		AcceptEncoding string
+		Expected       string
+		Description    string
+	}{
+		{AcceptEncoding: "", Expected: compress.EncodingIdentity, Description: "no header"},
+		{AcceptEncoding: "gzip, deflate, br", Expected: compress.EncodingBrotli, Description: "server preference breaks ties"},
+		{AcceptEncoding: "gzip;q=1.0, br;q=0.5", Expected: compress.EncodingGzip, Description: "client quality wins"},
+		{AcceptEncoding: "x-gzip", Expected: compress.EncodingGzip, Description: "legacy gzip"},
+		{AcceptEncoding: "*", Expected: compress.EncodingBrotli, Description: "wildcard"},
+		{AcceptEncoding: "*, br;q=0", Expected: compress.EncodingGzip, Description: "wildcard with exclusion"},
+		{AcceptEncoding: "compress, zstd", Expected: compress.EncodingIdentity, Description: "nothing supported"},
+		{AcceptEncoding: "gzip;q=0", Expected: compress.EncodingIdentity, Description: "refused"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(tc.Expected, compressor.Negotiate(tc.AcceptEncoding), tc.Description)
+	}
+}
+
+func Test_Compression(t *testing.T) {
+	assert := assert.New(t)
+	compressor, _ := createTestCompressor(t, config.Compression{})
+
+	testCases := []struct {
+		Target           string
+		AcceptEncoding   string
+		ContentType      string
+		Body             string
+		ExpectedEncoding string
+		ExpectedVary     string
+		Description      string
+	}{
+		{
+			Target:           "/",
+			AcceptEncoding:   "gzip",
+			ContentType:      "text/html; charset=utf-8",
+			Body:             largeBody,
+			ExpectedEncoding: compress.EncodingGzip,
+			ExpectedVary:     compress.HttpHeader_AcceptEncoding,
+			Description:      "gzip",
+		},
+		{
+			Target:           "/",
+			AcceptEncoding:   "deflate",
+			ContentType:      "application/json",
+			Body:             largeBody,
+			ExpectedEncoding: compress.EncodingDeflate,
+			ExpectedVary:     compress.HttpHeader_AcceptEncoding,
+			Description:      "deflate",
+		},
+		{
+			Target:           "/",
+			AcceptEncoding:   "br",
+			ContentType:      "text/plain",
+			Body:             largeBody,
+			ExpectedEncoding: compress.EncodingBrotli,
+			ExpectedVary:     compress.HttpHeader_AcceptEncoding,
+			Description:      "brotli",
+		},
+		{
+			Target:           "/",
+			AcceptEncoding:   "",
+			ContentType:      "text/html",
+			Body:             largeBody,
+			ExpectedEncoding: "",
+			ExpectedVary:     compress.HttpHeader_AcceptEncoding,
+			Description:      "client without compression still gets Vary",
+		},
+		{
+			Target:           "/",
+			AcceptEncoding:   "gzip",
+			ContentType:      "text/html",
+			Body:             "<p>small</p>",
+			ExpectedEncoding: "",
+			ExpectedVary:     "",
+			Description:      "below minimum size",
+		},
+		{
+			Target:           "/",
+			AcceptEncoding:   "gzip",
+			ContentType:      "image/png",
+			Body:             largeBody,
+			ExpectedEncoding: "",
+			ExpectedVary:     "",
+			Description:      "content type not allowed",
+		},
+		{
+			Target:           "/?compress=br",
+			AcceptEncoding:   "",
+			ContentType:      "image/png",
+			Body:             "tiny",
+			ExpectedEncoding: compress.EncodingBrotli,
+			ExpectedVary:     "",
+			Description:      "forced encoding ignores negotiation, size and type",
+		},
+		{
+			Target:           "/?compress=identity",
+			AcceptEncoding:   "gzip",
+			ContentType:      "text/html",
+			Body:             largeBody,
+			ExpectedEncoding: "",
+			ExpectedVary:     "",
+			Description:      "forced identity",
+		},
+	}
+
+	for _, tc := range testCases {
+		recorder := serve(compressor, tc.Target, tc.AcceptEncoding, tc.ContentType, tc.Body)
+
+		encoding := recorder.Header().Get(compress.HttpHeader_ContentEncoding)
+		assert.Equal(http.StatusOK, recorder.Code, tc.Description)
+		assert.Equal(tc.ExpectedEncoding, encoding, tc.Description)
+		assert.Equal(tc.ExpectedVary, recorder.Header().Get(compress.HttpHeader_Vary), tc.Description)
+
+		decoded, err := decode(t, encoding, recorder.Body.Bytes())
+		assert.Nil(err, tc.Description)
+		assert.Equal(tc.Body, decoded, tc.Description)
+	}
+}
+
+func Test_BrokenEncoding(t *testing.T) {
+	assert := assert.New(t)
+	compressor, _ := createTestCompressor(t, config.Compression{})
+
+	for _, override := range []string{"broken", "broken-deflate", "broken-br"} {
+		recorder := serve(compressor, "/?compress="+override, "", "text/html", largeBody)
+
+		encoding := recorder.Header().Get(compress.HttpHeader_ContentEncoding)
+		assert.NotEqual("", encoding, override)
+
+		decoded, err := decode(t, encoding, recorder.Body.Bytes())
+		assert.True(err != nil || decoded != largeBody, "%s body should not decode", override)
+	}
+}
+
+func Test_CompressionMetricsAndHeaders(t *testing.T) {
+	assert := assert.New(t)
+	compressor, gm := createTestCompressor(t, config.Compression{MinSize: -1})
+
+	handler := compressor.Handler(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.Header().Set(compress.HttpHeader_ContentType, "text/html")
+		responseWriter.Header().Set(compress.HttpHeader_ContentLength, "4400")
+		responseWriter.Header().Set(compress.HttpHeader_ETag, `"abc"`)
+		responseWriter.Header().Set(compress.HttpHeader_Vary, "Origin")
+		_, _ = io.WriteString(responseWriter, largeBody)
+	}))
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set(compress.HttpHeader_AcceptEncoding, "gzip")
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal("", recorder.Header().Get(compress.HttpHeader_ContentLength), "length of the uncompressed body is dropped")
+	assert.Equal(`W/"abc"`, recorder.Header().Get(compress.HttpHeader_ETag), "strong etag is weakened")
+	assert.Equal([]string{"Origin", compress.HttpHeader_AcceptEncoding}, recorder.Header().Values(compress.HttpHeader_Vary))
+
+	assert.Equal(int64(len(largeBody)), gm.TrackedMetrics.CompressionUncompressedBytes.Count())
+	assert.Equal(int64(recorder.Body.Len()), gm.TrackedMetrics.CompressionCompressedBytes.Count())
+	assert.Less(recorder.Body.Len(), len(largeBody))
+}
+
+func Test_NoBodyResponses(t *testing.T) {
+	assert := assert.New(t)
+	compressor, _ := createTestCompressor(t, config.Compression{MinSize: -1})
+
+	handler := compressor.Handler(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		responseWriter.Header().Set(compress.HttpHeader_ContentType, "text/html")
+		responseWriter.WriteHeader(http.StatusNotModified)
+	}))
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set(compress.HttpHeader_AcceptEncoding, "gzip")
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal(http.StatusNotModified, recorder.Code)
+	assert.Equal("", recorder.Header().Get(compress.HttpHeader_ContentEncoding))
+	assert.Equal(0, recorder.Body.Len())
+}
+
+func Test_InvalidConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := compress.New(config.Compression{Encodings: []string{"zstd"}}, nil)
+	assert.NotNil(err)
+
+	_, err = compress.New(config.Compression{Level: 12}, nil)
+	assert.NotNil(err)
+}
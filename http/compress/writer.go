@@ -0,0 +1,271 @@
+package compress
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// compressWriter buffers the first minSize bytes to decide whether to compress, then streams through the encoder
+type compressWriter struct {
+	http.ResponseWriter
+	compressor *Compressor
+	head       bool
+	encoding   string // negotiated or forced encoding, identity for none
+	forced     bool   // encoding set by the query override, size and content type are ignored
+	broken     bool   // corrupt the compressed body
+
+	status  int
+	decided bool
+	closed  bool
+	buffer  []byte
+
+	encoder      io.WriteCloser
+	output       *countingWriter
+	brokenBody   *bytes.Buffer
+	uncompressed int64
+}
+
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// applyOverride - ?compress= value, unknown values are ignored
+func (w *compressWriter) applyOverride(override string) {
+	override = strings.ToLower(override)
+
+	if strings.HasPrefix(override, brokenPrefix) {
+		encoding := strings.TrimPrefix(strings.TrimPrefix(override, brokenPrefix), "-")
+		if len(encoding) == 0 {
+			encoding = EncodingGzip
+		}
+		if !isSupported(encoding) {
+			return
+		}
+		w.encoding = encoding
+		w.forced = true
+		w.broken = true
+		return
+	}
+
+	if override == EncodingIdentity || override == "none" || isSupported(override) {
+		w.encoding = override
+		if override == "none" {
+			w.encoding = EncodingIdentity
+		}
+		w.forced = true
+	}
+}
+
+// Unwrap - underlying writer for http.ResponseController
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) WriteHeader(statusCode int) {
+	if w.decided {
+		return
+	}
+
+	// informational responses go straight out, the final status is still to come
+	if statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	if w.status == 0 {
+		w.status = statusCode
+	}
+
+	// no body will follow
+	if !bodyAllowed(statusCode) {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if !w.decided {
+		w.buffer = append(w.buffer, p...)
+		if len(w.buffer) >= w.compressor.minSize {
+			if err := w.decide(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(p), nil
+	}
+
+	return w.writeBody(p)
+}
+
+func (w *compressWriter) writeBody(p []byte) (int, error) {
+	if w.encoder == nil {
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.uncompressed += int64(len(p))
+
+	return w.encoder.Write(p)
+}
+
+// Flush - commit to a decision and flush whatever is encoded so far
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		_ = w.decide(true)
+	}
+
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok && !w.broken {
+		_ = flusher.Flush()
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func bodyAllowed(statusCode int) bool {
+	return statusCode >= 200 && statusCode != http.StatusNoContent && statusCode != http.StatusNotModified
+}
+
+// decide - write the header and buffered body, compressing when the response qualifies. sizeOK is false when the complete body is smaller than minSize.
+func (w *compressWriter) decide(sizeOK bool) error {
+	w.decided = true
+
+	header := w.Header()
+
+	contentType := header.Get(HttpHeader_ContentType)
+	if len(contentType) == 0 && len(w.buffer) > 0 {
+		contentType = http.DetectContentType(w.buffer)
+	}
+
+	eligible := bodyAllowed(w.status) &&
+		w.status != http.StatusPartialContent &&
+		len(header.Get(HttpHeader_ContentEncoding)) == 0 &&
+		len(header.Get(HttpHeader_ContentRange)) == 0 &&
+		(w.forced || (sizeOK && w.compressor.allowsContentType(contentType)))
+
+	if eligible && !w.forced {
+		addVary(header, HttpHeader_AcceptEncoding)
+	}
+
+	if !eligible || w.encoding == EncodingIdentity {
+		w.ResponseWriter.WriteHeader(w.status)
+		if len(w.buffer) > 0 && !w.head {
+			_, err := w.ResponseWriter.Write(w.buffer)
+			return err
+		}
+		return nil
+	}
+
+	if len(header.Get(HttpHeader_ContentType)) == 0 && len(contentType) > 0 {
+		header.Set(HttpHeader_ContentType, contentType)
+	}
+	header.Del(HttpHeader_ContentLength)
+	header.Set(HttpHeader_ContentEncoding, w.encoding)
+
+	// the representation changed, a strong validator no longer matches these bytes
+	if etag := header.Get(HttpHeader_ETag); len(etag) > 0 && !strings.HasPrefix(etag, "W/") {
+		header.Set(HttpHeader_ETag, "W/"+etag)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if w.head {
+		return nil
+	}
+
+	if w.broken {
+		w.brokenBody = &bytes.Buffer{}
+		w.output = &countingWriter{writer: w.brokenBody}
+	} else {
+		w.output = &countingWriter{writer: w.ResponseWriter}
+	}
+
+	w.encoder = w.compressor.newEncoder(w.encoding, w.output)
+
+	if len(w.buffer) > 0 {
+		if _, err := w.writeBody(w.buffer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close - finish the response, must be called after the handler returns
+func (w *compressWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		if err := w.decide(len(w.buffer) >= w.compressor.minSize); err != nil {
+			return err
+		}
+	}
+
+	if w.encoder == nil {
+		return nil
+	}
+
+	if err := w.encoder.Close(); err != nil {
+		return err
+	}
+
+	if w.brokenBody != nil {
+		_, err := w.ResponseWriter.Write(corrupt(w.brokenBody.Bytes()))
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.compressor.metrics != nil {
+		w.compressor.metrics.IncCompressionBytes(w.uncompressed, w.output.count)
+	}
+
+	return nil
+}
+
+// corrupt - flip bytes in the middle of the stream and drop its trailer, the header stays valid so decoders fail mid stream
+func corrupt(body []byte) []byte {
+	if len(body) < 8 {
+		return body[:len(body)/2]
+	}
+
+	middle := len(body) / 2
+	body[middle] ^= 0xFF
+	body[middle+1] ^= 0xFF
+
+	return body[:len(body)-4]
+}
+
+func addVary(header http.Header, value string) {
+	for _, existing := range header.Values(HttpHeader_Vary) {
+		for _, field := range strings.Split(existing, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.EqualFold(field, value) {
+				return
+			}
+		}
+	}
+
+	header.Add(HttpHeader_Vary, value)
+}
@@ -0,0 +1,138 @@
+package outbound
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a destination circuit breaker
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateHalfOpen
+	StateOpen
+)
+
+var (
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// breaker is a per destination circuit breaker.
+// closed: requests flow, consecutive failures >= failureThreshold opens the breaker.
+// open: requests are rejected until openDuration has passed, then the breaker is half-open.
+// half-open: up to halfOpenMaxRequests trial requests are allowed, all succeeding closes the breaker, any failure opens it again.
+type breaker struct {
+	sync.Mutex
+	destination         string
+	failureThreshold    int
+	openDuration        time.Duration
+	halfOpenMaxRequests int
+	onStateChange       func(destination string, from BreakerState, to BreakerState)
+
+	state             BreakerState
+	failures          int
+	openedAt          time.Time
+	halfOpenInFlight  int
+	halfOpenSuccesses int
+}
+
+// allow - returns ErrCircuitOpen when the request must not be sent
+func (b *breaker) allow(now time.Time) error {
+	b.Lock()
+	defer b.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if now.Sub(b.openedAt) < b.openDuration {
+			return ErrCircuitOpen
+		}
+		b.setState(StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if b.halfOpenInFlight >= b.halfOpenMaxRequests {
+			return ErrCircuitOpen
+		}
+		b.halfOpenInFlight++
+	}
+
+	return nil
+}
+
+// record - record the outcome of an allowed request
+func (b *breaker) record(success bool, now time.Time) {
+	b.Lock()
+	defer b.Unlock()
+
+	switch b.state {
+	case StateClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.failureThreshold {
+			b.openedAt = now
+			b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		if b.halfOpenInFlight > 0 {
+			b.halfOpenInFlight--
+		}
+		if !success {
+			b.openedAt = now
+			b.setState(StateOpen)
+			return
+		}
+		b.halfOpenSuccesses++
+		if b.halfOpenSuccesses >= b.halfOpenMaxRequests {
+			b.setState(StateClosed)
+		}
+	}
+}
+
+// release - give back an allowed request without recording an outcome, i.e. the caller cancelled it
+func (b *breaker) release() {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.state == StateHalfOpen && b.halfOpenInFlight > 0 {
+		b.halfOpenInFlight--
+	}
+}
+
+// getState - current state
+func (b *breaker) getState() BreakerState {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.state
+}
+
+// setState - caller must hold the lock
+func (b *breaker) setState(state BreakerState) {
+	from := b.state
+
+	b.state = state
+	b.failures = 0
+	b.halfOpenInFlight = 0
+	b.halfOpenSuccesses = 0
+
+	if b.onStateChange != nil && from != state {
+		b.onStateChange(b.destination, from, state)
+	}
+}
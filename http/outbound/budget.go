@@ -0,0 +1,52 @@
+package outbound
+
+import (
+	"sync"
+	"time"
+)
+
+// retryBudget limits retries to minRetries plus percent of the requests seen in the current window,
+// so a struggling destination is not hit with a retry storm.
+type retryBudget struct {
+	sync.Mutex
+	percent    int
+	minRetries int
+	window     time.Duration
+
+	windowStart time.Time
+	requests    int
+	retries     int
+}
+
+func (b *retryBudget) roll(now time.Time) {
+	if now.Sub(b.windowStart) >= b.window {
+		b.windowStart = now
+		b.requests = 0
+		b.retries = 0
+	}
+}
+
+// recordRequest - count an original (non retry) request
+func (b *retryBudget) recordRequest(now time.Time) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.roll(now)
+	b.requests++
+}
+
+// tryRetry - consume a retry from the budget, false when the budget is exhausted
+func (b *retryBudget) tryRetry(now time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	b.roll(now)
+
+	if b.retries >= b.minRetries+(b.requests*b.percent)/100 {
+		return false
+	}
+
+	b.retries++
+
+	return true
+}
@@ -0,0 +1,332 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/log"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/gometrics"
+)
+
+const (
+	DefaultTimeout             = 30 * time.Second
+	DefaultMaxRetries          = 2
+	DefaultBaseBackoff         = 50 * time.Millisecond
+	DefaultMaxBackoff          = 2 * time.Second
+	DefaultRetryBudgetPercent  = 20
+	DefaultRetryBudgetMin      = 10
+	DefaultRetryBudgetWindow   = 10 * time.Second
+	DefaultFailureThreshold    = 5
+	DefaultOpenDuration        = 30 * time.Second
+	DefaultHalfOpenMaxRequests = 1
+
+	HttpHeader_IdempotencyKey = "Idempotency-Key" // marks a POST or PATCH as safe to retry
+)
+
+// Client is an outbound http.RoundTripper with per destination circuit breakers, bounded retries with jittered backoff and a retry budget
+type Client struct {
+	transport           http.RoundTripper
+	timeout             time.Duration
+	maxRetries          int
+	baseBackoff         time.Duration
+	maxBackoff          time.Duration
+	failureThreshold    int
+	openDuration        time.Duration
+	halfOpenMaxRequests int
+	budget              *retryBudget
+	logger              *log.Logger
+	metrics             gometrics.IGoMetrics
+	retries             metrics.Counter
+
+	breakersLock sync.Mutex
+	breakers     map[string]*breaker
+	gauges       map[string]metrics.Gauge
+}
+
+// New - create outbound client, transport nil == http.DefaultTransport, logger nil == log.DefaultLogger, gm nil disables metrics
+func New(cfg config.Outbound, transport http.RoundTripper, logger *log.Logger, gm gometrics.IGoMetrics) *Client {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	if logger == nil {
+		logger = &log.DefaultLogger
+	}
+
+	client := Client{
+		transport:           transport,
+		timeout:             parseDuration(cfg.Timeout, DefaultTimeout),
+		maxRetries:          withDefault(cfg.MaxRetries, DefaultMaxRetries),
+		baseBackoff:         parseDuration(cfg.BaseBackoff, DefaultBaseBackoff),
+		maxBackoff:          parseDuration(cfg.MaxBackoff, DefaultMaxBackoff),
+		failureThreshold:    withDefault(cfg.CircuitBreaker.FailureThreshold, DefaultFailureThreshold),
+		openDuration:        parseDuration(cfg.CircuitBreaker.OpenDuration, DefaultOpenDuration),
+		halfOpenMaxRequests: withDefault(cfg.CircuitBreaker.HalfOpenMaxRequests, DefaultHalfOpenMaxRequests),
+		budget: &retryBudget{
+			percent:    withDefault(cfg.RetryBudget.Percent, DefaultRetryBudgetPercent),
+			minRetries: withDefault(cfg.RetryBudget.MinRetries, DefaultRetryBudgetMin),
+			window:     parseDuration(cfg.RetryBudget.Window, DefaultRetryBudgetWindow),
+		},
+		logger:   logger,
+		metrics:  gm,
+		breakers: make(map[string]*breaker),
+		gauges:   make(map[string]metrics.Gauge),
+	}
+
+	if gm != nil {
+		client.retries = gm.CreateCounter(client.metricName("outbound.retries"))
+	}
+
+	return &client
+}
+
+// HTTPClient - *http.Client using this client as its transport
+func (c *Client) HTTPClient() *http.Client {
+	return &http.Client{
+		Transport: c,
+		Timeout:   c.timeout,
+	}
+}
+
+// BreakerState - current breaker state for destination (host:port), closed when unknown or breakers are disabled
+func (c *Client) BreakerState(destination string) BreakerState {
+	c.breakersLock.Lock()
+	brk, found := c.breakers[destination]
+	c.breakersLock.Unlock()
+
+	if !found {
+		return StateClosed
+	}
+
+	return brk.getState()
+}
+
+// RoundTrip - send request, retrying retryable failures while the breaker and retry budget allow it
+func (c *Client) RoundTrip(request *http.Request) (*http.Response, error) {
+	destination := request.URL.Host
+	brk := c.getBreaker(destination)
+
+	c.budget.recordRequest(time.Now())
+
+	for attempt := 0; ; attempt++ {
+		attemptRequest, err := prepareAttempt(request, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		if brk != nil {
+			if err := brk.allow(time.Now()); err != nil {
+				return nil, fmt.Errorf("%w: %s", err, destination)
+			}
+		}
+
+		response, err := c.transport.RoundTrip(attemptRequest)
+
+		if brk != nil {
+			switch {
+			case err != nil && errors.Is(err, context.Canceled):
+				brk.release()
+			default:
+				brk.record(err == nil && response.StatusCode < http.StatusInternalServerError, time.Now())
+			}
+		}
+
+		if !c.shouldRetry(request, response, err, attempt) {
+			return response, err
+		}
+
+		if !c.budget.tryRetry(time.Now()) {
+			c.logger.Warn().
+				Tags(log.ApplicationTag).
+				Str("destination", destination).
+				ECSEvent(log.Web, log.Failure, log.InformationType).
+				Msg("outbound retry budget exhausted")
+			return response, err
+		}
+
+		if response != nil {
+			_, _ = io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+
+		if c.retries != nil {
+			c.retries.Inc(1)
+		}
+
+		timer := time.NewTimer(c.backoff(attempt))
+		select {
+		case <-request.Context().Done():
+			timer.Stop()
+			return nil, request.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry - transport errors and 502/503/504 are retried for idempotent requests,
+// other requests only when the connection could not be made, the destination may have handled them already
+func (c *Client) shouldRetry(request *http.Request, response *http.Response, err error, attempt int) bool {
+	if attempt >= c.maxRetries || request.Context().Err() != nil {
+		return false
+	}
+
+	if !isReplayable(request) {
+		return false
+	}
+
+	if err != nil {
+		return isIdempotent(request) || notSent(err)
+	}
+
+	if !isIdempotent(request) {
+		return false
+	}
+
+	switch response.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// backoff - full jitter exponential backoff, random duration in [0, min(maxBackoff, baseBackoff * 2^attempt)]
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.maxBackoff
+	if attempt < 32 {
+		if exp := c.baseBackoff << uint(attempt); exp > 0 && exp < ceiling {
+			ceiling = exp
+		}
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+func (c *Client) getBreaker(destination string) *breaker {
+	if c.failureThreshold <= 0 {
+		return nil
+	}
+
+	c.breakersLock.Lock()
+	defer c.breakersLock.Unlock()
+
+	brk, found := c.breakers[destination]
+	if !found {
+		brk = &breaker{
+			destination:         destination,
+			failureThreshold:    c.failureThreshold,
+			openDuration:        c.openDuration,
+			halfOpenMaxRequests: c.halfOpenMaxRequests,
+			onStateChange:       c.onStateChange,
+		}
+		c.breakers[destination] = brk
+
+		if c.metrics != nil {
+			gauge := c.metrics.CreateGauge(c.metricName(fmt.Sprintf("outbound.breaker.state.%s", destination)))
+			gauge.Update(int64(StateClosed))
+			c.gauges[destination] = gauge
+		}
+	}
+
+	return brk
+}
+
+// onStateChange - called with the breaker lock held, must not call back into the breaker
+func (c *Client) onStateChange(destination string, from BreakerState, to BreakerState) {
+	outcome := log.Unknown
+	switch to {
+	case StateOpen:
+		outcome = log.Failure
+	case StateClosed:
+		outcome = log.Success
+	}
+
+	c.logger.Warn().
+		Tags(log.ApplicationTag).
+		Str("destination", destination).
+		Str("from", from.String()).
+		Str("to", to.String()).
+		ECSEvent(log.Web, outcome, log.InformationType).
+		Msg("outbound circuit breaker state change")
+
+	c.breakersLock.Lock()
+	gauge, found := c.gauges[destination]
+	c.breakersLock.Unlock()
+
+	if found {
+		gauge.Update(int64(to))
+	}
+}
+
+func (c *Client) metricName(detail string) string {
+	return fmt.Sprintf("%s.%s", c.metrics.GetMetricsPrefix(), detail)
+}
+
+// isReplayable - the body, if any, can be recreated for another attempt
+func isReplayable(request *http.Request) bool {
+	return request.Body == nil || request.Body == http.NoBody || request.GetBody != nil
+}
+
+// isIdempotent - repeating the request has the same effect as sending it once, explicit with an Idempotency-Key header
+func isIdempotent(request *http.Request) bool {
+	switch request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return len(request.Header.Get(HttpHeader_IdempotencyKey)) > 0
+}
+
+// notSent - the error shows the request never reached the destination
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// prepareAttempt - the first attempt uses the request as is, retries get a fresh body
+func prepareAttempt(request *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || request.GetBody == nil {
+		return request, nil
+	}
+
+	attemptRequest := request.Clone(request.Context())
+
+	body, err := request.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	attemptRequest.Body = body
+
+	return attemptRequest, nil
+}
+
+func withDefault(value int, defaultValue int) int {
+	if value == 0 {
+		return defaultValue
+	}
+
+	return value
+}
+
+func parseDuration(value string, defaultValue time.Duration) time.Duration {
+	if len(value) == 0 {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return defaultValue
+	}
+
+	return duration
+}
@@ -0,0 +1,235 @@
+package outbound_test
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/outbound"
+	"github.com/mdonahue-godaddy/go-http-server/log"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/gometrics"
+)
+
+// createTestBackend - fails the first `failures` requests with 503, then returns 200
+func createTestBackend(failures int32, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		if atomic.AddInt32(hits, 1) <= failures {
+			responseWriter.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		responseWriter.WriteHeader(http.StatusOK)
+	}))
+}
+
+type roundTripperFunc func(request *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func createTestClient(cfg config.Outbound) (*outbound.Client, *gometrics.GoMetrics) {
+	logger := log.NewLogger()
+	logger.Logger = logger.Logger.Output(&bytes.Buffer{})
+
+	gm := gometrics.NewGoMetrics(metrics.NewRegistry(), "unit.test")
+
+	cfg.BaseBackoff = "1ms"
+	cfg.MaxBackoff = "2ms"
+
+	return outbound.New(cfg, nil, &logger, gm), gm
+}
+
+func Test_RetryThenSuccess(t *testing.T) {
+	assert := assert.New(t)
+
+	hits := int32(0)
+	backend := createTestBackend(2, &hits)
+	defer backend.Close()
+
+	client, _ := createTestClient(config.Outbound{MaxRetries: 2})
+
+	response, err := client.HTTPClient().Get(backend.URL)
+
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, response.StatusCode)
+	assert.Equal(int32(3), atomic.LoadInt32(&hits))
+}
+
+func Test_RetriesAreBounded(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		MaxRetries   int
+		ExpectedHits int32
+		Description  string
+	}{
+		{
+			MaxRetries:   1,
+			ExpectedHits: 2,
+			Description:  "one retry",
+		},
+		{
+			MaxRetries:   -1,
+			ExpectedHits: 1,
+			Description:  "retries disabled",
+		},
+	}
+
+	for _, tc := range testCases {
+		hits := int32(0)
+		backend := createTestBackend(10, &hits)
+
+		cfg := config.Outbound{MaxRetries: tc.MaxRetries}
+		cfg.CircuitBreaker.FailureThreshold = -1
+		client, _ := createTestClient(cfg)
+
+		response, err := client.HTTPClient().Get(backend.URL)
+
+		assert.Nil(err, tc.Description)
+		assert.Equal(http.StatusServiceUnavailable, response.StatusCode, tc.Description)
+		assert.Equal(tc.ExpectedHits, atomic.LoadInt32(&hits), tc.Description)
+
+		backend.Close()
+	}
+}
+
+func Test_NonReplayableRequestNotRetried(t *testing.T) {
+	assert := assert.New(t)
+
+	hits := int32(0)
+	backend := createTestBackend(10, &hits)
+	defer backend.Close()
+
+	cfg := config.Outbound{MaxRetries: 3}
+	cfg.CircuitBreaker.FailureThreshold = -1
+	client, _ := createTestClient(cfg)
+
+	testCases := []struct {
+		Method         string
+		IdempotencyKey string
+		NoGetBody      bool
+		ExpectedHits   int32
+		Description    string
+	}{
+		{Method: http.MethodPost, ExpectedHits: 1, Description: "post"},
+		{Method: http.MethodPatch, ExpectedHits: 1, Description: "patch"},
+		{Method: http.MethodPost, IdempotencyKey: "key-1", ExpectedHits: 4, Description: "post with idempotency key"},
+		{Method: http.MethodPut, NoGetBody: true, ExpectedHits: 1, Description: "body cannot be recreated"},
+		{Method: http.MethodPut, ExpectedHits: 4, Description: "put"},
+	}
+
+	for _, tc := range testCases {
+		atomic.StoreInt32(&hits, 0)
+
+		request, _ := http.NewRequest(tc.Method, backend.URL, strings.NewReader("body"))
+		if len(tc.IdempotencyKey) > 0 {
+			request.Header.Set(outbound.HttpHeader_IdempotencyKey, tc.IdempotencyKey)
+		}
+		if tc.NoGetBody {
+			request.GetBody = nil
+		}
+
+		response, err := client.HTTPClient().Do(request)
+
+		assert.Nil(err, tc.Description)
+		assert.Equal(http.StatusServiceUnavailable, response.StatusCode, tc.Description)
+		assert.Equal(tc.ExpectedHits, atomic.LoadInt32(&hits), tc.Description)
+	}
+}
+
+func Test_NonIdempotentRetriedWhenNotSent(t *testing.T) {
+	assert := assert.New(t)
+
+	attempts := int32(0)
+	transport := roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&attempts, 1)
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	})
+
+	logger := log.NewLogger()
+	logger.Logger = logger.Logger.Output(&bytes.Buffer{})
+	cfg := config.Outbound{MaxRetries: 2, BaseBackoff: "1ms", MaxBackoff: "2ms"}
+	cfg.CircuitBreaker.FailureThreshold = -1
+	client := outbound.New(cfg, transport, &logger, gometrics.NewGoMetrics(metrics.NewRegistry(), "unit.test"))
+
+	request, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1:1/", strings.NewReader("body"))
+	_, err := client.RoundTrip(request)
+
+	assert.NotNil(err)
+	assert.Equal(int32(3), atomic.LoadInt32(&attempts), "dial failures are retried")
+}
+
+func Test_RetryBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	hits := int32(0)
+	backend := createTestBackend(100, &hits)
+	defer backend.Close()
+
+	cfg := config.Outbound{MaxRetries: 5}
+	cfg.RetryBudget.Percent = -1
+	cfg.RetryBudget.MinRetries = 2
+	cfg.CircuitBreaker.FailureThreshold = -1
+	client, gm := createTestClient(cfg)
+
+	_, err := client.HTTPClient().Get(backend.URL)
+
+	assert.Nil(err)
+	assert.Equal(int32(3), atomic.LoadInt32(&hits), "budget allows only 2 retries")
+	assert.Equal(int64(2), gm.CreateCounter(gm.CreateMetricName("outbound.retries")).Count())
+}
+
+func Test_CircuitBreaker(t *testing.T) {
+	assert := assert.New(t)
+
+	hits := int32(0)
+	backend := createTestBackend(2, &hits)
+	defer backend.Close()
+
+	cfg := config.Outbound{MaxRetries: -1}
+	cfg.CircuitBreaker.FailureThreshold = 2
+	cfg.CircuitBreaker.OpenDuration = "50ms"
+	client, gm := createTestClient(cfg)
+
+	backendURL, _ := url.Parse(backend.URL)
+	gauge := gm.CreateGauge(gm.CreateMetricName("outbound.breaker.state." + backendURL.Host))
+
+	for idx := 0; idx < 2; idx++ {
+		_, err := client.HTTPClient().Get(backend.URL)
+		assert.Nil(err)
+	}
+
+	assert.Equal(outbound.StateOpen, client.BreakerState(backendURL.Host))
+	assert.Equal(int64(outbound.StateOpen), gauge.Value())
+
+	_, err := client.HTTPClient().Get(backend.URL)
+	assert.True(errors.Is(err, outbound.ErrCircuitOpen), "open breaker rejects requests")
+	assert.Equal(int32(2), atomic.LoadInt32(&hits), "rejected request never reached the backend")
+
+	time.Sleep(60 * time.Millisecond)
+
+	response, err := client.HTTPClient().Get(backend.URL)
+	assert.Nil(err)
+	assert.Equal(http.StatusOK, response.StatusCode)
+	assert.Equal(outbound.StateClosed, client.BreakerState(backendURL.Host), "successful half-open trial closes the breaker")
+	assert.Equal(int64(outbound.StateClosed), gauge.Value())
+}
+
+func Test_BreakerStateString(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("closed", outbound.StateClosed.String())
+	assert.Equal("half-open", outbound.StateHalfOpen.String())
+	assert.Equal("open", outbound.StateOpen.String())
+}
@@ -0,0 +1,316 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/auth"
+	auditlog "github.com/mdonahue-godaddy/go-http-server/log"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/gometrics"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/health"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+// Admin API, mounted on the metrics port under AdminPathPrefix.
+// Every request needs the configured bearer token and every action, allowed or denied, is audit logged with the security tag.
+
+const (
+	AdminPathPrefix = "/admin/"
+
+	ReadinessAuto = health.ReadinessAuto
+	ReadinessUp   = health.ReadinessUp
+	ReadinessDown = health.ReadinessDown
+
+	Redacted = "[REDACTED]"
+
+	adminMaxBodyBytes = 1 << 16
+)
+
+// secretKeys - config keys holding secrets, matched case insensitively as substrings ignoring - and _
+var secretKeys = []string{"token", "secret", "password", "authorization", "apikey", "cookie"}
+
+// adminToken - bearer token from config.Admin, TokenFile wins over Token
+func adminToken(cfg config.Admin) (string, error) {
+	if len(cfg.TokenFile) == 0 {
+		return cfg.Token, nil
+	}
+
+	token, err := os.ReadFile(cfg.TokenFile)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(token)), nil
+}
+
+// GetReadinessOverride - ReadinessAuto, ReadinessUp or ReadinessDown
+func (s *Server) GetReadinessOverride() string {
+	return s.health.GetReadinessOverride()
+}
+
+// SetReadinessOverride - force readiness up or down, ReadinessAuto clears the override
+func (s *Server) SetReadinessOverride(override string) error {
+	return s.health.SetReadinessOverride(override)
+}
+
+// EffectiveConfig - current config as JSON with secrets redacted
+func (s *Server) EffectiveConfig() (map[string]interface{}, error) {
+	value, err := shared.Struct2JSONString(s.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	effective := map[string]interface{}{}
+	if err = json.Unmarshal([]byte(*value), &effective); err != nil {
+		return nil, err
+	}
+
+	redact(effective)
+
+	return effective, nil
+}
+
+// redact - replace secret values and URL passwords in decoded JSON, in place
+func redact(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typed {
+			if isSecretKey(key) && item != nil && item != "" {
+				typed[key] = Redacted
+				continue
+			}
+			typed[key] = redact(item)
+		}
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = redact(item)
+		}
+	case string:
+		if strings.Contains(typed, "://") {
+			if parsed, err := url.Parse(typed); err == nil && parsed.User != nil {
+				return parsed.Redacted()
+			}
+		}
+	}
+
+	return value
+}
+
+// isSecretKey - key names a secret, file and directory paths are not secrets
+func isSecretKey(key string) bool {
+	lower := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key))
+	if strings.HasSuffix(lower, "file") || strings.HasSuffix(lower, "directory") {
+		return false
+	}
+
+	for _, secret := range secretKeys {
+		if strings.Contains(lower, secret) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AdminHandler - admin API handler, not found for every request when no admin token is configured
+func (s *Server) AdminHandler() http.Handler {
+	if len(s.adminToken) == 0 {
+		return http.NotFoundHandler()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(AdminPathPrefix+"loglevel", s.adminLogLevel)
+	mux.HandleFunc(AdminPathPrefix+"readiness", s.adminReadiness)
+	mux.HandleFunc(AdminPathPrefix+"counters/reset", s.adminResetCounters)
+	mux.HandleFunc(AdminPathPrefix+"config", s.adminConfig)
+
+	return s.adminAuthenticate(mux)
+}
+
+// adminAuthenticate - require the admin bearer token
+func (s *Server) adminAuthenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		token, err := auth.BearerToken(request)
+
+		if err != nil || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			auditlog.Warn().
+				Tags(auditlog.SecurityTag).
+				Str("path", request.URL.Path).
+				Str("http_method", request.Method).
+				Str("remote_addr", request.RemoteAddr).
+				ECSEvent(auditlog.Authentication, auditlog.Failure, auditlog.DeniedType).
+				Msg("admin authentication failed")
+
+			responseWriter.Header().Set(HttpHeader_WWWAuthenticate, "Bearer")
+			s.writeAdminJSON(responseWriter, request, http.StatusUnauthorized, map[string]interface{}{"error": http.StatusText(http.StatusUnauthorized)})
+			return
+		}
+
+		next.ServeHTTP(responseWriter, request)
+	})
+}
+
+// audit - log an admin action with the security tag
+func (s *Server) audit(request *http.Request, action string, err error, details ...string) {
+	outcome, event := auditlog.Success, auditlog.Info()
+	if err != nil {
+		outcome, event = auditlog.Failure, auditlog.Warn()
+	}
+
+	eventType := auditlog.ChangeType
+	if request.Method == http.MethodGet || request.Method == http.MethodHead {
+		eventType = auditlog.AccessType
+	}
+
+	event = event.
+		Tags(auditlog.SecurityTag).
+		Str("action", action).
+		Str("path", request.URL.Path).
+		Str("http_method", request.Method).
+		Str("remote_addr", request.RemoteAddr).
+		Err(err)
+
+	for i := 0; i+1 < len(details); i += 2 {
+		event = event.Str(details[i], details[i+1])
+	}
+
+	event.ECSEvent(auditlog.Configuration, outcome, eventType).Msg("admin " + action)
+}
+
+// adminLogLevel - GET the log level, PUT/POST {"level": "debug"} to change it for both logrus and the zerolog audit logger
+func (s *Server) adminLogLevel(responseWriter http.ResponseWriter, request *http.Request) {
+	current := log.GetLevel().String()
+
+	switch request.Method {
+	case http.MethodGet:
+		s.audit(request, "loglevel.get", nil)
+		s.writeAdminJSON(responseWriter, request, http.StatusOK, map[string]interface{}{"level": current})
+	case http.MethodPut, http.MethodPost:
+		body := struct {
+			Level string `json:"level"`
+		}{}
+		err := decodeAdminBody(responseWriter, request, &body)
+
+		var level log.Level
+		if err == nil {
+			level, err = log.ParseLevel(body.Level)
+		}
+		if err == nil {
+			err = auditlog.SetLevel(level.String())
+		}
+		if err != nil {
+			s.audit(request, "loglevel.set", err, "requested", body.Level)
+			s.writeAdminJSON(responseWriter, request, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+			return
+		}
+
+		log.SetLevel(level)
+		s.audit(request, "loglevel.set", nil, "from", current, "to", level.String())
+		s.writeAdminJSON(responseWriter, request, http.StatusOK, map[string]interface{}{"level": level.String(), "previous": current})
+	default:
+		s.adminMethodNotAllowed(responseWriter, request, http.MethodGet, http.MethodPut, http.MethodPost)
+	}
+}
+
+// adminReadiness - GET the readiness override, PUT/POST {"readiness": "up|down|auto"} to change it
+func (s *Server) adminReadiness(responseWriter http.ResponseWriter, request *http.Request) {
+	current := s.GetReadinessOverride()
+
+	switch request.Method {
+	case http.MethodGet:
+		s.audit(request, "readiness.get", nil)
+		s.writeAdminJSON(responseWriter, request, http.StatusOK, map[string]interface{}{"readiness": current, "ready": s.IsReady(), "state": s.GetState().String()})
+	case http.MethodPut, http.MethodPost:
+		body := struct {
+			Readiness string `json:"readiness"`
+		}{}
+		err := decodeAdminBody(responseWriter, request, &body)
+		if err == nil {
+			err = s.SetReadinessOverride(body.Readiness)
+		}
+		if err != nil {
+			s.audit(request, "readiness.set", err, "requested", body.Readiness)
+			s.writeAdminJSON(responseWriter, request, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+			return
+		}
+
+		s.audit(request, "readiness.set", nil, "from", current, "to", body.Readiness)
+		s.writeAdminJSON(responseWriter, request, http.StatusOK, map[string]interface{}{"readiness": body.Readiness, "previous": current, "ready": s.IsReady()})
+	default:
+		s.adminMethodNotAllowed(responseWriter, request, http.MethodGet, http.MethodPut, http.MethodPost)
+	}
+}
+
+// adminResetCounters - POST to clear the go-metrics counters
+func (s *Server) adminResetCounters(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		s.adminMethodNotAllowed(responseWriter, request, http.MethodPost)
+		return
+	}
+
+	met, ok := s.metrics.(*gometrics.GoMetrics)
+	if !ok {
+		err := fmt.Errorf("metrics do not support resetting counters")
+		s.audit(request, "counters.reset", err)
+		s.writeAdminJSON(responseWriter, request, http.StatusNotImplemented, map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	met.ResetCounters()
+	s.audit(request, "counters.reset", nil)
+	s.writeAdminJSON(responseWriter, request, http.StatusOK, map[string]interface{}{"reset": true})
+}
+
+// adminConfig - GET the effective config with secrets redacted
+func (s *Server) adminConfig(responseWriter http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		s.adminMethodNotAllowed(responseWriter, request, http.MethodGet)
+		return
+	}
+
+	effective, err := s.EffectiveConfig()
+	s.audit(request, "config.get", err)
+	if err != nil {
+		s.writeAdminJSON(responseWriter, request, http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	s.writeAdminJSON(responseWriter, request, http.StatusOK, effective)
+}
+
+func (s *Server) adminMethodNotAllowed(responseWriter http.ResponseWriter, request *http.Request, allowed ...string) {
+	responseWriter.Header().Set(HttpHeader_Allow, strings.Join(allowed, ", "))
+	s.writeAdminJSON(responseWriter, request, http.StatusMethodNotAllowed, map[string]interface{}{"error": http.StatusText(http.StatusMethodNotAllowed)})
+}
+
+func decodeAdminBody(responseWriter http.ResponseWriter, request *http.Request, value interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(responseWriter, request.Body, adminMaxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(value); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Server) writeAdminJSON(responseWriter http.ResponseWriter, request *http.Request, httpStatusCode int, value interface{}) {
+	method := "server.writeAdminJSON"
+	ctx := shared.CreateRequestContext(request, method)
+
+	responseWriter.Header().Set(shared.HttpHeader_ContentType, shared.ContentType_ApplicationJSON)
+	responseWriter.Header().Set(HttpHeader_CacheControl, "no-store")
+	responseWriter.WriteHeader(httpStatusCode)
+
+	if err := shared.WriteJSON(ctx, responseWriter, value); err != nil {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error writing response", method)
+	}
+}
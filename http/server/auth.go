@@ -0,0 +1,51 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/http/auth"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+// Authenticate - JWT middleware, verified claims are added to the request context, failures get 401.
+// Requests pass through untouched when JWT is not configured, and are all rejected when the configured keys failed to load.
+// CORS preflights never carry credentials, the ones a CORS policy covers are answered in front of the router and never get here.
+func (s *Server) Authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		if s.verifier == nil && s.verifierErr == nil {
+			next(responseWriter, request)
+			return
+		}
+
+		method := "server.authenticate"
+
+		var claims auth.Claims
+		err := errors.New("authentication unavailable")
+		if s.verifier != nil {
+			claims, err = s.verifier.Authenticate(request)
+		}
+
+		if err != nil {
+			ctx := shared.CreateRequestContext(request, method)
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeError, true, shared.KeyErrorMessage, err.Error())).Infof("%s unauthorized", method)
+
+			challenge := fmt.Sprintf("Bearer realm=%q", BasicAuthRealm)
+			if !errors.Is(err, auth.ErrMissingToken) {
+				challenge = fmt.Sprintf("%s, error=\"invalid_token\", error_description=%q", challenge, err.Error())
+			}
+			responseWriter.Header().Set(HttpHeader_WWWAuthenticate, challenge)
+
+			s.WriteNegotiatedResponse(ctx, responseWriter, request, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), map[string]interface{}{
+				"status": http.StatusUnauthorized,
+				"error":  err.Error(),
+			})
+			return
+		}
+
+		next(responseWriter, request.WithContext(auth.ContextWithClaims(request.Context(), claims)))
+	}
+}
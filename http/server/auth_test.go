@@ -0,0 +1,147 @@
+package server_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/server"
+)
+
+// signTestToken - HS256 token, claims is the raw JSON payload
+func signTestToken(secret string, claims string) string {
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","kid":"unit"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func Test_Authenticate(t *testing.T) {
+	assert := assert.New(t)
+
+	directory := t.TempDir()
+	assert.Nil(os.WriteFile(filepath.Join(directory, "unit.secret"), []byte("unit-test-secret"), 0600))
+
+	cfg := config.Settings{}
+	cfg.JWT.PEMDirectory = directory
+	cfg.JWT.Issuer = "https://issuer.test"
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+	handler := svc.Authenticate(svc.RequestProcessor)
+
+	testCases := []struct {
+		Authorization     string
+		ExpectedStatus    int
+		ExpectedChallenge string
+		ExpectedBody      string
+		Description       string
+	}{
+		{
+			Authorization:     "",
+			ExpectedStatus:    http.StatusUnauthorized,
+			ExpectedChallenge: `Bearer realm="go-http-server"`,
+			ExpectedBody:      "missing bearer token",
+			Description:       "no token",
+		},
+		{
+			Authorization:     "Bearer " + signTestToken("wrong-secret", `{"sub":"unit-test","iss":"https://issuer.test"}`),
+			ExpectedStatus:    http.StatusUnauthorized,
+			ExpectedChallenge: `Bearer realm="go-http-server", error="invalid_token", error_description="invalid signature"`,
+			ExpectedBody:      "invalid signature",
+			Description:       "bad signature",
+		},
+		{
+			Authorization:     "Bearer " + signTestToken("unit-test-secret", `{"sub":"unit-test","iss":"https://other.test"}`),
+			ExpectedStatus:    http.StatusUnauthorized,
+			ExpectedChallenge: `Bearer realm="go-http-server", error="invalid_token", error_description="invalid issuer"`,
+			ExpectedBody:      "invalid issuer",
+			Description:       "wrong issuer",
+		},
+		{
+			Authorization:     "Bearer " + signTestToken("unit-test-secret", `{"sub":"unit-test","iss":"https://issuer.test"}`),
+			ExpectedStatus:    http.StatusOK,
+			ExpectedChallenge: "",
+			ExpectedBody:      "&#34;sub&#34;:&#34;unit-test&#34;",
+			Description:       "valid token claims are echoed",
+		},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+		if len(tc.Authorization) > 0 {
+			request.Header.Set(server.HttpHeader_Authorization, tc.Authorization)
+		}
+
+		handler(recorder, request)
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+		assert.Equal(tc.ExpectedChallenge, recorder.Header().Get(server.HttpHeader_WWWAuthenticate), tc.Description)
+		assert.True(strings.Contains(recorder.Body.String(), tc.ExpectedBody), "%s: %s", tc.Description, recorder.Body.String())
+	}
+}
+
+func Test_AuthenticateFailsClosed(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := config.Settings{}
+	cfg.JWT.PEMDirectory = filepath.Join(t.TempDir(), "missing")
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+
+	recorder := httptest.NewRecorder()
+	svc.Authenticate(svc.RequestProcessor)(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	assert.Equal(http.StatusUnauthorized, recorder.Code, "keys failed to load, nothing is let through")
+}
+
+func Test_AuthenticatePreflight(t *testing.T) {
+	assert := assert.New(t)
+
+	directory := t.TempDir()
+	assert.Nil(os.WriteFile(filepath.Join(directory, "unit.secret"), []byte("unit-test-secret"), 0600))
+
+	cfg := config.Settings{}
+	cfg.JWT.PEMDirectory = directory
+	cfg.Routes = []config.Route{
+		{PathPrefix: "/public/", CORS: &config.CORS{AllowedOrigins: []string{"*"}}},
+		{PathPrefix: "/private/", Response: &config.CannedResponse{Status: http.StatusOK, Body: "private"}},
+	}
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+	handler := svc.Handler()
+
+	testCases := []struct {
+		Path           string
+		ExpectedStatus int
+		Description    string
+	}{
+		{Path: "/public/data", ExpectedStatus: http.StatusNoContent, Description: "answered by the route CORS policy"},
+		{Path: "/private/data", ExpectedStatus: http.StatusUnauthorized, Description: "no CORS policy, preflight is not let through"},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodOptions, "http://localhost"+tc.Path, nil)
+		request.Header.Set(server.HttpHeader_Origin, "https://app.example.test")
+		request.Header.Set(server.HttpHeader_AccessControlRequestMethod, http.MethodGet)
+
+		handler.ServeHTTP(recorder, request)
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+	}
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/http/auth"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+// httpbin style behavior endpoints, used by client test suites that can't reach the public httpbin
+
+const (
+	MaxRedirects int = 100
+
+	HttpHeader_Location        = "Location"
+	HttpHeader_Authorization   = "Authorization"
+	HttpHeader_WWWAuthenticate = "WWW-Authenticate"
+
+	BasicAuthRealm = "go-http-server"
+)
+
+// WriteNegotiatedResponse - write data as JSON, plain text or HTML (default) based on the Accept header
+func (s *Server) WriteNegotiatedResponse(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request, httpStatusCode int, title string, data map[string]interface{}) {
+	method := "server.writeNegotiatedResponse"
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, shared.KeyHTTPResponseStatusCode, httpStatusCode)).Debugf("%s entering", method)
+
+	shared.AddUniversalHeaders(ctx, responseWriter, s.serviceName)
+
+	var err error
+
+	switch shared.NegotiateContentType(request, shared.MediaType_TextHtml, shared.MediaType_ApplicationJSON, shared.MediaType_TextPlain) {
+	case shared.MediaType_ApplicationJSON:
+		responseWriter.Header().Set(shared.HttpHeader_ContentType, shared.ContentType_ApplicationJSON)
+		s.WriteHeader(ctx, responseWriter, httpStatusCode)
+		err = shared.WriteJSON(ctx, responseWriter, data)
+	case shared.MediaType_TextPlain:
+		responseWriter.Header().Set(shared.HttpHeader_ContentType, shared.ContentType_TextPlain)
+		s.WriteHeader(ctx, responseWriter, httpStatusCode)
+		_, err = responseWriter.Write([]byte(formatData(data, "%s: %v\n", false)))
+	default:
+		responseWriter.Header().Set(shared.HttpHeader_ContentType, shared.ContentType_TextHtml)
+		s.WriteHeader(ctx, responseWriter, httpStatusCode)
+		_, err = responseWriter.Write([]byte(s.RenderPage("", s.NewTemplateData(ctx, request, httpStatusCode, "", title, formatData(data, "%s: %v<br/>", true)))))
+	}
+
+	if err != nil {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s write error", method)
+	}
+}
+
+// formatData - data as sorted "key: value" lines
+func formatData(data map[string]interface{}, format string, escape bool) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	for _, key := range keys {
+		line := fmt.Sprintf(format, key, data[key])
+		if escape {
+			line = fmt.Sprintf(format, html.EscapeString(key), html.EscapeString(fmt.Sprint(data[key])))
+		}
+		builder.WriteString(line)
+	}
+
+	return builder.String()
+}
+
+// writeRedirect - redirect with a negotiated body
+func (s *Server) writeRedirect(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request, httpStatusCode int, location string) {
+	responseWriter.Header().Set(HttpHeader_Location, location)
+	s.WriteNegotiatedResponse(ctx, responseWriter, request, httpStatusCode, http.StatusText(httpStatusCode), map[string]interface{}{
+		"status":   httpStatusCode,
+		"location": location,
+	})
+}
+
+// writeBadRequest - 400 with a negotiated body
+func (s *Server) writeBadRequest(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request, reason string) {
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, reason)).Warnf("server.writeBadRequest %s", reason)
+	s.WriteNegotiatedResponse(ctx, responseWriter, request, http.StatusBadRequest, http.StatusText(http.StatusBadRequest), map[string]interface{}{
+		"status": http.StatusBadRequest,
+		"error":  reason,
+	})
+}
+
+// RedirectProcessor - /redirect/{n}, redirects n times before landing on /
+func (s *Server) RedirectProcessor(responseWriter http.ResponseWriter, request *http.Request) {
+	method := "server.redirectProcessor"
+	ctx := shared.CreateRequestContext(request, method)
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s entering", method)
+
+	count, err := strconv.Atoi(strings.TrimPrefix(request.URL.Path, "/redirect/"))
+	if err != nil || count < 1 || count > MaxRedirects {
+		s.writeBadRequest(ctx, responseWriter, request, fmt.Sprintf("redirect count must be 1 - %d", MaxRedirects))
+		return
+	}
+
+	location := "/"
+	if count > 1 {
+		location = fmt.Sprintf("/redirect/%d", count-1)
+	}
+
+	s.writeRedirect(ctx, responseWriter, request, http.StatusFound, location)
+}
+
+// RedirectToProcessor - /redirect-to?url=&status=, redirects to url with status (302 default)
+func (s *Server) RedirectToProcessor(responseWriter http.ResponseWriter, request *http.Request) {
+	method := "server.redirectToProcessor"
+	ctx := shared.CreateRequestContext(request, method)
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s entering", method)
+
+	query := request.URL.Query()
+
+	location := query.Get("url")
+	if len(location) == 0 {
+		s.writeBadRequest(ctx, responseWriter, request, "url is required")
+		return
+	}
+
+	if _, err := url.Parse(location); err != nil {
+		s.writeBadRequest(ctx, responseWriter, request, "url is invalid")
+		return
+	}
+
+	httpStatusCode := http.StatusFound
+	if value := query.Get("status"); len(value) > 0 {
+		code, err := strconv.Atoi(value)
+		if err != nil || code < 300 || code > 399 {
+			s.writeBadRequest(ctx, responseWriter, request, "status must be 300 - 399")
+			return
+		}
+		httpStatusCode = code
+	}
+
+	s.writeRedirect(ctx, responseWriter, request, httpStatusCode, location)
+}
+
+// CookiesProcessor - /cookies lists request cookies, /cookies/set?name=value sets and /cookies/delete?name deletes, both redirect to /cookies
+func (s *Server) CookiesProcessor(responseWriter http.ResponseWriter, request *http.Request) {
+	method := "server.cookiesProcessor"
+	ctx := shared.CreateRequestContext(request, method)
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s entering", method)
+
+	switch strings.TrimSuffix(request.URL.Path, "/") {
+	case "/cookies":
+		cookies := map[string]interface{}{}
+		for _, cookie := range request.Cookies() {
+			cookies[cookie.Name] = cookie.Value
+		}
+		s.WriteNegotiatedResponse(ctx, responseWriter, request, http.StatusOK, "Cookies", cookies)
+	case "/cookies/set":
+		for name, values := range request.URL.Query() {
+			http.SetCookie(responseWriter, &http.Cookie{Name: name, Value: values[0], Path: "/"})
+		}
+		s.writeRedirect(ctx, responseWriter, request, http.StatusFound, "/cookies")
+	case "/cookies/delete":
+		for name := range request.URL.Query() {
+			http.SetCookie(responseWriter, &http.Cookie{Name: name, Value: "", Path: "/", MaxAge: -1, Expires: time.Unix(0, 0)})
+		}
+		s.writeRedirect(ctx, responseWriter, request, http.StatusFound, "/cookies")
+	default:
+		s.WriteNegotiatedResponse(ctx, responseWriter, request, http.StatusNotFound, http.StatusText(http.StatusNotFound), map[string]interface{}{
+			"status": http.StatusNotFound,
+		})
+	}
+}
+
+// BasicAuthProcessor - /basic-auth/{user}/{pass}, 200 when the request's basic credentials match, otherwise 401
+func (s *Server) BasicAuthProcessor(responseWriter http.ResponseWriter, request *http.Request) {
+	method := "server.basicAuthProcessor"
+	ctx := shared.CreateRequestContext(request, method)
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s entering", method)
+
+	parts := strings.SplitN(strings.TrimPrefix(request.URL.Path, "/basic-auth/"), "/", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		s.writeBadRequest(ctx, responseWriter, request, "expected /basic-auth/{user}/{pass}")
+		return
+	}
+
+	user, pass, ok := request.BasicAuth()
+	if !ok || user != parts[0] || pass != parts[1] {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeError, true)).Infof("%s basic auth failed", method)
+		responseWriter.Header().Set(HttpHeader_WWWAuthenticate, fmt.Sprintf("Basic realm=%q", BasicAuthRealm))
+		s.WriteNegotiatedResponse(ctx, responseWriter, request, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), map[string]interface{}{
+			"authenticated": false,
+		})
+		return
+	}
+
+	s.WriteNegotiatedResponse(ctx, responseWriter, request, http.StatusOK, "Authenticated", map[string]interface{}{
+		"authenticated": true,
+		"user":          user,
+	})
+}
+
+// BearerProcessor - /bearer, 200 when the request has a bearer token, otherwise 401
+func (s *Server) BearerProcessor(responseWriter http.ResponseWriter, request *http.Request) {
+	method := "server.bearerProcessor"
+	ctx := shared.CreateRequestContext(request, method)
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s entering", method)
+
+	token, err := auth.BearerToken(request)
+	if err != nil {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeError, true, shared.KeyErrorMessage, err.Error())).Infof("%s bearer token missing", method)
+		responseWriter.Header().Set(HttpHeader_WWWAuthenticate, "Bearer")
+		s.WriteNegotiatedResponse(ctx, responseWriter, request, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized), map[string]interface{}{
+			"authenticated": false,
+		})
+		return
+	}
+
+	s.WriteNegotiatedResponse(ctx, responseWriter, request, http.StatusOK, "Authenticated", map[string]interface{}{
+		"authenticated": true,
+		"token":         token,
+	})
+}
+
+// StatusProcessor - /status/{codes}, responds with a status picked at random from the comma separated codes,
+// 1xx codes are rejected because net/http always follows an informational response with a final one
+func (s *Server) StatusProcessor(responseWriter http.ResponseWriter, request *http.Request) {
+	method := "server.statusProcessor"
+	ctx := shared.CreateRequestContext(request, method)
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s entering", method)
+
+	codes := make([]int, 0)
+	for _, value := range strings.Split(strings.TrimPrefix(request.URL.Path, "/status/"), ",") {
+		code, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || code < 200 || code > 599 {
+			s.writeBadRequest(ctx, responseWriter, request, fmt.Sprintf("invalid status code '%s'", value))
+			return
+		}
+		codes = append(codes, code)
+	}
+
+	httpStatusCode := codes[rand.Intn(len(codes))]
+
+	switch {
+	case httpStatusCode >= 300 && httpStatusCode < 400:
+		responseWriter.Header().Set(HttpHeader_Location, "/redirect/1")
+	case httpStatusCode == http.StatusUnauthorized:
+		responseWriter.Header().Set(HttpHeader_WWWAuthenticate, fmt.Sprintf("Basic realm=%q", BasicAuthRealm))
+	}
+
+	if httpStatusCode == http.StatusNoContent || httpStatusCode == http.StatusNotModified || request.Method == http.MethodHead {
+		// no body allowed
+		shared.AddUniversalHeaders(ctx, responseWriter, s.serviceName)
+		s.WriteHeader(ctx, responseWriter, httpStatusCode)
+		return
+	}
+
+	// configured error pages are served as is so they can be previewed
+	if s.routing().errorPages.match(httpStatusCode) != nil {
+		httpStatusCode, httpStatusMessage, htmlMessage := s.CreateRequestResponseDetails(ctx, request, httpStatusCode, "")
+		s.DoErrorResponse(ctx, responseWriter, request, httpStatusCode, htmlMessage, errors.New(httpStatusMessage))
+		return
+	}
+
+	s.WriteNegotiatedResponse(ctx, responseWriter, request, httpStatusCode, http.StatusText(httpStatusCode), map[string]interface{}{
+		"status": httpStatusCode,
+		"reason": http.StatusText(httpStatusCode),
+	})
+}
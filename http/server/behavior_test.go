@@ -0,0 +1,269 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/server"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+func createBehaviorTestServer() *server.Server {
+	svc := server.NewServer("TestServiceName", &config.Settings{}, nil)
+	svc.Init()
+	return svc
+}
+
+func decodeJSONBody(t *testing.T, recorder *httptest.ResponseRecorder) map[string]interface{} {
+	actual := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &actual), "body should be JSON")
+	return actual
+}
+
+func Test_RedirectProcessor(t *testing.T) {
+	assert := assert.New(t)
+	svc := createBehaviorTestServer()
+
+	testCases := []struct {
+		Path             string
+		ExpectedStatus   int
+		ExpectedLocation string
+		Description      string
+	}{
+		{
+			Path:             "/redirect/3",
+			ExpectedStatus:   http.StatusFound,
+			ExpectedLocation: "/redirect/2",
+			Description:      "redirect chain",
+		},
+		{
+			Path:             "/redirect/1",
+			ExpectedStatus:   http.StatusFound,
+			ExpectedLocation: "/",
+			Description:      "last redirect",
+		},
+		{
+			Path:             "/redirect/0",
+			ExpectedStatus:   http.StatusBadRequest,
+			ExpectedLocation: "",
+			Description:      "zero redirects",
+		},
+		{
+			Path:             "/redirect/abc",
+			ExpectedStatus:   http.StatusBadRequest,
+			ExpectedLocation: "",
+			Description:      "not a number",
+		},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		svc.RedirectProcessor(recorder, httptest.NewRequest(http.MethodGet, tc.Path, nil))
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+		assert.Equal(tc.ExpectedLocation, recorder.Header().Get(server.HttpHeader_Location), tc.Description)
+	}
+}
+
+func Test_RedirectToProcessor(t *testing.T) {
+	assert := assert.New(t)
+	svc := createBehaviorTestServer()
+
+	testCases := []struct {
+		Path             string
+		ExpectedStatus   int
+		ExpectedLocation string
+		Description      string
+	}{
+		{
+			Path:             "/redirect-to?url=http%3A%2F%2Fexample.test%2F",
+			ExpectedStatus:   http.StatusFound,
+			ExpectedLocation: "http://example.test/",
+			Description:      "default status",
+		},
+		{
+			Path:             "/redirect-to?url=%2Fother&status=307",
+			ExpectedStatus:   http.StatusTemporaryRedirect,
+			ExpectedLocation: "/other",
+			Description:      "explicit status",
+		},
+		{
+			Path:             "/redirect-to?url=%2Fother&status=200",
+			ExpectedStatus:   http.StatusBadRequest,
+			ExpectedLocation: "",
+			Description:      "non redirect status",
+		},
+		{
+			Path:             "/redirect-to",
+			ExpectedStatus:   http.StatusBadRequest,
+			ExpectedLocation: "",
+			Description:      "missing url",
+		},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		svc.RedirectToProcessor(recorder, httptest.NewRequest(http.MethodGet, tc.Path, nil))
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+		assert.Equal(tc.ExpectedLocation, recorder.Header().Get(server.HttpHeader_Location), tc.Description)
+	}
+}
+
+func Test_CookiesProcessor(t *testing.T) {
+	assert := assert.New(t)
+	svc := createBehaviorTestServer()
+
+	recorder := httptest.NewRecorder()
+	svc.CookiesProcessor(recorder, httptest.NewRequest(http.MethodGet, "/cookies/set?flavor=chocolate", nil))
+
+	assert.Equal(http.StatusFound, recorder.Code)
+	assert.Equal("/cookies", recorder.Header().Get(server.HttpHeader_Location))
+	cookies := recorder.Result().Cookies()
+	assert.Len(cookies, 1)
+	assert.Equal("flavor", cookies[0].Name)
+	assert.Equal("chocolate", cookies[0].Value)
+
+	recorder = httptest.NewRecorder()
+	svc.CookiesProcessor(recorder, httptest.NewRequest(http.MethodGet, "/cookies/delete?flavor", nil))
+
+	assert.Equal(http.StatusFound, recorder.Code)
+	cookies = recorder.Result().Cookies()
+	assert.Len(cookies, 1)
+	assert.Equal(-1, cookies[0].MaxAge)
+
+	recorder = httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/cookies", nil)
+	request.Header.Set(shared.HttpHeader_Accept, shared.MediaType_ApplicationJSON)
+	request.AddCookie(&http.Cookie{Name: "flavor", Value: "vanilla"})
+	svc.CookiesProcessor(recorder, request)
+
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Equal(shared.ContentType_ApplicationJSON, recorder.Header().Get(shared.HttpHeader_ContentType))
+	assert.Equal("vanilla", decodeJSONBody(t, recorder)["flavor"])
+}
+
+func Test_BasicAuthProcessor(t *testing.T) {
+	assert := assert.New(t)
+	svc := createBehaviorTestServer()
+
+	testCases := []struct {
+		User           string
+		Pass           string
+		ExpectedStatus int
+		Description    string
+	}{
+		{
+			User:           "user",
+			Pass:           "pass",
+			ExpectedStatus: http.StatusOK,
+			Description:    "matching credentials",
+		},
+		{
+			User:           "user",
+			Pass:           "wrong",
+			ExpectedStatus: http.StatusUnauthorized,
+			Description:    "wrong password",
+		},
+		{
+			User:           "",
+			Pass:           "",
+			ExpectedStatus: http.StatusUnauthorized,
+			Description:    "no credentials",
+		},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/basic-auth/user/pass", nil)
+		request.Header.Set(shared.HttpHeader_Accept, shared.MediaType_ApplicationJSON)
+		if len(tc.User) > 0 {
+			request.SetBasicAuth(tc.User, tc.Pass)
+		}
+
+		svc.BasicAuthProcessor(recorder, request)
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+		assert.Equal(tc.ExpectedStatus == http.StatusOK, decodeJSONBody(t, recorder)["authenticated"], tc.Description)
+		if tc.ExpectedStatus == http.StatusUnauthorized {
+			assert.Contains(recorder.Header().Get(server.HttpHeader_WWWAuthenticate), "Basic", tc.Description)
+		}
+	}
+}
+
+func Test_BearerProcessor(t *testing.T) {
+	assert := assert.New(t)
+	svc := createBehaviorTestServer()
+
+	recorder := httptest.NewRecorder()
+	svc.BearerProcessor(recorder, httptest.NewRequest(http.MethodGet, "/bearer", nil))
+
+	assert.Equal(http.StatusUnauthorized, recorder.Code)
+	assert.Equal("Bearer", recorder.Header().Get(server.HttpHeader_WWWAuthenticate))
+
+	recorder = httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/bearer", nil)
+	request.Header.Set(server.HttpHeader_Authorization, "Bearer abc123")
+	request.Header.Set(shared.HttpHeader_Accept, shared.MediaType_TextPlain)
+	svc.BearerProcessor(recorder, request)
+
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Equal(shared.ContentType_TextPlain, recorder.Header().Get(shared.HttpHeader_ContentType))
+	assert.Contains(recorder.Body.String(), "token: abc123")
+}
+
+func Test_StatusProcessor(t *testing.T) {
+	assert := assert.New(t)
+	svc := createBehaviorTestServer()
+
+	testCases := []struct {
+		Path        string
+		Expected    []int
+		Description string
+	}{
+		{
+			Path:        "/status/418",
+			Expected:    []int{http.StatusTeapot},
+			Description: "single code",
+		},
+		{
+			Path:        "/status/200,500",
+			Expected:    []int{http.StatusOK, http.StatusInternalServerError},
+			Description: "random code",
+		},
+		{
+			Path:        "/status/204",
+			Expected:    []int{http.StatusNoContent},
+			Description: "no content",
+		},
+		{
+			Path:        "/status/abc",
+			Expected:    []int{http.StatusBadRequest},
+			Description: "invalid code",
+		},
+		{
+			Path:        "/status/700",
+			Expected:    []int{http.StatusBadRequest},
+			Description: "out of range code",
+		},
+		{
+			Path:        "/status/102",
+			Expected:    []int{http.StatusBadRequest},
+			Description: "informational code",
+		},
+	}
+
+	for _, tc := range testCases {
+		for idx := 0; idx < 10; idx++ {
+			recorder := httptest.NewRecorder()
+			svc.StatusProcessor(recorder, httptest.NewRequest(http.MethodGet, tc.Path, nil))
+
+			assert.Contains(tc.Expected, recorder.Code, tc.Description)
+		}
+	}
+}
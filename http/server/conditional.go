@@ -0,0 +1,212 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+// Conditional (If-None-Match, If-Modified-Since, If-Match, If-Unmodified-Since) and range (Range, If-Range) requests
+// are handled by http.ServeContent, these types give it a strong ETag, a Last-Modified time and a seekable body.
+
+const (
+	MaxSyntheticBytes int64 = 100 << 20 // 100 MB
+
+	HttpHeader_ETag = "ETag"
+
+	ContentType_OctetStream = "application/octet-stream"
+
+	syntheticAlphabet = "abcdefghijklmnopqrstuvwxyz"
+)
+
+// cannedResponse is a loaded config.CannedResponse
+type cannedResponse struct {
+	status      int
+	contentType string
+	body        []byte
+	headers     map[string]string
+	etag        string
+	modified    time.Time
+}
+
+func newCannedResponse(cfg *config.CannedResponse, started time.Time) (*cannedResponse, error) {
+	c := cannedResponse{
+		status:      cfg.Status,
+		contentType: cfg.ContentType,
+		body:        []byte(cfg.Body),
+		headers:     cfg.Headers,
+		modified:    started,
+	}
+
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if c.status < 200 || c.status > 599 {
+		return nil, fmt.Errorf("invalid canned response status %d", c.status)
+	}
+
+	if len(cfg.BodyFile) > 0 {
+		body, err := os.ReadFile(cfg.BodyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.body = body
+	}
+
+	if len(cfg.LastModified) > 0 {
+		modified, err := time.Parse(time.RFC3339, cfg.LastModified)
+		if err != nil {
+			return nil, fmt.Errorf("invalid canned response lastModified '%s'", cfg.LastModified)
+		}
+		c.modified = modified
+	}
+
+	sum := sha256.Sum256(c.body)
+	c.etag = fmt.Sprintf("%q", hex.EncodeToString(sum[:8]))
+
+	return &c, nil
+}
+
+// newCannedResponses - load the route canned responses keyed by their config
+func newCannedResponses(cfg *config.Settings, started time.Time) (map[*config.CannedResponse]*cannedResponse, error) {
+	responses := make(map[*config.CannedResponse]*cannedResponse)
+
+	err := eachRoute(cfg, func(route *config.Route) error {
+		if route.Response == nil || responses[route.Response] != nil {
+			return nil
+		}
+		response, err := newCannedResponse(route.Response, started)
+		if err != nil {
+			return fmt.Errorf("route '%s': %w", route.PathPrefix, err)
+		}
+		responses[route.Response] = response
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// ServeCannedResponse - write the route's canned response, 200 responses honor conditional and range headers
+func (s *Server) ServeCannedResponse(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request, route *config.Route) {
+	method := "server.serveCannedResponse"
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s entering", method)
+
+	s.serveCannedResponse(ctx, responseWriter, request, route, s.routing().cannedResponses[route.Response])
+}
+
+// serveCannedResponse - write canned, a 500 when the route's canned response failed to load
+func (s *Server) serveCannedResponse(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request, route *config.Route, canned *cannedResponse) {
+	method := "server.serveCannedResponse"
+
+	if canned == nil {
+		httpStatusCode, httpStatusMessage, htmlMessage := s.CreateRequestResponseDetails(ctx, request, http.StatusInternalServerError, fmt.Sprintf("canned response for '%s' unavailable", route.PathPrefix))
+		s.DoErrorResponse(ctx, responseWriter, request, httpStatusCode, htmlMessage, errors.New(httpStatusMessage))
+		return
+	}
+
+	shared.AddUniversalHeaders(ctx, responseWriter, s.serviceName)
+
+	for key, value := range canned.headers {
+		responseWriter.Header().Set(key, value)
+	}
+
+	if len(canned.contentType) > 0 {
+		responseWriter.Header().Set(shared.HttpHeader_ContentType, canned.contentType)
+	}
+
+	if canned.status != http.StatusOK {
+		s.WriteHeader(ctx, responseWriter, canned.status)
+		if request.Method != http.MethodHead {
+			if _, err := responseWriter.Write(canned.body); err != nil {
+				log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s write error", method)
+			}
+		}
+		return
+	}
+
+	responseWriter.Header().Set(HttpHeader_ETag, canned.etag)
+
+	http.ServeContent(responseWriter, request, "", canned.modified, bytes.NewReader(canned.body))
+}
+
+// BytesProcessor - /bytes/{n}, n bytes of a repeating a-z pattern with conditional and range support
+func (s *Server) BytesProcessor(responseWriter http.ResponseWriter, request *http.Request) {
+	method := "server.bytesProcessor"
+	ctx := shared.CreateRequestContext(request, method)
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s entering", method)
+
+	size, err := strconv.ParseInt(strings.TrimPrefix(request.URL.Path, "/bytes/"), 10, 64)
+	if err != nil || size < 0 || size > MaxSyntheticBytes {
+		s.writeBadRequest(ctx, responseWriter, request, fmt.Sprintf("byte count must be 0 - %d", MaxSyntheticBytes))
+		return
+	}
+
+	shared.AddUniversalHeaders(ctx, responseWriter, s.serviceName)
+	responseWriter.Header().Set(shared.HttpHeader_ContentType, ContentType_OctetStream)
+	// the body is fully determined by its size
+	responseWriter.Header().Set(HttpHeader_ETag, fmt.Sprintf("\"bytes-%d\"", size))
+
+	http.ServeContent(responseWriter, request, "", s.started, &patternReader{size: size})
+}
+
+// patternReader is a seekable body of size bytes of a repeating a-z pattern, nothing is allocated up front
+type patternReader struct {
+	size   int64
+	offset int64
+}
+
+func (p *patternReader) Read(buffer []byte) (int, error) {
+	if p.offset >= p.size {
+		return 0, io.EOF
+	}
+
+	remaining := p.size - p.offset
+	if int64(len(buffer)) > remaining {
+		buffer = buffer[:remaining]
+	}
+
+	for idx := range buffer {
+		buffer[idx] = syntheticAlphabet[(p.offset+int64(idx))%int64(len(syntheticAlphabet))]
+	}
+
+	p.offset += int64(len(buffer))
+
+	return len(buffer), nil
+}
+
+func (p *patternReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += p.offset
+	case io.SeekEnd:
+		offset += p.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	p.offset = offset
+
+	return offset, nil
+}
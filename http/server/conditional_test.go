@@ -0,0 +1,243 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/server"
+)
+
+func createConditionalTestServer() *server.Server {
+	cfg := config.Settings{}
+	cfg.Routes = []config.Route{
+		{
+			PathPrefix: "/canned",
+			Response: &config.CannedResponse{
+				ContentType:  "text/plain",
+				Body:         "0123456789abcdefghij",
+				LastModified: "2022-01-02T03:04:05Z",
+				Headers:      map[string]string{"Cache-Control": "max-age=60"},
+			},
+		},
+		{
+			PathPrefix: "/gone",
+			Response:   &config.CannedResponse{Status: http.StatusGone, Body: "gone"},
+		},
+	}
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+
+	return svc
+}
+
+func Test_CannedConditionalRequests(t *testing.T) {
+	assert := assert.New(t)
+	svc := createConditionalTestServer()
+
+	// prime the validators
+	recorder := httptest.NewRecorder()
+	svc.RequestProcessor(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/canned", nil))
+
+	etag := recorder.Header().Get(server.HttpHeader_ETag)
+	lastModified := recorder.Header().Get("Last-Modified")
+
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Equal("0123456789abcdefghij", recorder.Body.String())
+	assert.True(strings.HasPrefix(etag, `"`), "strong etag")
+	assert.Equal("Sun, 02 Jan 2022 03:04:05 GMT", lastModified)
+	assert.Equal("bytes", recorder.Header().Get("Accept-Ranges"))
+	assert.Equal("max-age=60", recorder.Header().Get("Cache-Control"))
+
+	testCases := []struct {
+		Headers        map[string]string
+		ExpectedStatus int
+		ExpectedBody   string
+		ExpectedRange  string
+		Description    string
+	}{
+		{
+			Headers:        map[string]string{"If-None-Match": etag},
+			ExpectedStatus: http.StatusNotModified,
+			Description:    "If-None-Match matches",
+		},
+		{
+			Headers:        map[string]string{"If-None-Match": `"other", W/` + etag},
+			ExpectedStatus: http.StatusNotModified,
+			Description:    "If-None-Match uses weak comparison",
+		},
+		{
+			Headers:        map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified},
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "0123456789abcdefghij",
+			Description:    "If-None-Match takes precedence over If-Modified-Since",
+		},
+		{
+			Headers:        map[string]string{"If-Modified-Since": lastModified},
+			ExpectedStatus: http.StatusNotModified,
+			Description:    "If-Modified-Since not modified",
+		},
+		{
+			Headers:        map[string]string{"If-Modified-Since": "Sat, 01 Jan 2022 00:00:00 GMT"},
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "0123456789abcdefghij",
+			Description:    "If-Modified-Since modified",
+		},
+		{
+			Headers:        map[string]string{"If-Match": `"other"`},
+			ExpectedStatus: http.StatusPreconditionFailed,
+			Description:    "If-Match mismatch",
+		},
+		{
+			Headers:        map[string]string{"If-Match": etag, "Range": "bytes=0-4"},
+			ExpectedStatus: http.StatusPartialContent,
+			ExpectedBody:   "01234",
+			ExpectedRange:  "bytes 0-4/20",
+			Description:    "If-Match with range",
+		},
+		{
+			Headers:        map[string]string{"Range": "bytes=-5"},
+			ExpectedStatus: http.StatusPartialContent,
+			ExpectedBody:   "fghij",
+			ExpectedRange:  "bytes 15-19/20",
+			Description:    "suffix range",
+		},
+		{
+			Headers:        map[string]string{"Range": "bytes=30-40"},
+			ExpectedStatus: http.StatusRequestedRangeNotSatisfiable,
+			ExpectedRange:  "bytes */20",
+			Description:    "unsatisfiable range",
+		},
+		{
+			Headers:        map[string]string{"Range": "bytes=0-4", "If-Range": etag},
+			ExpectedStatus: http.StatusPartialContent,
+			ExpectedBody:   "01234",
+			ExpectedRange:  "bytes 0-4/20",
+			Description:    "If-Range current",
+		},
+		{
+			Headers:        map[string]string{"Range": "bytes=0-4", "If-Range": `"stale"`},
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "0123456789abcdefghij",
+			Description:    "If-Range stale sends the full body",
+		},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "http://localhost/canned", nil)
+		for key, value := range tc.Headers {
+			request.Header.Set(key, value)
+		}
+
+		svc.RequestProcessor(recorder, request)
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+		assert.Equal(tc.ExpectedRange, recorder.Header().Get("Content-Range"), tc.Description)
+		if len(tc.ExpectedBody) > 0 {
+			assert.Equal(tc.ExpectedBody, recorder.Body.String(), tc.Description)
+		}
+	}
+}
+
+func Test_CannedMultipartRange(t *testing.T) {
+	assert := assert.New(t)
+	svc := createConditionalTestServer()
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "http://localhost/canned", nil)
+	request.Header.Set("Range", "bytes=0-1,10-11")
+	svc.RequestProcessor(recorder, request)
+
+	assert.Equal(http.StatusPartialContent, recorder.Code)
+	assert.True(strings.HasPrefix(recorder.Header().Get("Content-Type"), "multipart/byteranges; boundary="))
+	assert.Contains(recorder.Body.String(), "Content-Range: bytes 0-1/20")
+	assert.Contains(recorder.Body.String(), "Content-Range: bytes 10-11/20")
+	assert.Contains(recorder.Body.String(), "Content-Type: text/plain")
+}
+
+func Test_CannedErrorStatus(t *testing.T) {
+	assert := assert.New(t)
+	svc := createConditionalTestServer()
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "http://localhost/gone", nil)
+	request.Header.Set("Range", "bytes=0-1")
+	svc.RequestProcessor(recorder, request)
+
+	assert.Equal(http.StatusGone, recorder.Code)
+	assert.Equal("gone", recorder.Body.String(), "ranges only apply to 200 responses")
+	assert.Equal("", recorder.Header().Get(server.HttpHeader_ETag))
+}
+
+func Test_BytesProcessor(t *testing.T) {
+	assert := assert.New(t)
+	svc := createConditionalTestServer()
+
+	testCases := []struct {
+		Path           string
+		Range          string
+		ExpectedStatus int
+		ExpectedBody   string
+		Description    string
+	}{
+		{
+			Path:           "/bytes/30",
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   "abcdefghijklmnopqrstuvwxyzabcd",
+			Description:    "full body",
+		},
+		{
+			Path:           "/bytes/30",
+			Range:          "bytes=25-27",
+			ExpectedStatus: http.StatusPartialContent,
+			ExpectedBody:   "zab",
+			Description:    "range across the pattern boundary",
+		},
+		{
+			Path:           "/bytes/30",
+			Range:          "bytes=30-",
+			ExpectedStatus: http.StatusRequestedRangeNotSatisfiable,
+			Description:    "range past the end",
+		},
+		{
+			Path:           "/bytes/-1",
+			ExpectedStatus: http.StatusBadRequest,
+			Description:    "negative size",
+		},
+		{
+			Path:           "/bytes/999999999999",
+			ExpectedStatus: http.StatusBadRequest,
+			Description:    "too large",
+		},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "http://localhost"+tc.Path, nil)
+		if len(tc.Range) > 0 {
+			request.Header.Set("Range", tc.Range)
+		}
+
+		svc.BytesProcessor(recorder, request)
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+		if len(tc.ExpectedBody) > 0 {
+			assert.Equal(tc.ExpectedBody, recorder.Body.String(), tc.Description)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "http://localhost/bytes/30", nil)
+	request.Header.Set("If-None-Match", `"bytes-30"`)
+	request.Header.Set("If-Modified-Since", time.Now().UTC().Add(time.Hour).Format(http.TimeFormat))
+	svc.BytesProcessor(recorder, request)
+
+	assert.Equal(http.StatusNotModified, recorder.Code)
+}
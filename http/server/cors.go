@@ -0,0 +1,328 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+const (
+	HttpHeader_Origin                        = "Origin"
+	HttpHeader_Vary                          = "Vary"
+	HttpHeader_AccessControlRequestMethod    = "Access-Control-Request-Method"
+	HttpHeader_AccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	HttpHeader_AccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	HttpHeader_AccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	HttpHeader_AccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	HttpHeader_AccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	HttpHeader_AccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	HttpHeader_AccessControlMaxAge           = "Access-Control-Max-Age"
+
+	corsAny         = "*"
+	corsRegexPrefix = "^"
+)
+
+var defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+type corsWildcard struct {
+	prefix string
+	suffix string
+}
+
+// corsPolicy is a compiled config.CORS
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	wildcards   []corsWildcard
+	regexes     []*regexp.Regexp
+	methods     map[string]bool
+	allowMethod string
+	anyHeader   bool
+	headers     map[string]bool
+	allowHeader string
+	expose      string
+	credentials bool
+	maxAge      int
+}
+
+func newCORSPolicy(cfg *config.CORS) (*corsPolicy, error) {
+	p := corsPolicy{
+		origins:     make(map[string]bool),
+		methods:     make(map[string]bool),
+		headers:     make(map[string]bool),
+		expose:      strings.Join(cfg.ExposedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+		maxAge:      cfg.MaxAge,
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		switch {
+		case origin == corsAny:
+			p.anyOrigin = true
+		case strings.HasPrefix(origin, corsRegexPrefix):
+			regex, err := regexp.Compile(origin)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cors origin regex '%s': %w", origin, err)
+			}
+			p.regexes = append(p.regexes, regex)
+		case strings.Count(origin, corsAny) == 1:
+			parts := strings.SplitN(strings.ToLower(origin), corsAny, 2)
+			p.wildcards = append(p.wildcards, corsWildcard{prefix: parts[0], suffix: parts[1]})
+		case strings.Contains(origin, corsAny):
+			return nil, fmt.Errorf("invalid cors origin '%s'", origin)
+		default:
+			p.origins[strings.ToLower(origin)] = true
+		}
+	}
+
+	methods := make([]string, 0)
+	for _, m := range cfg.AllowedMethods {
+		methods = append(methods, strings.ToUpper(m))
+	}
+	if len(methods) == 0 {
+		methods = append(methods, defaultCORSMethods...)
+	}
+	for _, m := range methods {
+		p.methods[m] = true
+	}
+	p.allowMethod = strings.Join(methods, ", ")
+
+	for _, header := range cfg.AllowedHeaders {
+		if header == corsAny {
+			p.anyHeader = true
+			continue
+		}
+		p.headers[strings.ToLower(header)] = true
+	}
+	p.allowHeader = strings.Join(cfg.AllowedHeaders, ", ")
+
+	return &p, nil
+}
+
+func (p *corsPolicy) allowsOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+
+	lower := strings.ToLower(origin)
+
+	if p.origins[lower] {
+		return true
+	}
+
+	for _, wildcard := range p.wildcards {
+		if len(lower) > len(wildcard.prefix)+len(wildcard.suffix) && strings.HasPrefix(lower, wildcard.prefix) && strings.HasSuffix(lower, wildcard.suffix) {
+			return true
+		}
+	}
+
+	for _, regex := range p.regexes {
+		if regex.MatchString(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// disallowedHeader - first requested header that is not allowed, empty when all are allowed
+func (p *corsPolicy) disallowedHeader(requested string) string {
+	if p.anyHeader {
+		return ""
+	}
+
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if len(header) > 0 && !p.headers[header] {
+			return header
+		}
+	}
+
+	return ""
+}
+
+// allowOrigin - Access-Control-Allow-Origin value, the request origin is echoed unless any origin is allowed without credentials
+func (p *corsPolicy) allowOrigin(origin string) string {
+	if p.anyOrigin && !p.credentials {
+		return corsAny
+	}
+
+	return origin
+}
+
+// isPreflight - CORS preflight request
+func isPreflight(request *http.Request) bool {
+	return request.Method == http.MethodOptions && len(request.Header.Get(HttpHeader_Origin)) > 0 && len(request.Header.Get(HttpHeader_AccessControlRequestMethod)) > 0
+}
+
+// newCORSPolicies - compile the default, virtual host and route policies keyed by their config
+func newCORSPolicies(cfg *config.Settings) (map[*config.CORS]*corsPolicy, error) {
+	policies := make(map[*config.CORS]*corsPolicy)
+
+	add := func(cors *config.CORS) error {
+		if cors == nil || policies[cors] != nil {
+			return nil
+		}
+		policy, err := newCORSPolicy(cors)
+		if err != nil {
+			return err
+		}
+		policies[cors] = policy
+		return nil
+	}
+
+	if err := add(cfg.CORS); err != nil {
+		return nil, err
+	}
+
+	for idx := range cfg.VirtualHosts {
+		if err := add(cfg.VirtualHosts[idx].CORS); err != nil {
+			return nil, err
+		}
+	}
+
+	err := eachRoute(cfg, func(route *config.Route) error {
+		return add(route.CORS)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// corsPolicyFor - route policy, else virtual host policy, else default policy, nil when none is configured
+func (rt *routing) corsPolicyFor(vhost *VirtualHost, route *config.Route) *corsPolicy {
+	if len(rt.corsPolicies) == 0 {
+		return nil
+	}
+
+	if route != nil && route.CORS != nil {
+		return rt.corsPolicies[route.CORS]
+	}
+
+	if vhost != nil && vhost.Config.CORS != nil {
+		return rt.corsPolicies[vhost.Config.CORS]
+	}
+
+	if rt.config != nil && rt.config.CORS != nil {
+		return rt.corsPolicies[rt.config.CORS]
+	}
+
+	return nil
+}
+
+// applyCORS - add CORS response headers, true when the request was a preflight and has been answered
+func (s *Server) applyCORS(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request, policy *corsPolicy) bool {
+	method := "server.applyCORS"
+
+	origin := request.Header.Get(HttpHeader_Origin)
+	if policy == nil || len(origin) == 0 {
+		return false
+	}
+
+	header := responseWriter.Header()
+	header.Add(HttpHeader_Vary, HttpHeader_Origin)
+
+	preflight := isPreflight(request)
+	if preflight {
+		header.Add(HttpHeader_Vary, HttpHeader_AccessControlRequestMethod)
+		header.Add(HttpHeader_Vary, HttpHeader_AccessControlRequestHeaders)
+	}
+
+	reason := ""
+	requestMethod := strings.ToUpper(request.Header.Get(HttpHeader_AccessControlRequestMethod))
+	requestHeaders := request.Header.Get(HttpHeader_AccessControlRequestHeaders)
+
+	switch {
+	case !policy.allowsOrigin(origin):
+		reason = "origin not allowed"
+	case preflight && !policy.methods[requestMethod]:
+		reason = fmt.Sprintf("method '%s' not allowed", requestMethod)
+	case preflight && len(policy.disallowedHeader(requestHeaders)) > 0:
+		reason = fmt.Sprintf("header '%s' not allowed", policy.disallowedHeader(requestHeaders))
+	}
+
+	if len(reason) > 0 {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyCORSOrigin, origin, shared.KeyErrorMessage, reason)).Warnf("%s cors request rejected", method)
+
+		if preflight {
+			s.WriteNegotiatedResponse(ctx, responseWriter, request, http.StatusForbidden, http.StatusText(http.StatusForbidden), map[string]interface{}{
+				"status": http.StatusForbidden,
+				"error":  "cors " + reason,
+			})
+		}
+
+		return preflight
+	}
+
+	header.Set(HttpHeader_AccessControlAllowOrigin, policy.allowOrigin(origin))
+	if policy.credentials {
+		header.Set(HttpHeader_AccessControlAllowCredentials, "true")
+	}
+
+	if !preflight {
+		if len(policy.expose) > 0 {
+			header.Set(HttpHeader_AccessControlExposeHeaders, policy.expose)
+		}
+		return false
+	}
+
+	header.Set(HttpHeader_AccessControlAllowMethods, policy.allowMethod)
+
+	if policy.anyHeader {
+		if len(requestHeaders) > 0 {
+			header.Set(HttpHeader_AccessControlAllowHeaders, requestHeaders)
+		}
+	} else if len(policy.allowHeader) > 0 {
+		header.Set(HttpHeader_AccessControlAllowHeaders, policy.allowHeader)
+	}
+
+	if policy.maxAge > 0 {
+		header.Set(HttpHeader_AccessControlMaxAge, strconv.Itoa(policy.maxAge))
+	}
+
+	shared.AddUniversalHeaders(ctx, responseWriter, s.serviceName)
+	s.WriteHeader(ctx, responseWriter, http.StatusNoContent)
+
+	return true
+}
+
+// corsRequests - add CORS headers and answer preflights in front of the router, so static mounts and behavior routes are covered.
+// Route policies apply to the configured routes the catch all pattern serves, the health paths are left alone.
+func (s *Server) corsRequests(router *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		if s.metricsGroup(request.URL.Path) == MetricsGroupHealth {
+			router.ServeHTTP(responseWriter, request)
+			return
+		}
+
+		resolved, ok := s.requestRoutingFor(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		var route *config.Route
+		if _, pattern := router.Handler(request); pattern == "/" {
+			route, _ = s.MatchRoute(resolved.routes(), request)
+		}
+
+		ctx := shared.CreateRequestContext(request, "server.corsRequests")
+		if s.applyCORS(ctx, responseWriter, request, resolved.rt.corsPolicyFor(resolved.vhost, route)) {
+			if route != nil {
+				setMetricsRoute(request, route.PathPrefix)
+			}
+			return
+		}
+
+		router.ServeHTTP(responseWriter, request)
+	})
+}
@@ -0,0 +1,216 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/server"
+)
+
+func createCORSTestServer(t *testing.T) http.Handler {
+	directory := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(directory, "app.js"), []byte("console.log('app')"), 0600))
+
+	cfg := config.Settings{}
+	cfg.CORS = &config.CORS{
+		AllowedOrigins:   []string{"https://app.example.test", "https://*.tenant.test", "^https://preview-[0-9]+\\.example\\.test$"},
+		AllowedMethods:   []string{"GET", "put"},
+		AllowedHeaders:   []string{"Content-Type", "X-Request-Id"},
+		ExposedHeaders:   []string{"X-Transaction-Id"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}
+	cfg.Routes = []config.Route{
+		{PathPrefix: "/public/", CORS: &config.CORS{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}}},
+	}
+	cfg.Static = []config.Static{{PathPrefix: "/files/", Directory: directory}}
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+
+	return svc.Handler()
+}
+
+func Test_CORSPreflight(t *testing.T) {
+	assert := assert.New(t)
+	handler := createCORSTestServer(t)
+
+	testCases := []struct {
+		Path            string
+		Origin          string
+		Method          string
+		Headers         string
+		ExpectedStatus  int
+		ExpectedOrigin  string
+		ExpectedHeaders string
+		Description     string
+	}{
+		{
+			Path:            "/",
+			Origin:          "https://app.example.test",
+			Method:          "PUT",
+			Headers:         "content-type",
+			ExpectedStatus:  http.StatusNoContent,
+			ExpectedOrigin:  "https://app.example.test",
+			ExpectedHeaders: "Content-Type, X-Request-Id",
+			Description:     "exact origin",
+		},
+		{
+			Path:            "/",
+			Origin:          "https://a.tenant.test",
+			Method:          "GET",
+			ExpectedStatus:  http.StatusNoContent,
+			ExpectedOrigin:  "https://a.tenant.test",
+			ExpectedHeaders: "Content-Type, X-Request-Id",
+			Description:     "wildcard origin",
+		},
+		{
+			Path:           "/",
+			Origin:         "https://tenant.test",
+			Method:         "GET",
+			ExpectedStatus: http.StatusForbidden,
+			Description:    "wildcard does not match apex",
+		},
+		{
+			Path:            "/",
+			Origin:          "https://preview-42.example.test",
+			Method:          "GET",
+			ExpectedStatus:  http.StatusNoContent,
+			ExpectedOrigin:  "https://preview-42.example.test",
+			ExpectedHeaders: "Content-Type, X-Request-Id",
+			Description:     "regex origin",
+		},
+		{
+			Path:           "/",
+			Origin:         "https://evil.test",
+			Method:         "GET",
+			ExpectedStatus: http.StatusForbidden,
+			Description:    "unknown origin",
+		},
+		{
+			Path:           "/",
+			Origin:         "https://app.example.test",
+			Method:         "DELETE",
+			ExpectedStatus: http.StatusForbidden,
+			Description:    "method not allowed",
+		},
+		{
+			Path:           "/",
+			Origin:         "https://app.example.test",
+			Method:         "GET",
+			Headers:        "X-Other",
+			ExpectedStatus: http.StatusForbidden,
+			Description:    "header not allowed",
+		},
+		{
+			Path:            "/public/data",
+			Origin:          "https://evil.test",
+			Method:          "POST",
+			Headers:         "X-Other",
+			ExpectedStatus:  http.StatusNoContent,
+			ExpectedOrigin:  "*",
+			ExpectedHeaders: "X-Other",
+			Description:     "route policy overrides default",
+		},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodOptions, "http://localhost"+tc.Path, nil)
+		request.Header.Set(server.HttpHeader_Origin, tc.Origin)
+		request.Header.Set(server.HttpHeader_AccessControlRequestMethod, tc.Method)
+		if len(tc.Headers) > 0 {
+			request.Header.Set(server.HttpHeader_AccessControlRequestHeaders, tc.Headers)
+		}
+
+		handler.ServeHTTP(recorder, request)
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+		assert.Equal(tc.ExpectedOrigin, recorder.Header().Get(server.HttpHeader_AccessControlAllowOrigin), tc.Description)
+		assert.Equal(tc.ExpectedHeaders, recorder.Header().Get(server.HttpHeader_AccessControlAllowHeaders), tc.Description)
+		assert.Contains(recorder.Header().Values(server.HttpHeader_Vary), server.HttpHeader_Origin, tc.Description)
+	}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodOptions, "http://localhost/", nil)
+	request.Header.Set(server.HttpHeader_Origin, "https://app.example.test")
+	request.Header.Set(server.HttpHeader_AccessControlRequestMethod, "GET")
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal("GET, PUT", recorder.Header().Get(server.HttpHeader_AccessControlAllowMethods))
+	assert.Equal("true", recorder.Header().Get(server.HttpHeader_AccessControlAllowCredentials))
+	assert.Equal("600", recorder.Header().Get(server.HttpHeader_AccessControlMaxAge))
+}
+
+func Test_CORSActualRequest(t *testing.T) {
+	assert := assert.New(t)
+	handler := createCORSTestServer(t)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	request.Header.Set(server.HttpHeader_Origin, "https://app.example.test")
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Equal("https://app.example.test", recorder.Header().Get(server.HttpHeader_AccessControlAllowOrigin))
+	assert.Equal("X-Transaction-Id", recorder.Header().Get(server.HttpHeader_AccessControlExposeHeaders))
+	assert.Equal("", recorder.Header().Get(server.HttpHeader_AccessControlAllowMethods), "methods are only sent on preflight")
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "http://localhost/", nil)
+	request.Header.Set(server.HttpHeader_Origin, "https://evil.test")
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal(http.StatusOK, recorder.Code, "rejected origins still get the response, the browser blocks it")
+	assert.Equal("", recorder.Header().Get(server.HttpHeader_AccessControlAllowOrigin))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+
+	assert.Equal("", recorder.Header().Get(server.HttpHeader_Vary), "same origin requests are untouched")
+}
+
+func Test_CORSRouter(t *testing.T) {
+	assert := assert.New(t)
+	handler := createCORSTestServer(t)
+
+	testCases := []struct {
+		Method         string
+		Path           string
+		ExpectedStatus int
+		Description    string
+	}{
+		{Method: http.MethodOptions, Path: "/files/app.js", ExpectedStatus: http.StatusNoContent, Description: "static preflight"},
+		{Method: http.MethodGet, Path: "/files/app.js", ExpectedStatus: http.StatusOK, Description: "static request"},
+		{Method: http.MethodOptions, Path: "/status/200", ExpectedStatus: http.StatusNoContent, Description: "behavior preflight"},
+		{Method: http.MethodGet, Path: "/status/200", ExpectedStatus: http.StatusOK, Description: "behavior request"},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(tc.Method, "http://localhost"+tc.Path, nil)
+		request.Header.Set(server.HttpHeader_Origin, "https://app.example.test")
+		if tc.Method == http.MethodOptions {
+			request.Header.Set(server.HttpHeader_AccessControlRequestMethod, http.MethodGet)
+		}
+
+		handler.ServeHTTP(recorder, request)
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+		assert.Equal("https://app.example.test", recorder.Header().Get(server.HttpHeader_AccessControlAllowOrigin), tc.Description)
+	}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodOptions, "http://localhost"+server.DefaultLivenessPath, nil)
+	request.Header.Set(server.HttpHeader_Origin, "https://app.example.test")
+	request.Header.Set(server.HttpHeader_AccessControlRequestMethod, http.MethodGet)
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal("", recorder.Header().Get(server.HttpHeader_AccessControlAllowOrigin), "health paths are left alone")
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+// errorPage is a loaded config.ErrorPage
+type errorPage struct {
+	templateName string             // Template found in the template directory, reloads with it
+	page         *template.Template // Template loaded from a file
+	body         string
+	contentType  string
+	headers      map[string]string
+}
+
+// errorPages matches error statuses, exact statuses win over classes
+type errorPages struct {
+	statuses map[int]*errorPage
+	classes  map[int]*errorPage // keyed by status / 100
+}
+
+func newErrorPage(cfg config.ErrorPage, templates *templateSet) (*errorPage, error) {
+	e := errorPage{
+		body:        cfg.Body,
+		contentType: cfg.ContentType,
+		headers:     cfg.Headers,
+	}
+
+	if len(e.contentType) == 0 {
+		e.contentType = shared.ContentType_TextHtml
+	}
+
+	switch {
+	case len(cfg.Template) > 0 && templates != nil && templates.lookup(cfg.Template) != nil:
+		e.templateName = cfg.Template
+	case len(cfg.Template) > 0:
+		source, err := os.ReadFile(cfg.Template)
+		if err != nil {
+			return nil, err
+		}
+		page, err := compileTemplate(cfg.Template, string(source))
+		if err != nil {
+			return nil, err
+		}
+		e.page = page
+	case len(cfg.BodyFile) > 0:
+		body, err := os.ReadFile(cfg.BodyFile)
+		if err != nil {
+			return nil, err
+		}
+		e.body = string(body)
+	case len(cfg.Body) == 0:
+		return nil, fmt.Errorf("error page needs a template, body or bodyFile")
+	}
+
+	return &e, nil
+}
+
+// newErrorPages - load the error pages, keys are a 4xx/5xx status ("404") or class ("5xx")
+func newErrorPages(cfgs map[string]config.ErrorPage, templates *templateSet) (*errorPages, error) {
+	e := errorPages{
+		statuses: make(map[int]*errorPage),
+		classes:  make(map[int]*errorPage),
+	}
+
+	for key, cfg := range cfgs {
+		normalized := strings.ToLower(strings.TrimSpace(key))
+
+		page, err := newErrorPage(cfg, templates)
+		if err != nil {
+			return nil, fmt.Errorf("error page '%s': %w", key, err)
+		}
+
+		if len(normalized) == 3 && strings.HasSuffix(normalized, "xx") && (normalized[0] == '4' || normalized[0] == '5') {
+			e.classes[int(normalized[0]-'0')] = page
+			continue
+		}
+
+		status, err := strconv.Atoi(normalized)
+		if err != nil || status < 400 || status > 599 {
+			return nil, fmt.Errorf("invalid error page status '%s'", key)
+		}
+		e.statuses[status] = page
+	}
+
+	return &e, nil
+}
+
+// match - error page for status, nil when none is configured
+func (e *errorPages) match(status int) *errorPage {
+	if e == nil {
+		return nil
+	}
+
+	if page, found := e.statuses[status]; found {
+		return page
+	}
+
+	return e.classes[status/100]
+}
+
+// isTemplate - the page is rendered rather than a static body
+func (p *errorPage) isTemplate() bool {
+	return len(p.templateName) > 0 || p.page != nil
+}
+
+// applyHeaders - content type and custom headers of the error page
+func (p *errorPage) applyHeaders(responseWriter http.ResponseWriter) {
+	responseWriter.Header().Set(shared.HttpHeader_ContentType, p.contentType)
+
+	for key, value := range p.headers {
+		responseWriter.Header().Set(key, value)
+	}
+}
@@ -0,0 +1,149 @@
+package server_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/server"
+)
+
+func Test_ErrorPages(t *testing.T) {
+	assert := assert.New(t)
+
+	directory := createTemplateDirectory(t, map[string]string{
+		"default.html": `{{.Title}}|{{.Body}}`,
+		"404.html":     `missing {{.Request.Path}} ({{.Status}})`,
+		"broken.html":  `{{.Missing.Field}}`,
+	})
+
+	pageFile := filepath.Join(t.TempDir(), "5xx.html")
+	assert.Nil(os.WriteFile(pageFile, []byte(`<h1>{{.Status}} {{.StatusText}}</h1><p>{{.Reason}}</p>`), 0600))
+
+	cfg := config.Settings{}
+	cfg.Templates = config.Templates{Directory: directory}
+	cfg.ErrorPages = map[string]config.ErrorPage{
+		"404": {Template: "404.html", Headers: map[string]string{"X-Error-Page": "404"}},
+		"5xx": {Template: pageFile, Headers: map[string]string{"Cache-Control": "no-store"}},
+		"502": {Body: `{"error":"bad gateway"}`, ContentType: "application/json"},
+		"405": {Template: "broken.html"},
+	}
+	cfg.Static = []config.Static{{PathPrefix: "/files/", Directory: t.TempDir()}}
+	cfg.Routes = []config.Route{
+		{PathPrefix: "/api", Upstream: "missing"},
+		{PathPrefix: "/canned", Response: &config.CannedResponse{BodyFile: filepath.Join(t.TempDir(), "missing")}},
+	}
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+	handler := svc.Handler()
+
+	testCases := []struct {
+		Method              string
+		Target              string
+		ExpectedStatus      int
+		ExpectedBody        string
+		ExpectedContentType string
+		ExpectedHeader      string
+		ExpectedValue       string
+		Description         string
+	}{
+		{
+			Method:              http.MethodGet,
+			Target:              "/files/nothing.txt",
+			ExpectedStatus:      http.StatusNotFound,
+			ExpectedBody:        "missing /files/nothing.txt (404)",
+			ExpectedContentType: "text/html; charset=utf-8",
+			ExpectedHeader:      "X-Error-Page",
+			ExpectedValue:       "404",
+			Description:         "status template from the directory with request data",
+		},
+		{
+			Method:              http.MethodGet,
+			Target:              "/api/users",
+			ExpectedStatus:      http.StatusBadGateway,
+			ExpectedBody:        `{"error":"bad gateway"}`,
+			ExpectedContentType: "application/json",
+			Description:         "static body, status wins over class",
+		},
+		{
+			Method:              http.MethodGet,
+			Target:              "/canned",
+			ExpectedStatus:      http.StatusInternalServerError,
+			ExpectedBody:        "<h1>500 Internal Server Error</h1><p>canned response for &#39;/canned&#39; unavailable</p>",
+			ExpectedContentType: "text/html; charset=utf-8",
+			ExpectedHeader:      "Cache-Control",
+			ExpectedValue:       "no-store",
+			Description:         "class template from a file",
+		},
+		{
+			Method:              http.MethodGet,
+			Target:              "/status/503",
+			ExpectedStatus:      http.StatusServiceUnavailable,
+			ExpectedBody:        "<h1>503 Service Unavailable</h1><p></p>",
+			ExpectedContentType: "text/html; charset=utf-8",
+			ExpectedHeader:      "Cache-Control",
+			ExpectedValue:       "no-store",
+			Description:         "status endpoint previews error pages",
+		},
+		{
+			Method:              http.MethodPost,
+			Target:              "/files/nothing.txt",
+			ExpectedStatus:      http.StatusMethodNotAllowed,
+			ExpectedBody:        "Method Not Allowed|HTTP Status: 405 (method not allowed)",
+			ExpectedContentType: "text/html; charset=utf-8",
+			Description:         "failing template falls back to the default template",
+		},
+		{
+			Method:              http.MethodHead,
+			Target:              "/anything",
+			ExpectedStatus:      http.StatusBadRequest,
+			ExpectedBody:        "",
+			ExpectedContentType: "text/html; charset=utf-8",
+			Description:         "no error page configured",
+		},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(tc.Method, tc.Target, nil))
+		body, _ := io.ReadAll(recorder.Body)
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+		assert.Equal(tc.ExpectedBody, string(body), tc.Description)
+		assert.Equal(tc.ExpectedContentType, recorder.Header().Get("Content-Type"), tc.Description)
+		if len(tc.ExpectedHeader) > 0 {
+			assert.Equal(tc.ExpectedValue, recorder.Header().Get(tc.ExpectedHeader), tc.Description)
+		}
+	}
+}
+
+func Test_ErrorPagesInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		ErrorPages  map[string]config.ErrorPage
+		Description string
+	}{
+		{ErrorPages: map[string]config.ErrorPage{"200": {Body: "ok"}}, Description: "not an error status"},
+		{ErrorPages: map[string]config.ErrorPage{"3xx": {Body: "redirect"}}, Description: "not an error class"},
+		{ErrorPages: map[string]config.ErrorPage{"404": {}}, Description: "no body"},
+		{ErrorPages: map[string]config.ErrorPage{"404": {Template: filepath.Join(t.TempDir(), "missing.html")}}, Description: "missing template file"},
+	}
+
+	for _, tc := range testCases {
+		cfg := config.Settings{ErrorPages: tc.ErrorPages}
+
+		svc := server.NewServer("TestServiceName", &cfg, nil)
+		svc.Init()
+
+		_, _, htmlMessage := svc.CreateResponseDetails(http.StatusNotFound, "")
+		assert.Contains(htmlMessage, "HTTP Status: 404 (not found)", tc.Description)
+	}
+}
@@ -0,0 +1,215 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/server"
+	"github.com/mdonahue-godaddy/go-http-server/metrics"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/health"
+)
+
+func Test_ReadinessHealthChecks(t *testing.T) {
+	assert := assert.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	address := listener.Addr().String()
+
+	cfg := config.Settings{}
+	cfg.Health = config.Health{
+		UnhealthyThreshold: 1,
+		Checks: []config.HealthCheck{
+			{Name: "database", Type: "tcp", Target: address, Critical: true},
+			{Name: "cache", Type: "tcp", Target: "127.0.0.1:1"},
+		},
+	}
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+	handler := svc.Handler()
+
+	readiness := func() (int, string) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz/readinessZ67", nil))
+		return recorder.Code, recorder.Body.String()
+	}
+
+	status, body := readiness()
+	assert.Equal(http.StatusServiceUnavailable, status, "critical check pending")
+	assert.Contains(body, "database pending")
+
+	svc.GetHealthChecks().RunChecks(context.Background())
+	status, _ = readiness()
+	assert.Equal(http.StatusOK, status, "non critical failure only warns")
+
+	listener.Close()
+	svc.GetHealthChecks().RunChecks(context.Background())
+	status, body = readiness()
+	assert.Equal(http.StatusServiceUnavailable, status, "critical check failing")
+	assert.Contains(body, "database failing")
+
+	assert.Nil(svc.SetReadinessOverride(server.ReadinessUp))
+	status, _ = readiness()
+	assert.Equal(http.StatusOK, status, "forced up")
+}
+
+func Test_HealthCheckReport(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := config.Settings{}
+	cfg.Health = config.Health{Checks: []config.HealthCheck{{Name: "database", Type: "tcp", Target: "127.0.0.1:1", Critical: true}}}
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+	svc.GetHealthChecks().RunChecks(context.Background())
+	handler := svc.Handler()
+
+	request := httptest.NewRequest(http.MethodGet, "/healthz/readinessZ67", nil)
+	request.Header.Set("Accept", health.ContentType_HealthJSON)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	report := health.Report{}
+	assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &report))
+	assert.Equal(http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(health.ContentType_HealthJSON, recorder.Header().Get("Content-Type"))
+	assert.Equal(health.StatusFail, report.Status)
+	assert.Equal(health.Report{Status: health.StatusFail}, report, "service port report has only the status")
+	assert.NotContains(recorder.Body.String(), "127.0.0.1:1", "check output not exposed")
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz/livenessZ76?verbose", nil))
+	report = health.Report{}
+	assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &report))
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Equal(health.Report{Status: health.StatusWarn}, report, "alive with a failing dependency")
+
+	metricsMux := http.NewServeMux()
+	assert.Nil(metrics.StartHealthCheck(context.Background(), metricsMux, svc.HealthPaths(), svc.GetHealth()))
+	recorder = httptest.NewRecorder()
+	metricsMux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz/readinessZ67?verbose", nil))
+	report = health.Report{}
+	assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &report))
+	assert.Equal(health.StatusFail, report.Checks["database:responseTime"][0].Status, "metrics port report has the checks")
+
+	cfg.Health.DetailedReports = true
+	svc = server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+	svc.GetHealthChecks().RunChecks(context.Background())
+
+	recorder = httptest.NewRecorder()
+	svc.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz/readinessZ67?verbose", nil))
+	report = health.Report{}
+	assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &report))
+	assert.Equal(http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(health.StatusFail, report.Checks["database:responseTime"][0].Status, "detailed reports on the service port")
+}
+
+func Test_InvalidHealthPaths(t *testing.T) {
+	assert := assert.New(t)
+
+	directory := t.TempDir()
+
+	testCases := []struct {
+		Health      config.Health
+		Description string
+	}{
+		{Health: config.Health{LivenessPath: "/probe", ReadinessPath: "/probe"}, Description: "duplicate paths"},
+		{Health: config.Health{ReadinessPath: "/status/"}, Description: "built in route"},
+		{Health: config.Health{LivenessPath: "/"}, Description: "catch all route"},
+		{Health: config.Health{StartupPath: "/assets/"}, Description: "static mount"},
+		{Health: config.Health{LivenessPath: "live"}, Description: "relative path"},
+	}
+
+	for _, tc := range testCases {
+		cfg := config.Settings{Health: tc.Health}
+		cfg.Static = []config.Static{{PathPrefix: "/assets/", Directory: directory}}
+
+		svc := server.NewServer("TestServiceName", &cfg, nil)
+		svc.Init()
+
+		var handler http.Handler
+		assert.NotPanics(func() { handler = svc.Handler() }, tc.Description)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, server.DefaultLivenessPath, nil))
+		assert.Equal(http.StatusOK, recorder.Code, tc.Description+": default paths used")
+	}
+}
+
+func Test_StartupProbeAndSharedHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := config.Settings{}
+	cfg.Service.HTTP.Server.IPv4Address = "127.0.0.1"
+	cfg.Service.HTTP.Server.Port = freePort(t)
+	cfg.Service.GracefulShutdownDelaySeconds = "0s"
+	cfg.Health = config.Health{LivenessPath: "/live", ReadinessPath: "/ready", StartupPath: "/started"}
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", cfg.Service.HTTP.Server.Port)
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+	svc.GetHealth().AddListener()
+
+	metricsAddress := fmt.Sprintf("127.0.0.1:%d", freePort(t))
+	metricsURL := "http://" + metricsAddress
+	metricsStatus := func(path string) int {
+		response, err := http.Get(metricsURL + path)
+		if err != nil {
+			return 0
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+	serviceStatus := func(path string) int {
+		response, err := http.Get(baseURL + path)
+		if err != nil {
+			return 0
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	go svc.Run()
+	defer svc.Shutdown()
+
+	assert.True(waitFor(func() bool { return serviceStatus("/live") == http.StatusOK }), "service listening")
+	assert.Equal(http.StatusServiceUnavailable, serviceStatus("/started"), "metrics listener not bound yet")
+
+	go metrics.StartServer(context.Background(), metricsAddress, true, false, config.PProf{}, svc.GetHealth(), svc.HealthPaths(), nil)
+
+	assert.True(waitFor(func() bool { return serviceStatus("/started") == http.StatusOK }), "startup probe passes once both listeners are bound")
+	assert.Equal(http.StatusOK, metricsStatus("/started"), "metrics port shares the state and the paths")
+	assert.Equal(http.StatusNotFound, metricsStatus("/healthz/startup"), "default path replaced on the metrics port")
+
+	for _, path := range []string{"/live", "/ready"} {
+		response, err := http.Get(baseURL + path)
+		assert.Nil(err)
+		response.Body.Close()
+		assert.Equal(http.StatusOK, response.StatusCode, path)
+	}
+
+	response, err := http.Get(baseURL + "/healthz/readinessZ67")
+	assert.Nil(err)
+	response.Body.Close()
+	assert.NotEqual(http.StatusServiceUnavailable, response.StatusCode, "default path replaced")
+
+	assert.Nil(svc.SetReadinessOverride(server.ReadinessDown))
+	assert.Equal(http.StatusServiceUnavailable, metricsStatus("/ready"), "admin override on both ports")
+	assert.Equal(http.StatusOK, metricsStatus("/live"))
+
+	svc.GetHealth().SetStatus(false, "wedged")
+	response, err = http.Get(baseURL + "/live")
+	assert.Nil(err)
+	response.Body.Close()
+	assert.Equal(http.StatusServiceUnavailable, response.StatusCode, "liveness follows the shared status")
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+// Lifecycle: starting -> serving -> draining -> stopped.
+// Draining fails readiness, turns keep-alives off and closes connections after each response,
+// in flight requests get until the drain timeout to finish before connections are force closed.
+
+// State is a server lifecycle state
+type State int32
+
+const (
+	StateStarting State = iota // created or initialized, not listening yet
+	StateServing               // listening
+	StateDraining              // shutting down, finishing in flight requests
+	StateStopped               // not serving
+
+	DefaultDrainTimeout = 5 * time.Second
+	DefaultDrainDelay   = 10 * time.Second
+
+	HttpHeader_Connection = "Connection"
+)
+
+func (state State) String() string {
+	switch state {
+	case StateStarting:
+		return "starting"
+	case StateServing:
+		return "serving"
+	case StateDraining:
+		return "draining"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// DrainResult reports how a shutdown went
+type DrainResult struct {
+	InFlight int64         // requests in flight when the listeners closed
+	Drained  int64         // requests that finished before the drain timeout
+	Forced   int64         // requests cut off by force closing their connections
+	Duration time.Duration // time from draining to stopped
+}
+
+// GetState - current lifecycle state, safe for concurrent use
+func (s *Server) GetState() State {
+	return State(s.state.Load())
+}
+
+// transition - move from one of the from states to to, false when the server is in another state
+func (s *Server) transition(to State, from ...State) bool {
+	for _, state := range from {
+		if s.state.CompareAndSwap(int32(state), int32(to)) {
+			if to >= StateDraining {
+				s.health.SetDraining("Server is shutting down.")
+			}
+			if to == StateStopped {
+				close(s.stopped)
+			}
+			log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false, "lifecycle.from", state.String(), "lifecycle.to", to.String())).Infof("server.transition lifecycle state changed")
+			return true
+		}
+	}
+
+	return false
+}
+
+// InFlight - requests currently being handled
+func (s *Server) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// trackRequests - count in flight requests and ask clients to close their connection once draining
+func (s *Server) trackRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+
+		if s.GetState() >= StateDraining {
+			responseWriter.Header().Set(HttpHeader_Connection, "close")
+		}
+
+		next.ServeHTTP(responseWriter, request)
+	})
+}
+
+// drainDurations - delay before the listeners close and the deadline for in flight requests, from config with defaults
+func (s *Server) drainDurations() (time.Duration, time.Duration) {
+	method := "server.drainDurations"
+
+	delay, timeout := DefaultDrainDelay, DefaultDrainTimeout
+
+	if s.config == nil {
+		return delay, timeout
+	}
+
+	if value, err := time.ParseDuration(s.config.Service.GracefulShutdownDelaySeconds); err == nil && value >= 0 {
+		delay = value
+	} else {
+		log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false, "value", s.config.Service.GracefulShutdownDelaySeconds)).Warnf("%s invalid Service.GracefulShutdownDelaySeconds, defaulting to: %s", method, delay.String())
+	}
+
+	if len(s.config.Service.DrainTimeout) > 0 {
+		if value, err := time.ParseDuration(s.config.Service.DrainTimeout); err == nil && value > 0 {
+			timeout = value
+		} else {
+			log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false, "value", s.config.Service.DrainTimeout)).Warnf("%s invalid Service.DrainTimeout, defaulting to: %s", method, timeout.String())
+		}
+	}
+
+	return delay, timeout
+}
+
+// Shutdown - drain and stop the server, safe to call more than once and before Run
+func (s *Server) Shutdown() DrainResult {
+	//nolint
+	method := "server.Shutdown"
+	log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false)).Infof("%s entering", method)
+
+	start := time.Now().UTC()
+	result := DrainResult{}
+
+	if !s.transition(StateDraining, StateServing) {
+		// never served, nothing to drain
+		if s.transition(StateStopped, StateStarting) {
+			s.closeServer()
+		}
+		log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false, "lifecycle.state", s.GetState().String())).Infof("%s not serving, nothing to drain", method)
+		return result
+	}
+
+	s.serverMu.Lock()
+	httpServer := s.server
+	s.serverMu.Unlock()
+
+	httpServer.SetKeepAlivesEnabled(false)
+
+	delay, timeout := s.drainDurations()
+
+	// keep serving while load balancers notice the failing readiness
+	time.Sleep(delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result.InFlight = s.InFlight()
+
+	err := httpServer.Shutdown(ctx)
+	if err != nil {
+		result.Forced = s.InFlight()
+		if errors.Is(err, context.DeadlineExceeded) {
+			log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, "drain.forced", result.Forced)).Warnf("%s drain timeout after %s, force closing connections", method, timeout.String())
+		} else {
+			log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s server error while shutting down, force closing connections", method)
+		}
+		if err = httpServer.Close(); err != nil {
+			log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s server error while closing", method)
+		}
+	}
+
+	result.Drained = result.InFlight - result.Forced
+	if result.Drained < 0 {
+		result.Drained = 0
+	}
+	result.Duration = time.Since(start)
+
+	s.transition(StateStopped, StateDraining)
+
+	log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false, "drain.inflight", result.InFlight, "drain.drained", result.Drained, "drain.forced", result.Forced, "drain.duration", result.Duration.String())).Infof("%s stopped", method)
+
+	return result
+}
+
+// closeServer - close the http.Server if Run created one
+func (s *Server) closeServer() {
+	s.serverMu.Lock()
+	defer s.serverMu.Unlock()
+
+	if s.server != nil {
+		_ = s.server.Close()
+	}
+}
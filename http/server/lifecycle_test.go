@@ -0,0 +1,121 @@
+package server_test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/server"
+)
+
+func freePort(t *testing.T) uint16 {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	return uint16(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func waitFor(condition func() bool) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func Test_LifecycleDrain(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := config.Settings{}
+	cfg.Service.HTTP.Server.IPv4Address = "127.0.0.1"
+	cfg.Service.HTTP.Server.Port = freePort(t)
+	cfg.Service.GracefulShutdownDelaySeconds = "300ms"
+	cfg.Service.DrainTimeout = "200ms"
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", cfg.Service.HTTP.Server.Port)
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+	assert.Equal(server.StateStarting, svc.GetState())
+
+	runDone := make(chan struct{})
+	go func() {
+		svc.Run()
+		close(runDone)
+	}()
+
+	assert.True(waitFor(func() bool {
+		response, err := http.Get(baseURL + "/healthz/readinessZ67")
+		if err != nil {
+			return false
+		}
+		response.Body.Close()
+		return response.StatusCode == http.StatusOK
+	}), "server ready")
+	assert.Equal(server.StateServing, svc.GetState())
+
+	// a response the client never reads keeps its handler in flight
+	slow, err := http.Get(baseURL + "/bytes/104857600")
+	assert.Nil(err)
+	defer slow.Body.Close()
+	assert.True(waitFor(func() bool { return svc.InFlight() == 1 }), "slow request in flight")
+
+	results := make(chan server.DrainResult)
+	go func() { results <- svc.Shutdown() }()
+
+	assert.True(waitFor(func() bool { return svc.GetState() == server.StateDraining }), "draining")
+	assert.True(svc.IsShuttingDown())
+
+	response, err := http.Get(baseURL + "/healthz/readinessZ67")
+	assert.Nil(err)
+	response.Body.Close()
+	assert.Equal(http.StatusServiceUnavailable, response.StatusCode, "readiness fails while draining")
+	assert.True(response.Close, "connections are closed while draining")
+
+	result := <-results
+	assert.Equal(int64(1), result.InFlight)
+	assert.Equal(int64(1), result.Forced)
+	assert.Equal(int64(0), result.Drained)
+	assert.Equal(server.StateStopped, svc.GetState())
+
+	select {
+	case <-runDone:
+	case <-time.After(5 * time.Second):
+		assert.Fail("Run did not return after Shutdown")
+	}
+
+	assert.Equal(server.DrainResult{}, svc.Shutdown(), "second Shutdown is a no-op")
+}
+
+func Test_ShutdownBeforeRun(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := config.Settings{}
+	cfg.Service.HTTP.Server.IPv4Address = "127.0.0.1"
+	cfg.Service.HTTP.Server.Port = freePort(t)
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+
+	assert.Equal(server.DrainResult{}, svc.Shutdown())
+	assert.Equal(server.StateStopped, svc.GetState())
+
+	runDone := make(chan struct{})
+	go func() {
+		svc.Run()
+		close(runDone)
+	}()
+
+	select {
+	case <-runDone:
+	case <-time.After(5 * time.Second):
+		assert.Fail("Run should not listen once stopped")
+	}
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mdonahue-godaddy/go-http-server/log"
+)
+
+// Every response is counted once, by status family, body bytes and duration, in the TrackedMetrics group for its path.
+// Service responses are also timed by route pattern, method and status class, paths without a route count as other.
+
+const (
+	MetricsGroupService = "service"
+	MetricsGroupHealth  = "health"
+	MetricsGroupMetric  = "metric"
+
+	GoMetricsPath = "/debug/gometrics"
+)
+
+// statusWriter records the status and the body bytes written through it
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// Unwrap - underlying writer for http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	// informational responses are followed by the final status
+	if w.status == 0 && (statusCode < 100 || statusCode >= 200 || statusCode == http.StatusSwitchingProtocols) {
+		w.status = statusCode
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack - take over the connection, counted as switching protocols
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking is not supported")
+	}
+
+	conn, readWriter, err := hijacker.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, readWriter, err
+}
+
+// Status - status written, 200 when the handler wrote nothing
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// metricsGroup - TrackedMetrics group for a service port path
+func (s *Server) metricsGroup(path string) string {
+	livenessPath, readinessPath, startupPath := s.healthPaths()
+
+	switch path {
+	case livenessPath, readinessPath, startupPath:
+		return MetricsGroupHealth
+	case GoMetricsPath:
+		return MetricsGroupMetric
+	}
+
+	return MetricsGroupService
+}
+
+// metricsPortGroup - TrackedMetrics group for a metrics port path, the health paths and the /healthz/ tree count as health
+func (s *Server) metricsPortGroup(path string) string {
+	if s.metricsGroup(path) == MetricsGroupHealth || strings.HasPrefix(path, "/healthz/") {
+		return MetricsGroupHealth
+	}
+
+	return MetricsGroupMetric
+}
+
+type metricsRouteKey struct{}
+
+// setMetricsRoute - name the route of a request served by the catch all processor, e.g. the matched route prefix
+func setMetricsRoute(request *http.Request, route string) {
+	if label, ok := request.Context().Value(metricsRouteKey{}).(*string); ok {
+		*label = route
+	}
+}
+
+// metricsRoute - route pattern of a service request, the router pattern unless the request named its route
+func (s *Server) metricsRoute(router *http.ServeMux) func(request *http.Request) string {
+	return func(request *http.Request) string {
+		if label, ok := request.Context().Value(metricsRouteKey{}).(*string); ok && len(*label) > 0 {
+			return *label
+		}
+
+		// the catch all pattern only counts requests that matched a route
+		if _, pattern := router.Handler(request); pattern != "/" {
+			return pattern
+		}
+
+		return ""
+	}
+}
+
+// recordMetrics - count the status family, body bytes and duration of every response in the group for its path,
+// and time service responses by route when route is not nil
+func (s *Server) recordMetrics(next http.Handler, group func(path string) string, route func(request *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		writer := &statusWriter{ResponseWriter: responseWriter}
+
+		if route != nil {
+			request = request.WithContext(context.WithValue(request.Context(), metricsRouteKey{}, new(string)))
+		}
+
+		defer func() {
+			duration := time.Since(start)
+
+			switch group(request.URL.Path) {
+			case MetricsGroupHealth:
+				s.metrics.IncHTTPHealth(&log.DefaultLogger, writer.Status(), duration)
+				s.metrics.IncHTTPHealthBytes(writer.bytes)
+			case MetricsGroupMetric:
+				s.metrics.IncHTTPMetric(&log.DefaultLogger, writer.Status(), duration)
+				s.metrics.IncHTTPMetricBytes(writer.bytes)
+			default:
+				s.metrics.IncHTTPService(&log.DefaultLogger, writer.Status(), duration)
+				s.metrics.IncHTTPServiceBytes(writer.bytes)
+				if route != nil {
+					s.metrics.IncHTTPRoute(route(request), request.Method, writer.Status(), duration)
+				}
+			}
+		}()
+
+		next.ServeHTTP(writer, request)
+	})
+}
+
+// MetricsPortHandler - count metrics port responses in the health and metric groups
+func (s *Server) MetricsPortHandler(next http.Handler) http.Handler {
+	return s.recordMetrics(next, s.metricsPortGroup, nil)
+}
@@ -0,0 +1,106 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/server"
+)
+
+func Test_ResponseMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	count := func(name string) int64 {
+		return metrics.GetOrRegisterCounter("go-http-server."+name, metrics.DefaultRegistry).Count()
+	}
+	timed := func(name string) int64 {
+		return metrics.GetOrRegisterTimer("go-http-server."+name, metrics.DefaultRegistry).Count()
+	}
+
+	svc := server.NewServer("TestServiceName", &config.Settings{}, nil)
+	svc.Init()
+	handler := svc.Handler()
+
+	testCases := []struct {
+		Path        string
+		Counter     string
+		Timer       string
+		Bytes       string
+		Description string
+	}{
+		{Path: "/status/404", Counter: "http.service.response.status.4xx", Timer: "http.service.request", Bytes: "http.service.response.bytes", Description: "client error"},
+		{Path: "/status/503", Counter: "http.service.response.status.5xx", Timer: "http.service.request", Bytes: "http.service.response.bytes", Description: "server error"},
+		{Path: "/bytes/10", Counter: "http.service.response.status.2xx", Timer: "http.service.request", Bytes: "http.service.response.bytes", Description: "success"},
+		{Path: server.DefaultLivenessPath, Counter: "http.health.response.status.2xx", Timer: "http.health.request", Bytes: "http.health.response.bytes", Description: "health"},
+		{Path: server.GoMetricsPath, Counter: "http.metric.response.status.2xx", Timer: "http.metric.request", Bytes: "http.metric.response.bytes", Description: "metrics"},
+	}
+
+	for _, tc := range testCases {
+		counter, timer, bytes := count(tc.Counter), timed(tc.Timer), count(tc.Bytes)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.Path, nil))
+
+		assert.Equal(counter+1, count(tc.Counter), tc.Description)
+		assert.Equal(timer+1, timed(tc.Timer), tc.Description)
+		assert.Equal(bytes+int64(recorder.Body.Len()), count(tc.Bytes), tc.Description)
+	}
+
+	health, metric := count("http.health.response.status.2xx"), count("http.metric.response.status.4xx")
+	metricsPort := svc.MetricsPortHandler(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/healthz/liveness" {
+			return
+		}
+		http.NotFound(responseWriter, request)
+	}))
+	metricsPort.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz/liveness", nil))
+	metricsPort.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(health+1, count("http.health.response.status.2xx"), "metrics port health")
+	assert.Equal(metric+1, count("http.metric.response.status.4xx"), "metrics port other")
+}
+
+func Test_RouteMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	timed := func(name string) int64 {
+		return metrics.GetOrRegisterTimer("go-http-server.http.service.route."+name, metrics.DefaultRegistry).Count()
+	}
+
+	cfg := config.Settings{}
+	cfg.Routes = []config.Route{{PathPrefix: "/api/v1/", Response: &config.CannedResponse{Status: http.StatusCreated, Body: "created"}}}
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+	handler := svc.Handler()
+
+	testCases := []struct {
+		Method      string
+		Path        string
+		Timer       string
+		Description string
+	}{
+		{Method: http.MethodGet, Path: "/status/418", Timer: "status.GET.4xx", Description: "router pattern"},
+		{Method: http.MethodPost, Path: "/status/500", Timer: "status.POST.5xx", Description: "method and status class"},
+		{Method: http.MethodGet, Path: "/api/v1/users/42", Timer: "api_v1.GET.2xx", Description: "config route prefix"},
+		{Method: http.MethodGet, Path: "/unrouted/42", Timer: "other.GET.2xx", Description: "unknown path"},
+	}
+
+	for _, tc := range testCases {
+		before := timed(tc.Timer)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(tc.Method, tc.Path, nil))
+
+		assert.Equal(before+1, timed(tc.Timer), tc.Description)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, server.GoMetricsPath, nil))
+	assert.Equal(http.StatusOK, recorder.Code, "exp handler")
+	assert.Contains(recorder.Body.String(), "go-http-server.http.service.route.status.GET.4xx.count")
+}
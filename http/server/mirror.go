@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/outbound"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/gometrics"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+const (
+	DefaultMirrorTimeout       = 5 * time.Second
+	DefaultMirrorMaxBodyBytes  = int64(1 << 20) // 1 MB
+	DefaultMirrorMaxConcurrent = 100
+
+	HttpHeader_XShadowRequest = "X-Shadow-Request"
+	HttpHeader_XForwardedHost = "X-Forwarded-Host"
+)
+
+// mirror asynchronously copies a percentage of requests to a shadow target, it must never change the primary response
+type mirror struct {
+	target       *url.URL
+	percentage   float64
+	maxBodyBytes int64
+	credentials  bool // forward secret headers
+	client       *http.Client
+	slots        chan struct{}
+	metrics      gometrics.IGoMetrics
+}
+
+func newMirror(cfg config.Mirror, outboundCfg config.Outbound, gm gometrics.IGoMetrics) (*mirror, error) {
+	target, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if len(target.Scheme) == 0 || len(target.Host) == 0 {
+		return nil, errors.New("mirror url requires scheme and host")
+	}
+
+	m := mirror{
+		target:       target,
+		percentage:   cfg.Percentage,
+		maxBodyBytes: cfg.MaxBodyBytes,
+		credentials:  cfg.ForwardCredentials,
+		metrics:      gm,
+	}
+
+	if m.maxBodyBytes <= 0 {
+		m.maxBodyBytes = DefaultMirrorMaxBodyBytes
+	}
+
+	maxConcurrent := cfg.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = DefaultMirrorMaxConcurrent
+	}
+	m.slots = make(chan struct{}, maxConcurrent)
+
+	// shadow requests are never retried, but do get a breaker so a dead shadow target is not hammered
+	outboundCfg.MaxRetries = -1
+	outboundCfg.Timeout = cfg.Timeout
+	if len(outboundCfg.Timeout) == 0 {
+		outboundCfg.Timeout = DefaultMirrorTimeout.String()
+	}
+	m.client = outbound.New(outboundCfg, nil, nil, nil).HTTPClient()
+
+	return &m, nil
+}
+
+// sample - true when this request should be mirrored
+func (m *mirror) sample() bool {
+	if m.percentage >= 100 {
+		return true
+	}
+
+	return rand.Float64()*100 < m.percentage
+}
+
+// Mirror - copy request (body included) to the shadow target in the background.
+// The request body is buffered and replaced so the primary handler still sees the full body.
+func (m *mirror) Mirror(ctx context.Context, request *http.Request) {
+	method := "server.mirror.Mirror"
+
+	if !m.sample() {
+		return
+	}
+
+	if request.ContentLength > m.maxBodyBytes {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s request body too large to mirror", method)
+		return
+	}
+
+	var body []byte
+	if request.Body != nil && request.Body != http.NoBody {
+		buffered, err := io.ReadAll(io.LimitReader(request.Body, m.maxBodyBytes+1))
+		if err != nil || int64(len(buffered)) > m.maxBodyBytes {
+			// give the primary handler back exactly what was read plus the unread remainder
+			request.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buffered), request.Body), Closer: request.Body}
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s request body unreadable or too large to mirror", method)
+			return
+		}
+		body = buffered
+		request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		m.metrics.IncShadowError()
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s too many shadow requests in flight, dropping", method)
+		return
+	}
+
+	shadow, err := m.createShadowRequest(request, body)
+	if err != nil {
+		<-m.slots
+		m.metrics.IncShadowError()
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error creating shadow request", method)
+		return
+	}
+
+	go m.send(ctx, shadow)
+}
+
+func (m *mirror) send(ctx context.Context, shadow *http.Request) {
+	method := "server.mirror.send"
+	defer func() { <-m.slots }()
+
+	start := time.Now()
+
+	response, err := m.client.Do(shadow)
+	if err != nil {
+		m.metrics.IncShadowError()
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Warnf("%s shadow request error", method)
+		return
+	}
+
+	_, _ = io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	m.metrics.IncShadowRequest(time.Since(start))
+
+	if response.StatusCode >= http.StatusInternalServerError {
+		m.metrics.IncShadowError()
+	}
+
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, shared.KeyHTTPResponseStatusCode, response.StatusCode)).Debugf("%s shadow response discarded", method)
+}
+
+// createShadowRequest - detached from the primary request context so it outlives the primary response,
+// headers naming a secret (see isSecretKey) are dropped unless credentials are forwarded
+func (m *mirror) createShadowRequest(request *http.Request, body []byte) (*http.Request, error) {
+	shadowURL := *m.target
+	shadowURL.Path = strings.TrimSuffix(shadowURL.Path, "/") + request.URL.Path
+	shadowURL.RawPath = ""
+	shadowURL.RawQuery = request.URL.RawQuery
+
+	shadow, err := http.NewRequestWithContext(context.Background(), request.Method, shadowURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	shadow.Header = request.Header.Clone()
+	if !m.credentials {
+		for key := range shadow.Header {
+			if isSecretKey(key) {
+				shadow.Header.Del(key)
+			}
+		}
+	}
+	shadow.Header.Set(HttpHeader_XShadowRequest, "true")
+	shadow.Header.Set(HttpHeader_XForwardedHost, request.Host)
+
+	return shadow, nil
+}
+
+// mirrorRequests - copy requests for known hosts to the shadow target, health probes are not mirrored
+func (s *Server) mirrorRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		if s.mirror != nil && s.metricsGroup(request.URL.Path) != MetricsGroupHealth {
+			s.mirror.Mirror(shared.CreateRequestContext(request, "server.mirrorRequests"), request)
+		}
+
+		next.ServeHTTP(responseWriter, request)
+	})
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
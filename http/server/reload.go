@@ -0,0 +1,233 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+// routing is the reloadable part of the server, swapped as a unit so a request sees a single configuration
+type routing struct {
+	config          *config.Settings
+	virtualHosts    *virtualHosts
+	cannedResponses map[*config.CannedResponse]*cannedResponse
+	corsPolicies    map[*config.CORS]*corsPolicy
+	templates       *templateSet
+	errorPages      *errorPages
+}
+
+// routing - current configuration, the startup config before Init
+func (s *Server) routing() *routing {
+	if rt := s.live.Load(); rt != nil {
+		return rt
+	}
+
+	return &routing{config: s.config}
+}
+
+// setting is a named config section compared between reloads
+type setting struct {
+	name  string
+	field func(cfg *config.Settings) interface{} // pointer to the section
+}
+
+// liveSettings are applied by Reload
+var liveSettings = []setting{
+	{"logging.level", func(cfg *config.Settings) interface{} { return &cfg.Logging.Level }},
+	{"service.unknownHostStatus", func(cfg *config.Settings) interface{} { return &cfg.Service.UnknownHostStatus }},
+	{"routes", func(cfg *config.Settings) interface{} { return &cfg.Routes }},
+	{"virtualHosts", func(cfg *config.Settings) interface{} { return &cfg.VirtualHosts }},
+	{"cors", func(cfg *config.Settings) interface{} { return &cfg.CORS }},
+	{"templates", func(cfg *config.Settings) interface{} { return &cfg.Templates }},
+	{"errorPages", func(cfg *config.Settings) interface{} { return &cfg.ErrorPages }},
+}
+
+// restartSettings need a restart, Reload keeps their current values
+var restartSettings = []setting{
+	{"service.http", func(cfg *config.Settings) interface{} { return &cfg.Service.HTTP }},
+	{"service.gracefulShutdownDelaySeconds", func(cfg *config.Settings) interface{} { return &cfg.Service.GracefulShutdownDelaySeconds }},
+	{"service.drainTimeout", func(cfg *config.Settings) interface{} { return &cfg.Service.DrainTimeout }},
+	{"service.configWatchInterval", func(cfg *config.Settings) interface{} { return &cfg.Service.ConfigWatchInterval }},
+	{"metrics", func(cfg *config.Settings) interface{} { return &cfg.Metrics }},
+	{"upstreams", func(cfg *config.Settings) interface{} { return &cfg.Upstreams }},
+	{"outbound", func(cfg *config.Settings) interface{} { return &cfg.Outbound }},
+	{"mirror", func(cfg *config.Settings) interface{} { return &cfg.Mirror }},
+	{"jwt", func(cfg *config.Settings) interface{} { return &cfg.JWT }},
+	{"compression", func(cfg *config.Settings) interface{} { return &cfg.Compression }},
+	{"static", func(cfg *config.Settings) interface{} { return &cfg.Static }},
+	{"admin", func(cfg *config.Settings) interface{} { return &cfg.Admin }},
+	{"health", func(cfg *config.Settings) interface{} { return &cfg.Health }},
+	{"profiler", func(cfg *config.Settings) interface{} { return &cfg.Profiler }},
+}
+
+// changedSettings - names of the settings that differ between current and next
+func changedSettings(settings []setting, current *config.Settings, next *config.Settings) []string {
+	changed := []string{}
+
+	for _, setting := range settings {
+		if !reflect.DeepEqual(setting.field(current), setting.field(next)) {
+			changed = append(changed, setting.name)
+		}
+	}
+
+	return changed
+}
+
+// ReloadResult reports what a reload changed
+type ReloadResult struct {
+	Applied         []string // live settings that changed
+	RestartRequired []string // changed settings that keep their current value until restart
+	Err             error    // the new config was rejected, nothing changed
+}
+
+// newRouting - build the reloadable configuration, unlike Init any error rejects the whole config
+func (s *Server) newRouting(cfg *config.Settings) (*routing, error) {
+	rt := &routing{config: cfg}
+
+	if len(cfg.Logging.Level) > 0 {
+		if _, err := log.ParseLevel(cfg.Logging.Level); err != nil {
+			return nil, fmt.Errorf("logging.level: %w", err)
+		}
+	}
+
+	err := eachRoute(cfg, func(route *config.Route) error {
+		if route.Response == nil && len(route.Upstream) > 0 {
+			if _, found := s.upstreams.Get(route.Upstream); !found {
+				return fmt.Errorf("route '%s': unknown upstream '%s'", route.PathPrefix, route.Upstream)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Templates.Directory) > 0 {
+		templates, err := newTemplateSet(cfg.Templates)
+		if err != nil {
+			return nil, fmt.Errorf("templates: %w", err)
+		}
+		rt.templates = templates
+	}
+
+	if len(cfg.ErrorPages) > 0 {
+		errorPages, err := newErrorPages(cfg.ErrorPages, rt.templates)
+		if err != nil {
+			return nil, err
+		}
+		rt.errorPages = errorPages
+	}
+
+	if len(cfg.VirtualHosts) > 0 {
+		virtualHosts, err := newVirtualHosts(s.context, cfg.VirtualHosts, s.responseTemplateFile, rt.templates)
+		if err != nil {
+			return nil, fmt.Errorf("virtual hosts: %w", err)
+		}
+		rt.virtualHosts = virtualHosts
+	}
+
+	cannedResponses, err := newCannedResponses(cfg, s.started)
+	if err != nil {
+		return nil, fmt.Errorf("canned responses: %w", err)
+	}
+	rt.cannedResponses = cannedResponses
+
+	corsPolicies, err := newCORSPolicies(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cors: %w", err)
+	}
+	rt.corsPolicies = corsPolicies
+
+	return rt, nil
+}
+
+// Reload - validate cfg and apply its live settings atomically, settings that need a restart are logged and kept
+func (s *Server) Reload(cfg *config.Settings) ReloadResult {
+	//nolint
+	method := "server.Reload"
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	result := ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+
+	current := s.live.Load()
+	switch {
+	case cfg == nil:
+		result.Err = errors.New("no config")
+	case current == nil:
+		result.Err = errors.New("server not initialized")
+	}
+	if result.Err != nil {
+		s.reloaded(method, result)
+		return result
+	}
+
+	effective := *cfg
+
+	result.RestartRequired = changedSettings(restartSettings, current.config, &effective)
+	for _, setting := range restartSettings {
+		reflect.ValueOf(setting.field(&effective)).Elem().Set(reflect.ValueOf(setting.field(current.config)).Elem())
+	}
+	for _, name := range result.RestartRequired {
+		log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false, "config.setting", name)).Warnf("%s %s changed, restart required to apply it", method, name)
+	}
+
+	result.Applied = changedSettings(liveSettings, current.config, &effective)
+
+	rt, err := s.newRouting(&effective)
+	if err != nil {
+		result.Applied = []string{}
+		result.Err = err
+		s.reloaded(method, result)
+		return result
+	}
+
+	if lvl, err := log.ParseLevel(effective.Logging.Level); err == nil && effective.Logging.Level != current.config.Logging.Level {
+		log.SetLevel(lvl)
+	}
+
+	s.live.Store(rt)
+
+	if current.templates != nil {
+		current.templates.Stop()
+	}
+	if rt.templates != nil && s.GetState() == StateServing {
+		rt.templates.Start(s.context)
+	}
+
+	s.reloaded(method, result)
+	return result
+}
+
+// ReloadFile - load fileName with config.LoadSettings and Reload it
+func (s *Server) ReloadFile(fileName string) ReloadResult {
+	cfg, err := config.LoadSettings(fileName)
+	if err != nil {
+		result := ReloadResult{Applied: []string{}, RestartRequired: []string{}, Err: fmt.Errorf("loading '%s': %w", fileName, err)}
+		s.reloaded("server.ReloadFile", result)
+		return result
+	}
+
+	return s.Reload(cfg)
+}
+
+// reloaded - count and log the reload result
+func (s *Server) reloaded(method string, result ReloadResult) {
+	if s.metrics != nil {
+		s.metrics.IncConfigReload(result.Err == nil)
+	}
+
+	if result.Err != nil {
+		log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, "config.reload.result", "failure", shared.KeyErrorMessage, result.Err.Error())).Errorf("%s config rejected, keeping the current config", method)
+		return
+	}
+
+	log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false, "config.reload.result", "success", "config.reload.applied", strings.Join(result.Applied, ","), "config.reload.restart_required", strings.Join(result.RestartRequired, ","))).Infof("%s config reloaded", method)
+}
@@ -0,0 +1,120 @@
+package server_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/server"
+)
+
+func get(handler http.Handler, target string) (int, string) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	body, _ := io.ReadAll(recorder.Body)
+
+	return recorder.Code, string(body)
+}
+
+func Test_Reload(t *testing.T) {
+	assert := assert.New(t)
+
+	defer log.SetLevel(log.GetLevel())
+
+	directory := createTemplateDirectory(t, map[string]string{"default.html": `old {{.Status}}`})
+
+	cfg := config.Settings{}
+	cfg.Logging.Level = "info"
+	cfg.Service.HTTP.Server.Port = 8080
+	cfg.Templates = config.Templates{Directory: directory}
+	cfg.ErrorPages = map[string]config.ErrorPage{"404": {Template: "default.html"}}
+	cfg.Routes = []config.Route{{PathPrefix: "/hello", Response: &config.CannedResponse{Body: "hello"}}}
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+	handler := svc.Handler()
+
+	status, body := get(handler, "/hello")
+	assert.Equal(http.StatusOK, status)
+	assert.Equal("hello", body)
+
+	assert.Nil(os.WriteFile(filepath.Join(directory, "default.html"), []byte(`new {{.Status}}`), 0600))
+
+	next := cfg
+	next.Logging.Level = "debug"
+	next.Service.HTTP.Server.Port = 9090
+	next.Routes = []config.Route{{PathPrefix: "/hello", Response: &config.CannedResponse{Body: "goodbye"}}}
+
+	result := svc.Reload(&next)
+	assert.Nil(result.Err)
+	assert.Equal([]string{"logging.level", "routes"}, result.Applied)
+	assert.Equal([]string{"service.http"}, result.RestartRequired)
+
+	status, body = get(handler, "/hello")
+	assert.Equal(http.StatusOK, status)
+	assert.Equal("goodbye", body, "routes applied")
+	assert.Equal(log.DebugLevel, log.GetLevel(), "log level applied")
+	assert.Equal(uint16(8080), svc.GetConfig().Service.HTTP.Server.Port, "listen address kept until restart")
+	assert.Equal("debug", svc.GetConfig().Logging.Level)
+
+	status, body = get(handler, "/status/404")
+	assert.Equal("new 404", body, "templates reloaded")
+	assert.Equal(http.StatusNotFound, status)
+}
+
+func Test_ReloadInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	failures := metrics.GetOrRegisterCounter("go-http-server.config.reload.failure", metrics.DefaultRegistry)
+
+	cfg := config.Settings{}
+	cfg.Routes = []config.Route{{PathPrefix: "/hello", Response: &config.CannedResponse{Body: "hello"}}}
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+	handler := svc.Handler()
+
+	testCases := []struct {
+		Change      func(cfg *config.Settings)
+		Description string
+	}{
+		{Change: func(cfg *config.Settings) { cfg.Logging.Level = "loud" }, Description: "invalid log level"},
+		{Change: func(cfg *config.Settings) { cfg.Routes = []config.Route{{PathPrefix: "/api", Upstream: "missing"}} }, Description: "unknown upstream"},
+		{Change: func(cfg *config.Settings) {
+			cfg.Templates = config.Templates{Directory: filepath.Join(t.TempDir(), "missing")}
+		}, Description: "missing template directory"},
+		{Change: func(cfg *config.Settings) { cfg.ErrorPages = map[string]config.ErrorPage{"200": {Body: "ok"}} }, Description: "invalid error page"},
+		{Change: func(cfg *config.Settings) {
+			cfg.Routes[0].Response = &config.CannedResponse{BodyFile: filepath.Join(t.TempDir(), "missing")}
+		}, Description: "missing canned response body file"},
+	}
+
+	for _, tc := range testCases {
+		before := failures.Count()
+
+		next := config.Settings{}
+		next.Routes = []config.Route{{PathPrefix: "/hello", Response: &config.CannedResponse{Body: "goodbye"}}}
+		tc.Change(&next)
+
+		result := svc.Reload(&next)
+		assert.NotNil(result.Err, tc.Description)
+		assert.Empty(result.Applied, tc.Description)
+		assert.Equal(before+1, failures.Count(), tc.Description)
+
+		_, body := get(handler, "/hello")
+		assert.Equal("hello", body, tc.Description+", current config kept")
+	}
+
+	before := failures.Count()
+	result := svc.ReloadFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.NotNil(result.Err)
+	assert.Equal(before+1, failures.Count(), "load failure counted")
+}
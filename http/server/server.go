@@ -4,22 +4,39 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"html"
+	"html/template"
+	"io"
 	"net"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rcrowley/go-metrics"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/auth"
+	"github.com/mdonahue-godaddy/go-http-server/http/compress"
+	"github.com/mdonahue-godaddy/go-http-server/http/outbound"
+	"github.com/mdonahue-godaddy/go-http-server/http/upstream"
 	"github.com/mdonahue-godaddy/go-http-server/metrics/gometrics"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/health"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/pprof"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/profiler"
 	"github.com/mdonahue-godaddy/go-http-server/shared"
 )
 
 const (
+	DefaultLivenessPath  string = "/healthz/livenessZ76"
+	DefaultReadinessPath string = "/healthz/readinessZ67"
+	DefaultStartupPath   string = "/healthz/startup"
+	ProfilesPath         string = "/debugz/profiles/"
+
 	DefaultResponseTemplateFile string = "<!DOCTYPE HTML><html lang='en-us'><head><title>** {{page_title}} **</title></head><body>{{page_body}}</body></html>"
 )
 
@@ -27,13 +44,33 @@ const (
 type Server struct {
 	serviceName          string
 	isInitialized        bool
-	isShuttingDown       bool
+	state                atomic.Int32  // State
+	stopped              chan struct{} // closed once stopped
+	inFlight             atomic.Int64
+	serverMu             sync.Mutex // guards server
+	reloadMu             sync.Mutex // serializes Reload
 	config               *config.Settings
 	context              context.Context
 	router               *http.ServeMux
 	server               *http.Server
 	metrics              gometrics.IGoMetrics
 	responseTemplateFile string
+	upstreams            *upstream.Registry
+	outbound             *outbound.Client
+	mirror               *mirror
+	verifier             *auth.Verifier
+	verifierErr          error
+	compressor           *compress.Compressor
+	staticMounts         []*staticMount
+	live                 atomic.Pointer[routing] // reloadable configuration
+	responseTemplate     *template.Template
+	adminToken           string
+	health               *health.HealthEndpoints // shared with the metrics port
+	watchdog             *health.Watchdog        // nil when no trigger is configured
+	profiler             *profiler.Profiler      // nil without a profile directory
+	exporters            []*gometrics.Exporter   // statsd and graphite push exporters
+	defaultHealthPaths   bool                    // configured health paths are invalid
+	started              time.Time
 }
 
 // NewServer - create new instance of server
@@ -42,6 +79,10 @@ func NewServer(serviceName string, cfg *config.Settings, template *string) *Serv
 
 	server.serviceName = serviceName
 	server.config = cfg
+	server.started = time.Now().UTC()
+	server.stopped = make(chan struct{})
+	server.health = health.NewHealthEndpoints(nil, "")
+	server.health.SetStatus(true, "")
 
 	if template != nil && len(*template) > 0 {
 		server.responseTemplateFile = *template
@@ -49,13 +90,21 @@ func NewServer(serviceName string, cfg *config.Settings, template *string) *Serv
 		server.responseTemplateFile = DefaultResponseTemplateFile
 	}
 
+	responseTemplate, err := compileTemplate("response", server.responseTemplateFile)
+	if err != nil {
+		log.WithFields(shared.GetFields(context.Background(), shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("server.NewServer error parsing response template, using built in template")
+	} else {
+		server.responseTemplate = responseTemplate
+	}
+
 	server.metrics = gometrics.NewGoMetrics(metrics.DefaultRegistry, "go-http-server")
 
 	return &server
 }
 
+// GetConfig - effective configuration, changes with Reload
 func (s *Server) GetConfig() *config.Settings {
-	return s.config
+	return s.routing().config
 }
 
 func (s *Server) GetServiceName() string {
@@ -66,8 +115,195 @@ func (s *Server) IsInitialized() bool {
 	return s.isInitialized
 }
 
+// IsShuttingDown - draining or stopped
 func (s *Server) IsShuttingDown() bool {
-	return s.isShuttingDown
+	return s.GetState() >= StateDraining
+}
+
+// IsReady - readiness, draining always fails it, otherwise the admin override wins over the critical health checks
+func (s *Server) IsReady() bool {
+	ready, _ := s.health.Ready()
+	return ready
+}
+
+// healthPaths - liveness, readiness and startup paths from config.Health with defaults, all defaults when they are invalid
+func (s *Server) healthPaths() (string, string, string) {
+	livenessPath, readinessPath, startupPath := DefaultLivenessPath, DefaultReadinessPath, DefaultStartupPath
+
+	if s.config != nil && !s.defaultHealthPaths {
+		if len(s.config.Health.LivenessPath) > 0 {
+			livenessPath = s.config.Health.LivenessPath
+		}
+		if len(s.config.Health.ReadinessPath) > 0 {
+			readinessPath = s.config.Health.ReadinessPath
+		}
+		if len(s.config.Health.StartupPath) > 0 {
+			startupPath = s.config.Health.StartupPath
+		}
+	}
+
+	return livenessPath, readinessPath, startupPath
+}
+
+// HealthPaths - liveness, readiness and startup paths, shared by the health endpoints on both ports
+func (s *Server) HealthPaths() health.Paths {
+	livenessPath, readinessPath, startupPath := s.healthPaths()
+
+	return health.Paths{Liveness: livenessPath, Readiness: readinessPath, Startup: startupPath}
+}
+
+// GetHealth - health state shared by the health endpoints on both ports
+func (s *Server) GetHealth() *health.HealthEndpoints {
+	return s.health
+}
+
+// GetHealthChecks - dependency checks driving readiness, nil when none are configured
+func (s *Server) GetHealthChecks() *health.Registry {
+	return s.health.Checks
+}
+
+// GetOutboundClient - outbound client shared by forwarding and other outbound calls, nil until Init
+func (s *Server) GetOutboundClient() *outbound.Client {
+	return s.outbound
+}
+
+// UpstreamHealthHandler - upstream pool and target health, served on the metrics port
+func (s *Server) UpstreamHealthHandler() http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		s.upstreams.HealthHandler(responseWriter, request)
+	})
+}
+
+// ProfilesHandler - saved profile listing and downloads under ProfilesPath, protected like the /debugz tree, served on the metrics port
+func (s *Server) ProfilesHandler() http.Handler {
+	method := "server.ProfilesHandler"
+
+	if s.profiler == nil {
+		return http.NotFoundHandler()
+	}
+
+	handler, err := pprof.Protect(s.profiler.Handler(ProfilesPath), s.config.Metrics.PProf)
+	if err != nil {
+		log.WithFields(shared.GetFields(s.context, shared.EventTypeError, true, shared.KeyErrorMessage, err.Error())).Errorf("%s error protecting profiles, profiles not served", method)
+		return http.NotFoundHandler()
+	}
+
+	return handler
+}
+
+// PrometheusHandler - the metrics registry in the Prometheus text format, served on the metrics port
+func (s *Server) PrometheusHandler() http.Handler {
+	if met, ok := s.metrics.(*gometrics.GoMetrics); ok {
+		return met.PrometheusHandler()
+	}
+
+	return http.NotFoundHandler()
+}
+
+// GetRoutes - routes used when virtual hosts are not configured
+func (s *Server) GetRoutes() []config.Route {
+	cfg := s.routing().config
+	if cfg == nil {
+		return nil
+	}
+
+	return cfg.Routes
+}
+
+// GetUnknownHostStatus - status returned for hosts not matching a virtual host
+func (s *Server) GetUnknownHostStatus() int {
+	cfg := s.routing().config
+	if cfg != nil && cfg.Service.UnknownHostStatus >= 400 && cfg.Service.UnknownHostStatus < 500 {
+		return cfg.Service.UnknownHostStatus
+	}
+
+	return http.StatusMisdirectedRequest
+}
+
+// ResolveVirtualHost - virtual host for the request Host header, nil when no virtual host matches.
+// Hosts failing shared.IsValidRequestHost (i.e. IP addresses) only match the default virtual host.
+func (s *Server) ResolveVirtualHost(ctx context.Context, request *http.Request) *VirtualHost {
+	return s.routing().resolveVirtualHost(ctx, request)
+}
+
+func (rt *routing) resolveVirtualHost(ctx context.Context, request *http.Request) *VirtualHost {
+	if rt.virtualHosts == nil {
+		return nil
+	}
+
+	host, err := shared.GetHost(ctx, request)
+	if err != nil {
+		return nil
+	}
+
+	if !shared.IsValidRequestHost(ctx, request) {
+		return rt.virtualHosts.fallback
+	}
+
+	return rt.virtualHosts.match(host)
+}
+
+// MatchRoute - longest path prefix route, nil when no route matches, nil pool when the route's upstream is unknown
+func (s *Server) MatchRoute(routes []config.Route, request *http.Request) (*config.Route, *upstream.Pool) {
+	var matched *config.Route
+
+	for idx := range routes {
+		route := &routes[idx]
+		if !strings.HasPrefix(request.URL.Path, route.PathPrefix) {
+			continue
+		}
+		if matched == nil || len(route.PathPrefix) > len(matched.PathPrefix) {
+			matched = route
+		}
+	}
+
+	if matched == nil {
+		return nil, nil
+	}
+
+	pool, found := s.upstreams.Get(matched.Upstream)
+	if !found {
+		return matched, nil
+	}
+
+	return matched, pool
+}
+
+// eachRoute - call fn for the top level and every virtual host route, stops at the first error
+func eachRoute(cfg *config.Settings, fn func(route *config.Route) error) error {
+	for idx := range cfg.Routes {
+		if err := fn(&cfg.Routes[idx]); err != nil {
+			return err
+		}
+	}
+
+	for vidx := range cfg.VirtualHosts {
+		for idx := range cfg.VirtualHosts[vidx].Routes {
+			if err := fn(&cfg.VirtualHosts[vidx].Routes[idx]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ForwardRequest - forward request to upstream pool
+func (s *Server) ForwardRequest(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request, route *config.Route, pool *upstream.Pool) {
+	method := "server.forwardRequest"
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, shared.KeyUpstreamPool, pool.GetName())).Debugf("%s entering", method)
+
+	outbound := request.Clone(ctx)
+
+	if route.StripPrefix {
+		outbound.URL.Path = strings.TrimPrefix(outbound.URL.Path, route.PathPrefix)
+		if !strings.HasPrefix(outbound.URL.Path, "/") {
+			outbound.URL.Path = "/" + outbound.URL.Path
+		}
+		outbound.URL.RawPath = ""
+	}
+
+	pool.ServeHTTP(responseWriter, outbound)
 }
 
 // doHeadErrorResponse - WARNING HEAD requests should not return a body so normal error response can't be used.
@@ -79,6 +315,10 @@ func (s *Server) DoHeadErrorResponse(ctx context.Context, responseWriter http.Re
 	responseWriter.Header().Add("Content-Length", "0")
 	responseWriter.Header().Add("Content-Type", shared.ContentType_TextHtml)
 
+	if page := s.routing().errorPages.match(httpStatusCode); page != nil {
+		page.applyHeaders(responseWriter)
+	}
+
 	s.WriteHeader(ctx, responseWriter, httpStatusCode)
 }
 
@@ -96,9 +336,19 @@ func (s *Server) DoErrorResponse(ctx context.Context, responseWriter http.Respon
 
 	log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyHTTPResponseStatusCode, httpStatusCode, shared.KeyHTTPResponseBodyContent, htmlMessage)).Errorf("%s returning error response. %s", method, msg)
 
+	// htmlMessage from CreateRequestResponseDetails is already the error page body
+	page := s.routing().errorPages.match(httpStatusCode)
+	if page != nil {
+		page.applyHeaders(responseWriter)
+	}
+
 	s.WriteHeader(ctx, responseWriter, httpStatusCode)
 
-	if len(htmlMessage) > 0 {
+	if page != nil && len(htmlMessage) > 0 {
+		if _, err := io.WriteString(responseWriter, htmlMessage); err != nil {
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, shared.KeyHTTPResponseStatusCode, httpStatusCode, shared.KeyErrorMessage, err.Error())).Errorf("%s write error", method)
+		}
+	} else if len(htmlMessage) > 0 {
 		err := shared.WriteHTML(ctx, responseWriter, htmlMessage)
 		if err != nil {
 			log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, shared.KeyHTTPResponseStatusCode, httpStatusCode, shared.KeyHTTPResponseBodyContent, htmlMessage, shared.KeyErrorMessage, err.Error())).Errorf("%s error calling writeHTML", method)
@@ -127,13 +377,17 @@ func (s *Server) WriteHeader(ctx context.Context, responseWriter http.ResponseWr
 	method := "server.writeHeader"
 	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s writing response header with HTTP Status Code: %d", method, httpStatusCode)
 
-	//s.metrics.IncHTTPService(log, httpStatusCode, time.Duration(1*time.Second))
-
 	responseWriter.WriteHeader(httpStatusCode)
 }
 
+// GenerateHtmlBodyFromTemplate - render the server template, pageBody is trusted markup
 func (s *Server) GenerateHtmlBodyFromTemplate(pageTitle string, pageBody string) string {
-	html := s.responseTemplateFile
+	return s.RenderPage("", s.NewTemplateData(context.Background(), nil, 0, "", pageTitle, pageBody))
+}
+
+// GenerateHtmlBody - replace {{page_title}} and {{page_body}} in template, values are not escaped
+func GenerateHtmlBody(template string, pageTitle string, pageBody string) string {
+	html := template
 
 	html = strings.Replace(html, "{{page_title}}", pageTitle, -1)
 	html = strings.Replace(html, "{{page_body}}", pageBody, -1)
@@ -141,7 +395,13 @@ func (s *Server) GenerateHtmlBodyFromTemplate(pageTitle string, pageBody string)
 	return html
 }
 
+// CreateResponseDetails - status text and rendered page for httpStatusCode, see CreateRequestResponseDetails
 func (s *Server) CreateResponseDetails(httpStatusCode int, reason string) (int, string, string) {
+	return s.CreateRequestResponseDetails(context.Background(), nil, httpStatusCode, reason)
+}
+
+// CreateRequestResponseDetails - status text and page for httpStatusCode rendered with the request, the configured error page when one matches
+func (s *Server) CreateRequestResponseDetails(ctx context.Context, request *http.Request, httpStatusCode int, reason string) (int, string, string) {
 	httpStatusMessage := http.StatusText(httpStatusCode)
 	htmlMessage := strings.ToLower(httpStatusMessage)
 
@@ -151,18 +411,27 @@ func (s *Server) CreateResponseDetails(httpStatusCode int, reason string) (int,
 		title = fmt.Sprintf("%s.%s", httpStatusMessage, reason)
 	}
 
+	body := fmt.Sprintf("HTTP Status: %d (%s)", httpStatusCode, htmlMessage)
 	if len(strings.TrimSpace(reason)) > 0 {
-		htmlMessage = s.GenerateHtmlBodyFromTemplate(title, fmt.Sprintf("HTTP Status: %d (%s, %s)", httpStatusCode, htmlMessage, reason))
-	} else {
-		htmlMessage = s.GenerateHtmlBodyFromTemplate(title, fmt.Sprintf("HTTP Status: %d (%s)", httpStatusCode, htmlMessage))
+		body = fmt.Sprintf("HTTP Status: %d (%s, %s)", httpStatusCode, htmlMessage, html.EscapeString(reason))
 	}
 
+	data := s.NewTemplateData(ctx, request, httpStatusCode, reason, title, body)
+
+	if page := s.routing().errorPages.match(httpStatusCode); page != nil {
+		if !page.isTemplate() {
+			return httpStatusCode, httpStatusMessage, page.body
+		}
+		return httpStatusCode, httpStatusMessage, s.renderPage(page.templateName, page.page, data)
+	}
+
+	htmlMessage = s.RenderPage("", data)
+
 	return httpStatusCode, httpStatusMessage, htmlMessage
 }
 
 // livenessRequestProcessor - liveness processor - is the service alive
 func (s *Server) LivenessRequestProcessor(responseWriter http.ResponseWriter, request *http.Request) {
-	start := time.Now().UTC()
 	method := "server.livenessRequestProcessor"
 	ctx := shared.CreateRequestContext(request, method)
 	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s entering", method)
@@ -170,14 +439,16 @@ func (s *Server) LivenessRequestProcessor(responseWriter http.ResponseWriter, re
 	responseStatus := http.StatusOK
 	responseMessage := "Liveness"
 
-	s.WriteHealthCheckResponse(ctx, responseWriter, responseStatus, responseMessage)
+	if alive, reason := s.health.Alive(); !alive {
+		responseStatus = http.StatusServiceUnavailable
+		responseMessage = reason
+	}
 
-	s.metrics.IncHealthRequest(time.Since(start))
+	s.WriteHealthCheckResponse(ctx, responseWriter, request, responseStatus, responseMessage)
 }
 
 // readinessRequestProcessor - readiness processor
 func (s *Server) ReadinessRequestProcessor(responseWriter http.ResponseWriter, request *http.Request) {
-	start := time.Now().UTC()
 	method := "server.readinessRequestProcessor"
 	ctx := shared.CreateRequestContext(request, method)
 	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s entering", method)
@@ -185,17 +456,33 @@ func (s *Server) ReadinessRequestProcessor(responseWriter http.ResponseWriter, r
 	responseStatus := http.StatusOK
 	responseMessage := "Readiness"
 
-	if s.isShuttingDown {
+	if ready, reason := s.health.Ready(); !ready {
 		responseStatus = http.StatusServiceUnavailable
-		responseMessage = "Server is shutting down."
+		responseMessage = reason
 	}
 
-	s.WriteHealthCheckResponse(ctx, responseWriter, responseStatus, responseMessage)
+	s.WriteHealthCheckResponse(ctx, responseWriter, request, responseStatus, responseMessage)
+}
 
-	s.metrics.IncHealthRequest(time.Since(start))
+// StartupRequestProcessor - startup processor, passes once Init is done and the listener is up
+func (s *Server) StartupRequestProcessor(responseWriter http.ResponseWriter, request *http.Request) {
+	method := "server.startupRequestProcessor"
+	ctx := shared.CreateRequestContext(request, method)
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s entering", method)
+
+	responseStatus := http.StatusOK
+	responseMessage := "Startup"
+
+	if started, reason := s.health.Started(); !started {
+		responseStatus = http.StatusServiceUnavailable
+		responseMessage = reason
+	}
+
+	s.WriteHealthCheckResponse(ctx, responseWriter, request, responseStatus, responseMessage)
 }
 
-func (s *Server) WriteHealthCheckResponse(ctx context.Context, responseWriter http.ResponseWriter, httpStatusCode int, message string) {
+// WriteHealthCheckResponse - html health page, or a health+json report with only the overall status when the request asks for one (see health.WantsReport)
+func (s *Server) WriteHealthCheckResponse(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request, httpStatusCode int, message string) {
 	method := "server.writeHealthCheckHeader"
 	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s writing response header with HTTP Status Code: %d, Message: %s", method, httpStatusCode, message)
 
@@ -204,9 +491,22 @@ func (s *Server) WriteHealthCheckResponse(ctx context.Context, responseWriter ht
 		responseWriter.Header().Add(shared.HttpHeader_Server, nodename)
 	}
 
+	if health.WantsReport(request) {
+		report := health.NewReport(httpStatusCode, message, s.health.Checks.Report())
+		if s.config == nil || !s.config.Health.DetailedReports {
+			// check outputs name internal hosts, the service port is public
+			report = report.Summary()
+		}
+
+		if err = health.WriteReport(responseWriter, httpStatusCode, report); err != nil {
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error writing health report", method)
+		}
+		return
+	}
+
 	responseWriter.WriteHeader(httpStatusCode)
 
-	_, _, htmlMessage := s.CreateResponseDetails(httpStatusCode, message)
+	_, _, htmlMessage := s.CreateRequestResponseDetails(ctx, nil, httpStatusCode, message)
 
 	if len(htmlMessage) > 0 {
 		err = shared.WriteHTML(ctx, responseWriter, htmlMessage)
@@ -218,31 +518,64 @@ func (s *Server) WriteHealthCheckResponse(ctx context.Context, responseWriter ht
 
 // requestProcessor main server func
 func (s *Server) RequestProcessor(responseWriter http.ResponseWriter, request *http.Request) {
-	start := time.Now().UTC()
 	method := "server.requestProcessor"
 	ctx := shared.CreateRequestContext(request, method)
 	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Infof("%s entering", method)
 
+	resolved, ok := s.requestRoutingFor(responseWriter, request)
+	if !ok {
+		return
+	}
+	rt, vhost := resolved.rt, resolved.vhost
+
+	route, pool := s.MatchRoute(resolved.routes(), request)
+	if route != nil {
+		setMetricsRoute(request, route.PathPrefix)
+	}
+
+	if route != nil && route.Response != nil {
+		s.serveCannedResponse(ctx, responseWriter, request, route, rt.cannedResponses[route.Response])
+		return
+	}
+
+	if route != nil && len(route.Upstream) > 0 {
+		if pool == nil {
+			httpStatusCode, httpStatusMessage, htmlMessage := s.CreateRequestResponseDetails(ctx, request, http.StatusBadGateway, fmt.Sprintf("unknown upstream '%s'", route.Upstream))
+			s.DoErrorResponse(ctx, responseWriter, request, httpStatusCode, htmlMessage, errors.New(httpStatusMessage))
+		} else {
+			s.ForwardRequest(ctx, responseWriter, request, route, pool)
+		}
+		return
+	}
+
 	if request.Method == "HEAD" { // head request responses shouldn't return a body
 		s.DoHeadErrorResponse(ctx, responseWriter, request, http.StatusBadRequest, "Verb: HEAD - not supported")
-		s.metrics.IncServiceRequest(time.Since(start))
 		return
 	}
 
 	// get host name from request
-	_, err := shared.GetHost(ctx, request)
+	host, err := shared.GetHost(ctx, request)
 	if err != nil {
-		httpStatusCode, httpStatusMessage, htmlMessage := s.CreateResponseDetails(http.StatusBadRequest, err.Error())
+		httpStatusCode, httpStatusMessage, htmlMessage := s.CreateRequestResponseDetails(ctx, request, http.StatusBadRequest, err.Error())
 		s.DoErrorResponse(ctx, responseWriter, request, httpStatusCode, htmlMessage, errors.New(httpStatusMessage))
-		s.metrics.IncServiceRequest(time.Since(start))
 		return
 	}
 
-	htmlMessage := fmt.Sprintf("Request from '%s' to Host: '%s', URL: '%v'", request.RemoteAddr, request.Host, request.URL)
+	htmlMessage := fmt.Sprintf("Request from '%s' to Host: '%s', URL: '%s'", html.EscapeString(request.RemoteAddr), html.EscapeString(request.Host), html.EscapeString(request.URL.String()))
 
-	s.DoValidRequestResponse(ctx, responseWriter, request, htmlMessage)
+	if claims, found := auth.ClaimsFromContext(ctx); found {
+		if claimsJSON, err := shared.Struct2JSONString(claims); err == nil {
+			htmlMessage = fmt.Sprintf("%s, Claims: %s", htmlMessage, html.EscapeString(*claimsJSON))
+		}
+	}
+
+	if vhost != nil {
+		htmlMessage = s.renderPage(vhost.Config.Template, vhost.page, s.NewTemplateData(ctx, request, http.StatusOK, "", host, htmlMessage))
+	} else if rt.templates != nil {
+		htmlMessage = s.RenderPage("", s.NewTemplateData(ctx, request, http.StatusOK, "", host, htmlMessage))
+	}
 
-	s.metrics.IncServiceRequest(time.Since(start))
+	s.DoValidRequestResponse(ctx, responseWriter, request, htmlMessage)
 }
 
 // Init - setup server
@@ -259,12 +592,224 @@ func (s *Server) Init() {
 
 	// Init settings
 	s.isInitialized = true
-	s.isShuttingDown = false
 	s.router = nil
 	s.server = nil
 
-	// Load Response Template HTML File
-	s.responseTemplateFile = shared.LoadHTMLFile(s.context, "", DefaultResponseTemplateFile)
+	// Setup templates, outbound client and upstream pools
+	if s.config != nil {
+		rt := &routing{config: s.config}
+		defer s.live.Store(rt)
+
+		if len(s.config.Templates.Directory) > 0 {
+			templates, err := newTemplateSet(s.config.Templates)
+			if err != nil {
+				log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error loading templates, using the server template", method)
+			} else {
+				rt.templates = templates
+			}
+		}
+
+		if len(s.config.ErrorPages) > 0 {
+			errorPages, err := newErrorPages(s.config.ErrorPages, rt.templates)
+			if err != nil {
+				log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error loading error pages, using the server template", method)
+			} else {
+				rt.errorPages = errorPages
+			}
+		}
+
+		s.outbound = outbound.New(s.config.Outbound, nil, nil, s.metrics)
+
+		upstreams, err := upstream.NewRegistry(s.config.Upstreams, s.outbound)
+		if err != nil {
+			log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error creating upstream pools, forwarding disabled", method)
+		} else {
+			s.upstreams = upstreams
+		}
+
+		if len(s.config.VirtualHosts) > 0 {
+			virtualHosts, err := newVirtualHosts(s.context, s.config.VirtualHosts, s.responseTemplateFile, rt.templates)
+			if err != nil {
+				log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error creating virtual hosts, virtual hosts disabled", method)
+			} else {
+				rt.virtualHosts = virtualHosts
+			}
+		}
+
+		if s.config.Compression.Enabled {
+			compressor, err := compress.New(s.config.Compression, s.metrics)
+			if err != nil {
+				log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error creating compressor, compression disabled", method)
+			} else {
+				s.compressor = compressor
+			}
+		}
+
+		cannedResponses, err := newCannedResponses(s.config, s.started)
+		if err != nil {
+			log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error loading canned responses", method)
+		} else {
+			rt.cannedResponses = cannedResponses
+		}
+
+		for _, cfg := range s.config.Static {
+			mount, err := newStaticMount(cfg)
+			if err != nil {
+				log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error creating static mount '%s', mount disabled", method, cfg.PathPrefix)
+				continue
+			}
+			s.staticMounts = append(s.staticMounts, mount)
+		}
+
+		if err := s.validateHealthPaths(s.healthPaths()); err != nil {
+			log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s invalid health paths, using the default health paths", method)
+			s.defaultHealthPaths = true
+		}
+
+		corsPolicies, err := newCORSPolicies(s.config)
+		if err != nil {
+			log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error creating cors policies, cross origin requests will not be allowed", method)
+		} else {
+			rt.corsPolicies = corsPolicies
+		}
+
+		if len(s.config.JWT.JWKSFile) > 0 || len(s.config.JWT.PEMDirectory) > 0 {
+			verifier, err := auth.NewVerifier(s.config.JWT, nil)
+			if err != nil {
+				log.WithFields(shared.GetFields(s.context, shared.EventTypeError, true, shared.KeyErrorMessage, err.Error())).Errorf("%s error creating jwt verifier, all requests will be rejected", method)
+				s.verifierErr = err
+			} else {
+				s.verifier = verifier
+			}
+		}
+
+		if len(s.config.Health.Checks) > 0 {
+			checks, err := health.NewRegistryFromConfig(s.config.Health, nil)
+			if err != nil {
+				log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error creating health checks, health checks disabled", method)
+			} else {
+				s.health.Checks = checks
+			}
+		}
+
+		watchdog, err := health.NewWatchdog(s.config.Health.Watchdog, s.health)
+		if err != nil {
+			log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error creating watchdog, watchdog disabled", method)
+		} else {
+			s.watchdog = watchdog
+		}
+
+		s.metrics.EnableExpHandler()
+		s.metrics.SetRouteMaxSeries(s.config.Metrics.RouteMaxSeries)
+
+		var serviceRequests metrics.Timer
+		if met, ok := s.metrics.(*gometrics.GoMetrics); ok {
+			serviceRequests = met.TrackedMetrics.ServiceRequest
+
+			statsd, err := met.NewStatsDExporter(s.config.Metrics.StatsD, nil)
+			if err != nil {
+				log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error creating statsd exporter, statsd exporter disabled", method)
+			} else if statsd != nil {
+				s.exporters = append(s.exporters, statsd)
+			}
+
+			graphite, err := met.NewGraphiteExporter(s.config.Metrics.Graphite, nil)
+			if err != nil {
+				log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error creating graphite exporter, graphite exporter disabled", method)
+			} else if graphite != nil {
+				s.exporters = append(s.exporters, graphite)
+			}
+		}
+
+		profiles, err := profiler.NewProfiler(s.config.Profiler, serviceRequests)
+		if err != nil {
+			log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error creating profiler, profiler disabled", method)
+		} else {
+			s.profiler = profiles
+		}
+
+		adminToken, err := adminToken(s.config.Admin)
+		if err != nil {
+			log.WithFields(shared.GetFields(s.context, shared.EventTypeError, true, shared.KeyErrorMessage, err.Error())).Errorf("%s error reading admin token, admin api disabled", method)
+		} else {
+			s.adminToken = adminToken
+		}
+
+		if len(s.config.Mirror.URL) > 0 {
+			mirror, err := newMirror(s.config.Mirror, s.config.Outbound, s.metrics)
+			if err != nil {
+				log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error creating mirror, mirroring disabled", method)
+			} else {
+				s.mirror = mirror
+			}
+		}
+	}
+}
+
+type builtinRoute struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// builtinRoutes - service port routes besides the health paths, the exp handler and the static mounts
+func (s *Server) builtinRoutes() []builtinRoute {
+	return []builtinRoute{
+		{"/redirect/", s.RedirectProcessor},
+		{"/redirect-to", s.RedirectToProcessor},
+		{"/cookies", s.CookiesProcessor},
+		{"/cookies/", s.CookiesProcessor},
+		{"/basic-auth/", s.BasicAuthProcessor},
+		{"/bearer", s.BearerProcessor},
+		{"/status/", s.StatusProcessor},
+		{"/bytes/", s.BytesProcessor},
+		{"/", s.Authenticate(s.RequestProcessor)},
+	}
+}
+
+// validateHealthPaths - health paths must start with / and be distinct patterns on the service port router
+func (s *Server) validateHealthPaths(paths ...string) error {
+	reserved := map[string]string{GoMetricsPath: "exp handler"}
+	for _, route := range s.builtinRoutes() {
+		reserved[route.pattern] = "built in route"
+	}
+	for _, mount := range s.staticMounts {
+		reserved[mount.prefix] = "static mount"
+	}
+
+	for _, path := range paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("health path '%s' must start with /", path)
+		}
+		if owner, found := reserved[path]; found {
+			return fmt.Errorf("health path '%s' is already used by a %s", path, owner)
+		}
+		reserved[path] = "health path"
+	}
+
+	return nil
+}
+
+// Handler - create the router and wrap it with the server wide middleware
+func (s *Server) Handler() http.Handler {
+	s.router = http.NewServeMux()
+	livenessPath, readinessPath, startupPath := s.healthPaths()
+	s.router.HandleFunc(livenessPath, s.LivenessRequestProcessor)
+	s.router.HandleFunc(readinessPath, s.ReadinessRequestProcessor)
+	s.router.HandleFunc(startupPath, s.StartupRequestProcessor)
+	for _, route := range s.builtinRoutes() {
+		s.router.HandleFunc(route.pattern, route.handler)
+	}
+	if met, ok := s.metrics.(*gometrics.GoMetrics); ok && met.ExpHandler != nil {
+		s.router.Handle(GoMetricsPath, met.ExpHandler)
+	}
+	s.registerStaticMounts(s.router)
+
+	handler := s.resolveHosts(s.mirrorRequests(s.corsRequests(s.router)))
+	if s.compressor != nil {
+		handler = s.compressor.Handler(handler)
+	}
+
+	return s.recordMetrics(s.trackRequests(handler), s.metricsGroup, s.metricsRoute(s.router))
 }
 
 // Run - start server and listen
@@ -273,14 +818,7 @@ func (s *Server) Run() {
 	method := "server.Run"
 	log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false)).Infof("%s entering...", method)
 
-	// setup handler
-	s.router = http.NewServeMux()
-	s.router.HandleFunc("/healthz/livenessZ76", s.LivenessRequestProcessor)
-	s.router.HandleFunc("/healthz/readinessZ67", s.ReadinessRequestProcessor)
-	s.router.HandleFunc("/", s.RequestProcessor)
-	if met, ok := s.metrics.(*gometrics.GoMetrics); ok {
-		s.router.Handle("/debug/gometrics", met.ExpHandler)
-	}
+	handler := s.Handler()
 
 	log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false, shared.KeyServerAddress, s.config.Service.HTTP.Server.IPv4Address)).Infof("%s server address", method)
 
@@ -292,9 +830,9 @@ func (s *Server) Run() {
 	// locations and devices with slow connections.
 
 	// setup server
-	s.server = &http.Server{
+	httpServer := &http.Server{
 		Addr:              net.JoinHostPort(s.config.Service.HTTP.Server.IPv4Address, strconv.FormatUint(uint64(s.config.Service.HTTP.Server.Port), 10)),
-		Handler:           s.router,
+		Handler:           handler,
 		ReadTimeout:       30 * time.Second, // Maximum duration for reading the entire request, including the body.
 		ReadHeaderTimeout: 0,                // Amount of time allowed to read request headers. If zero, the value of ReadTimeout is used. If both are zero, there is no timeout.
 		WriteTimeout:      30 * time.Second, // Maximum duration before timing out writes of the response.
@@ -304,41 +842,59 @@ func (s *Server) Run() {
 		//ErrorLog:          logger,
 	}
 
-	defer s.server.Close()
-
-	err := s.server.ListenAndServe()
+	s.serverMu.Lock()
+	s.server = httpServer
+	s.serverMu.Unlock()
 
-	s.isShuttingDown = true
-
-	if err != nil && err != http.ErrServerClosed {
-		log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyServerAddress, s.config.Service.HTTP.Server.IPv4Address, shared.KeyErrorMessage, err.Error())).Errorf("%s server listen error", method)
+	if !s.transition(StateServing, StateStarting) {
+		log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, "lifecycle.state", s.GetState().String())).Errorf("%s server is not starting, not listening", method)
 		return
 	}
 
-	log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false)).Infof("%s existing", method)
-}
+	if s.upstreams != nil {
+		s.upstreams.Start(s.context)
+		defer s.upstreams.Stop()
+	}
 
-// Shutdown - Shutdown server
-func (s *Server) Shutdown() {
-	//nolint
-	method := "server.Shutdown"
-	log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false)).Infof("%s entering", method)
+	s.health.Checks.Start(s.context)
+	defer s.health.Checks.Stop()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	s.watchdog.Start(s.context)
+	defer s.watchdog.Stop()
 
-	s.isShuttingDown = true
+	s.profiler.Start(s.context)
+	defer s.profiler.Stop()
 
-	duration, err := time.ParseDuration(s.config.Service.GracefulShutdownDelaySeconds)
-	if err != nil {
-		duration, _ = time.ParseDuration("10s")
-		log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false, shared.KeyErrorMessage, err.Error())).Errorf("%s server parsing Service.GracefulShutdownDelaySeconds, defaulting to: %s", method, duration.String())
+	for _, exporter := range s.exporters {
+		exporter.Start()
+		defer exporter.Stop()
 	}
 
-	time.Sleep(duration)
+	if templates := s.routing().templates; templates != nil {
+		templates.Start(s.context)
+	}
+	// Reload may have replaced the template set
+	defer func() {
+		if templates := s.routing().templates; templates != nil {
+			templates.Stop()
+		}
+	}()
 
-	if err = s.server.Shutdown(ctx); err != nil {
-		log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false, shared.KeyErrorMessage, err.Error())).Errorf("%s server error while shutting down", method)
-		panic(err)
+	// the startup probe passes once this listener and the ones added to the health state are up
+	listener, err := net.Listen("tcp", httpServer.Addr)
+	if err == nil {
+		s.health.SetStarted()
+		err = httpServer.Serve(listener)
 	}
+
+	if err != nil && err != http.ErrServerClosed {
+		s.transition(StateStopped, StateServing)
+		log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyServerAddress, s.config.Service.HTTP.Server.IPv4Address, shared.KeyErrorMessage, err.Error())).Errorf("%s server listen error", method)
+		return
+	}
+
+	// the listeners are closed, wait for Shutdown to finish draining
+	<-s.stopped
+
+	log.WithFields(shared.GetFields(s.context, shared.EventTypeInfo, false)).Infof("%s existing", method)
 }
@@ -2,7 +2,12 @@ package server_test
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -97,3 +102,255 @@ func Test_generateHtmlBodyFromTemplate(t *testing.T) {
 		assert.Equal(tc.Expected, actual, tc.Description)
 	}
 }
+
+func Test_MatchRoute(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := &config.Settings{
+		Upstreams: []config.UpstreamPool{
+			{Name: "api", Targets: []string{"http://api.test"}},
+			{Name: "apiv2", Targets: []string{"http://apiv2.test"}},
+		},
+		Routes: []config.Route{
+			{PathPrefix: "/api/", Upstream: "api"},
+			{PathPrefix: "/api/v2/", Upstream: "apiv2"},
+			{PathPrefix: "/missing/", Upstream: "missing"},
+		},
+	}
+	svc := server.NewServer("TestServiceName", cfg, nil)
+	svc.Init()
+
+	testCases := []struct {
+		Path          string
+		ExpectedRoute string
+		ExpectedPool  string
+		Description   string
+	}{
+		{
+			Path:          "/",
+			ExpectedRoute: "",
+			ExpectedPool:  "",
+			Description:   "no route",
+		},
+		{
+			Path:          "/api/users",
+			ExpectedRoute: "/api/",
+			ExpectedPool:  "api",
+			Description:   "prefix route",
+		},
+		{
+			Path:          "/api/v2/users",
+			ExpectedRoute: "/api/v2/",
+			ExpectedPool:  "apiv2",
+			Description:   "longest prefix wins",
+		},
+		{
+			Path:          "/missing/thing",
+			ExpectedRoute: "/missing/",
+			ExpectedPool:  "",
+			Description:   "route with unknown upstream",
+		},
+	}
+
+	for _, tc := range testCases {
+		route, pool := svc.MatchRoute(svc.GetRoutes(), httptest.NewRequest(http.MethodGet, tc.Path, nil))
+
+		if len(tc.ExpectedRoute) == 0 {
+			assert.Nil(route, tc.Description)
+		} else {
+			assert.Equal(tc.ExpectedRoute, route.PathPrefix, tc.Description)
+		}
+
+		if len(tc.ExpectedPool) == 0 {
+			assert.Nil(pool, tc.Description)
+		} else {
+			assert.Equal(tc.ExpectedPool, pool.GetName(), tc.Description)
+		}
+	}
+}
+
+func Test_Mirror(t *testing.T) {
+	assert := assert.New(t)
+
+	type shadowRequest struct {
+		path    string
+		body    string
+		headers http.Header
+	}
+	shadowRequests := make(chan shadowRequest, 2)
+	shadow := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		assert.Equal("true", request.Header.Get(server.HttpHeader_XShadowRequest))
+		shadowRequests <- shadowRequest{path: request.URL.Path, body: string(body), headers: request.Header}
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer shadow.Close()
+
+	primary := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		body, _ := io.ReadAll(request.Body)
+		_, _ = responseWriter.Write(body)
+	}))
+	defer primary.Close()
+
+	cfg := &config.Settings{
+		Upstreams:    []config.UpstreamPool{{Name: "primary", Targets: []string{primary.URL}}},
+		Routes:       []config.Route{{PathPrefix: "/api/", Upstream: "primary"}},
+		Mirror:       config.Mirror{URL: shadow.URL, Percentage: 100},
+		VirtualHosts: []config.VirtualHost{{Hosts: []string{"api.example.test"}, Routes: []config.Route{{PathPrefix: "/api/", Upstream: "primary"}}}},
+	}
+	svc := server.NewServer("TestServiceName", cfg, nil)
+	svc.Init()
+	handler := svc.Handler()
+
+	received := func() *shadowRequest {
+		select {
+		case shadowRequest := <-shadowRequests:
+			return &shadowRequest
+		case <-time.After(2 * time.Second):
+			return nil
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "http://api.example.test/api/echo", strings.NewReader("payload"))
+	request.Header.Set(server.HttpHeader_Authorization, "Bearer secret")
+	request.Header.Set("Cookie", "session=secret")
+	request.Header.Set("X-Api-Key", "secret")
+	request.Header.Set("X-Request-Id", "42")
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal(http.StatusOK, recorder.Code, "shadow failure must not affect the primary response")
+	assert.Equal("payload", recorder.Body.String(), "primary still receives the full body")
+
+	if shadowRequest := received(); assert.NotNil(shadowRequest, "shadow request not received") {
+		assert.Equal("/api/echo", shadowRequest.path)
+		assert.Equal("payload", shadowRequest.body, "shadow receives the body")
+		assert.Equal("", shadowRequest.headers.Get(server.HttpHeader_Authorization), "authorization stripped")
+		assert.Equal("", shadowRequest.headers.Get("Cookie"), "cookie stripped")
+		assert.Equal("", shadowRequest.headers.Get("X-Api-Key"), "api key stripped")
+		assert.Equal("42", shadowRequest.headers.Get("X-Request-Id"), "other headers copied")
+	}
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "http://unknown.test/status/200", nil)
+	handler.ServeHTTP(recorder, request)
+	assert.Equal(http.StatusMisdirectedRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "http://api.example.test/status/200", nil)
+	handler.ServeHTTP(recorder, request)
+
+	if shadowRequest := received(); assert.NotNil(shadowRequest, "shadow request not received") {
+		assert.Equal("/status/200", shadowRequest.path, "behavior routes are mirrored, unknown hosts are not")
+	}
+}
+
+func Test_VirtualHosts(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := &config.Settings{
+		VirtualHosts: []config.VirtualHost{
+			{Hosts: []string{"api.example.test"}, Headers: map[string]string{"X-VHost": "api"}},
+			{Hosts: []string{"*.example.test"}, Headers: map[string]string{"X-VHost": "wildcard"}},
+			{Hosts: []string{"*.deep.example.test"}, Headers: map[string]string{"X-VHost": "deep"}},
+		},
+	}
+	cfg.Service.UnknownHostStatus = http.StatusNotFound
+
+	svc := server.NewServer("TestServiceName", cfg, nil)
+	svc.Init()
+
+	testCases := []struct {
+		Host           string
+		ExpectedStatus int
+		ExpectedVHost  string
+		Description    string
+	}{
+		{
+			Host:           "api.example.test",
+			ExpectedStatus: http.StatusOK,
+			ExpectedVHost:  "api",
+			Description:    "exact match",
+		},
+		{
+			Host:           "API.Example.Test:8081",
+			ExpectedStatus: http.StatusOK,
+			ExpectedVHost:  "api",
+			Description:    "exact match ignores case and port",
+		},
+		{
+			Host:           "www.example.test",
+			ExpectedStatus: http.StatusOK,
+			ExpectedVHost:  "wildcard",
+			Description:    "wildcard match",
+		},
+		{
+			Host:           "a.deep.example.test",
+			ExpectedStatus: http.StatusOK,
+			ExpectedVHost:  "deep",
+			Description:    "longest wildcard wins",
+		},
+		{
+			Host:           "example.test",
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedVHost:  "",
+			Description:    "wildcard does not match apex, configured unknown host status",
+		},
+		{
+			Host:           "127.0.0.1",
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedVHost:  "",
+			Description:    "invalid host without default",
+		},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Host = tc.Host
+
+		svc.RequestProcessor(recorder, request)
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+		assert.Equal(tc.ExpectedVHost, recorder.Header().Get("X-VHost"), tc.Description)
+	}
+
+	handler := svc.Handler()
+	routerCases := []struct {
+		Host           string
+		Path           string
+		ExpectedStatus int
+		ExpectedVHost  string
+		Description    string
+	}{
+		{Host: "example.test", Path: "/status/200", ExpectedStatus: http.StatusNotFound, Description: "behavior route, unknown host"},
+		{Host: "example.test", Path: "/bytes/8", ExpectedStatus: http.StatusNotFound, Description: "bytes route, unknown host"},
+		{Host: "api.example.test", Path: "/bytes/8", ExpectedStatus: http.StatusOK, ExpectedVHost: "api", Description: "bytes route, known host"},
+		{Host: "10.0.0.1", Path: server.DefaultLivenessPath, ExpectedStatus: http.StatusOK, Description: "health paths answer any host"},
+	}
+
+	for _, tc := range routerCases {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, tc.Path, nil)
+		request.Host = tc.Host
+
+		handler.ServeHTTP(recorder, request)
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+		assert.Equal(tc.ExpectedVHost, recorder.Header().Get("X-VHost"), tc.Description)
+	}
+
+	cfg.VirtualHosts = append(cfg.VirtualHosts, config.VirtualHost{Hosts: []string{"*"}, Headers: map[string]string{"X-VHost": "default"}})
+	cfg.Service.UnknownHostStatus = 0
+	svc = server.NewServer("TestServiceName", cfg, nil)
+	svc.Init()
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Host = "other.test"
+	svc.RequestProcessor(recorder, request)
+
+	assert.Equal(http.StatusOK, recorder.Code, "default virtual host")
+	assert.Equal("default", recorder.Header().Get("X-VHost"), "default virtual host")
+	assert.Equal(http.StatusMisdirectedRequest, svc.GetUnknownHostStatus(), "default unknown host status")
+}
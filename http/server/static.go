@@ -0,0 +1,276 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+const (
+	DefaultStaticIndex = "index.html"
+
+	HttpHeader_CacheControl = "Cache-Control"
+	HttpHeader_Allow        = "Allow"
+)
+
+// staticMount is a validated config.Static
+type staticMount struct {
+	prefix       string
+	root         http.Dir
+	index        string
+	listing      bool
+	spa          bool
+	cacheControl []config.StaticCacheRule
+}
+
+func newStaticMount(cfg config.Static) (*staticMount, error) {
+	m := staticMount{
+		prefix:       cfg.PathPrefix,
+		root:         http.Dir(cfg.Directory),
+		index:        cfg.Index,
+		listing:      cfg.Listing,
+		spa:          cfg.SPA,
+		cacheControl: cfg.CacheControl,
+	}
+
+	if !strings.HasPrefix(m.prefix, "/") {
+		m.prefix = "/" + m.prefix
+	}
+	if !strings.HasSuffix(m.prefix, "/") {
+		m.prefix += "/"
+	}
+
+	if len(m.index) == 0 {
+		m.index = DefaultStaticIndex
+	}
+
+	info, err := os.Stat(cfg.Directory)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("static directory '%s' is not a directory", cfg.Directory)
+	}
+
+	for _, rule := range m.cacheControl {
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid static cache control pattern '%s'", rule.Pattern)
+		}
+	}
+
+	return &m, nil
+}
+
+// cacheControlFor - Cache-Control of the first rule matching name (slash separated, relative to the root), empty when none match
+func (m *staticMount) cacheControlFor(name string) string {
+	name = strings.TrimPrefix(name, "/")
+
+	for _, rule := range m.cacheControl {
+		if matched, _ := path.Match(rule.Pattern, name); matched {
+			return rule.Value
+		}
+		if matched, _ := path.Match(rule.Pattern, path.Base(name)); matched {
+			return rule.Value
+		}
+	}
+
+	return ""
+}
+
+// registerStaticMounts - add the static mounts to router, mounts colliding with an existing pattern are skipped
+func (s *Server) registerStaticMounts(router *http.ServeMux) {
+	method := "server.registerStaticMounts"
+
+	for _, mount := range s.staticMounts {
+		if _, pattern := router.Handler(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: mount.prefix}}); pattern == mount.prefix {
+			log.WithFields(shared.GetFields(s.context, shared.EventTypeError, false, shared.KeyErrorMessage, "pattern already registered")).Errorf("%s static mount '%s' skipped", method, mount.prefix)
+			continue
+		}
+
+		router.HandleFunc(mount.prefix, s.staticHandler(mount))
+	}
+}
+
+// isHidden - any path segment is a dot file
+func isHidden(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// staticHandler - serve files for a static mount
+func (s *Server) staticHandler(mount *staticMount) http.HandlerFunc {
+	return func(responseWriter http.ResponseWriter, request *http.Request) {
+		method := "server.staticHandler"
+		ctx := shared.CreateRequestContext(request, method)
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Infof("%s entering", method)
+
+		if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			responseWriter.Header().Set(HttpHeader_Allow, "GET, HEAD")
+			httpStatusCode, httpStatusMessage, htmlMessage := s.CreateRequestResponseDetails(ctx, request, http.StatusMethodNotAllowed, "")
+			s.DoErrorResponse(ctx, responseWriter, request, httpStatusCode, htmlMessage, errors.New(httpStatusMessage))
+			return
+		}
+
+		name := path.Clean("/" + strings.TrimPrefix(request.URL.Path, mount.prefix))
+
+		if isHidden(name) {
+			s.staticNotFound(ctx, responseWriter, request)
+			return
+		}
+
+		file, err := mount.root.Open(name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && mount.spa && len(path.Ext(name)) == 0 {
+				s.serveStaticFile(ctx, responseWriter, request, mount, "/"+mount.index)
+				return
+			}
+			s.staticNotFound(ctx, responseWriter, request)
+			return
+		}
+
+		info, err := file.Stat()
+		file.Close()
+		if err != nil {
+			s.staticNotFound(ctx, responseWriter, request)
+			return
+		}
+
+		if !info.IsDir() {
+			s.serveStaticFile(ctx, responseWriter, request, mount, name)
+			return
+		}
+
+		// directories are always addressed with a trailing slash so relative links resolve
+		if !strings.HasSuffix(request.URL.Path, "/") {
+			location := request.URL.Path + "/"
+			if len(request.URL.RawQuery) > 0 {
+				location += "?" + request.URL.RawQuery
+			}
+			responseWriter.Header().Set(HttpHeader_Location, location)
+			s.WriteHeader(ctx, responseWriter, http.StatusMovedPermanently)
+			return
+		}
+
+		index := path.Join(name, mount.index)
+		if indexFile, err := mount.root.Open(index); err == nil {
+			indexFile.Close()
+			s.serveStaticFile(ctx, responseWriter, request, mount, index)
+			return
+		}
+
+		if mount.listing {
+			s.serveStaticListing(ctx, responseWriter, request, mount, name)
+			return
+		}
+
+		s.staticNotFound(ctx, responseWriter, request)
+	}
+}
+
+func (s *Server) staticNotFound(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request) {
+	httpStatusCode, httpStatusMessage, htmlMessage := s.CreateRequestResponseDetails(ctx, request, http.StatusNotFound, "")
+	s.DoErrorResponse(ctx, responseWriter, request, httpStatusCode, htmlMessage, errors.New(httpStatusMessage))
+}
+
+// serveStaticFile - content type from the file extension, conditional and range requests via http.ServeContent
+func (s *Server) serveStaticFile(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request, mount *staticMount, name string) {
+	method := "server.serveStaticFile"
+
+	file, err := mount.root.Open(name)
+	if err != nil {
+		s.staticNotFound(ctx, responseWriter, request)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		s.staticNotFound(ctx, responseWriter, request)
+		return
+	}
+
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, "fileName", name)).Debugf("%s serving file", method)
+
+	shared.AddUniversalHeaders(ctx, responseWriter, s.serviceName)
+
+	if cacheControl := mount.cacheControlFor(name); len(cacheControl) > 0 {
+		responseWriter.Header().Set(HttpHeader_CacheControl, cacheControl)
+	}
+
+	http.ServeContent(responseWriter, request, info.Name(), info.ModTime(), file)
+}
+
+// serveStaticListing - directory listing rendered through the response template
+func (s *Server) serveStaticListing(ctx context.Context, responseWriter http.ResponseWriter, request *http.Request, mount *staticMount, name string) {
+	method := "server.serveStaticListing"
+
+	directory, err := mount.root.Open(name)
+	if err != nil {
+		s.staticNotFound(ctx, responseWriter, request)
+		return
+	}
+	defer directory.Close()
+
+	entries, err := directory.Readdir(-1)
+	if err != nil {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error reading directory", method)
+		httpStatusCode, httpStatusMessage, htmlMessage := s.CreateRequestResponseDetails(ctx, request, http.StatusInternalServerError, "")
+		s.DoErrorResponse(ctx, responseWriter, request, httpStatusCode, htmlMessage, errors.New(httpStatusMessage))
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var body strings.Builder
+	body.WriteString("<ul>")
+	if name != "/" {
+		body.WriteString("<li><a href=\"../\">../</a></li>")
+	}
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if strings.HasPrefix(entryName, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		href := (&url.URL{Path: entryName}).String()
+		body.WriteString(fmt.Sprintf("<li><a href=\"%s\">%s</a></li>", html.EscapeString(href), html.EscapeString(entryName)))
+	}
+	body.WriteString("</ul>")
+
+	title := "Index of " + path.Join(mount.prefix, name)
+	if !strings.HasSuffix(title, "/") {
+		title += "/"
+	}
+
+	shared.AddUniversalHeaders(ctx, responseWriter, s.serviceName)
+	responseWriter.Header().Set(shared.HttpHeader_ContentType, shared.ContentType_TextHtml)
+	s.WriteHeader(ctx, responseWriter, http.StatusOK)
+
+	if request.Method == http.MethodHead {
+		return
+	}
+
+	if _, err = responseWriter.Write([]byte(s.RenderPage("", s.NewTemplateData(ctx, request, http.StatusOK, "", title, body.String())))); err != nil {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s write error", method)
+	}
+}
@@ -0,0 +1,177 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/server"
+)
+
+func createStaticTestServer(t *testing.T) http.Handler {
+	directory := t.TempDir()
+
+	files := map[string]string{
+		"index.html":       "<html>app</html>",
+		"assets/app.js":    "console.log('app')",
+		"assets/app.css":   "body {}",
+		"docs/readme.txt":  "readme",
+		"docs/a&b <c>.txt": "odd",
+		".env":             "SECRET=1",
+	}
+	for name, content := range files {
+		fileName := filepath.Join(directory, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(fileName), 0700))
+		assert.Nil(t, os.WriteFile(fileName, []byte(content), 0600))
+	}
+
+	cfg := config.Settings{}
+	cfg.Static = []config.Static{
+		{
+			PathPrefix: "/app",
+			Directory:  directory,
+			SPA:        true,
+			CacheControl: []config.StaticCacheRule{
+				{Pattern: "assets/*.js", Value: "public, max-age=31536000, immutable"},
+				{Pattern: "*.html", Value: "no-cache"},
+			},
+		},
+		{
+			PathPrefix: "/files/",
+			Directory:  directory,
+			Listing:    true,
+			Index:      "missing.html",
+		},
+		{
+			PathPrefix: "/status/",
+			Directory:  directory,
+		},
+	}
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+
+	return svc.Handler()
+}
+
+func Test_StaticFiles(t *testing.T) {
+	assert := assert.New(t)
+	handler := createStaticTestServer(t)
+
+	testCases := []struct {
+		Method               string
+		Path                 string
+		ExpectedStatus       int
+		ExpectedContentType  string
+		ExpectedCacheControl string
+		ExpectedBody         string
+		Description          string
+	}{
+		{
+			Method:               http.MethodGet,
+			Path:                 "/app/",
+			ExpectedStatus:       http.StatusOK,
+			ExpectedContentType:  "text/html; charset=utf-8",
+			ExpectedCacheControl: "no-cache",
+			ExpectedBody:         "<html>app</html>",
+			Description:          "index file",
+		},
+		{
+			Method:               http.MethodGet,
+			Path:                 "/app/assets/app.js",
+			ExpectedStatus:       http.StatusOK,
+			ExpectedContentType:  "text/javascript; charset=utf-8",
+			ExpectedCacheControl: "public, max-age=31536000, immutable",
+			ExpectedBody:         "console.log('app')",
+			Description:          "mime type and cache rule",
+		},
+		{
+			Method:              http.MethodGet,
+			Path:                "/app/assets/app.css",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "text/css; charset=utf-8",
+			ExpectedBody:        "body {}",
+			Description:         "no cache rule",
+		},
+		{
+			Method:               http.MethodGet,
+			Path:                 "/app/settings/profile",
+			ExpectedStatus:       http.StatusOK,
+			ExpectedContentType:  "text/html; charset=utf-8",
+			ExpectedCacheControl: "no-cache",
+			ExpectedBody:         "<html>app</html>",
+			Description:          "spa fallback",
+		},
+		{
+			Method:         http.MethodGet,
+			Path:           "/app/assets/missing.js",
+			ExpectedStatus: http.StatusNotFound,
+			Description:    "missing asset is not a spa route",
+		},
+		{
+			Method:         http.MethodGet,
+			Path:           "/app/.env",
+			ExpectedStatus: http.StatusNotFound,
+			Description:    "dot files are hidden",
+		},
+		{
+			Method:         http.MethodPost,
+			Path:           "/app/",
+			ExpectedStatus: http.StatusMethodNotAllowed,
+			Description:    "read only",
+		},
+		{
+			Method:         http.MethodGet,
+			Path:           "/files/assets",
+			ExpectedStatus: http.StatusMovedPermanently,
+			Description:    "directory redirect",
+		},
+		{
+			Method:         http.MethodGet,
+			Path:           "/status/200",
+			ExpectedStatus: http.StatusOK,
+			Description:    "mount colliding with a built in endpoint is skipped",
+		},
+	}
+
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(tc.Method, "http://localhost"+tc.Path, nil))
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+		if len(tc.ExpectedContentType) > 0 {
+			assert.Equal(tc.ExpectedContentType, recorder.Header().Get("Content-Type"), tc.Description)
+		}
+		assert.Equal(tc.ExpectedCacheControl, recorder.Header().Get(server.HttpHeader_CacheControl), tc.Description)
+		if len(tc.ExpectedBody) > 0 {
+			assert.Equal(tc.ExpectedBody, recorder.Body.String(), tc.Description)
+		}
+	}
+}
+
+func Test_StaticListing(t *testing.T) {
+	assert := assert.New(t)
+	handler := createStaticTestServer(t)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/files/docs/", nil))
+
+	body := recorder.Body.String()
+
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Contains(body, "** Index of /files/docs/ **", "rendered through the template")
+	assert.Contains(body, `<a href="readme.txt">readme.txt</a>`)
+	assert.Contains(body, `<a href="a&amp;b%20%3Cc%3E.txt">a&amp;b &lt;c&gt;.txt</a>`, "names are escaped")
+	assert.Contains(body, `<a href="../">../</a>`)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://localhost/files/", nil))
+
+	assert.Contains(recorder.Body.String(), `<a href="assets/">assets/</a>`)
+	assert.NotContains(recorder.Body.String(), ".env")
+}
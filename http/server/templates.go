@@ -0,0 +1,358 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+	"github.com/mdonahue-godaddy/go-http-server/version"
+)
+
+const (
+	DefaultTemplateName = "default.html"
+)
+
+var (
+	templateExtensions = []string{".html", ".tmpl"}
+
+	// legacy placeholders from the string replacement templates
+	legacyPlaceholders = strings.NewReplacer("{{page_title}}", "{{.Title}}", "{{page_body}}", "{{.Body}}")
+
+	// built in template, used when nothing else renders
+	builtinTemplate = template.Must(compileTemplate("builtin", DefaultResponseTemplateFile))
+)
+
+// TemplateRequest is the request as seen by a template
+type TemplateRequest struct {
+	Method     string
+	Proto      string
+	Host       string
+	Path       string
+	Query      string
+	RemoteAddr string
+	UserAgent  string
+	Header     http.Header // without credentials (Authorization, Cookie)
+}
+
+// TemplateData is the data model passed to response templates, strings are escaped by html/template, Body is trusted markup
+type TemplateData struct {
+	Title         string
+	Body          template.HTML
+	Status        int
+	StatusText    string
+	Reason        string
+	TransactionID string
+	Hostname      string
+	ServiceName   string
+	Version       string
+	Request       *TemplateRequest // nil outside of a request
+}
+
+// serviceVersion - build version, 0.0.0-local for development builds
+func serviceVersion() string {
+	if len(version.Version) == 0 {
+		return "0.0.0-local"
+	}
+
+	return version.Version
+}
+
+// NewTemplateData - data model for a page, request may be nil
+func (s *Server) NewTemplateData(ctx context.Context, request *http.Request, status int, reason string, title string, body string) TemplateData {
+	data := TemplateData{
+		Title:       title,
+		Body:        template.HTML(body), //nolint:gosec // callers escape request values
+		Status:      status,
+		StatusText:  http.StatusText(status),
+		Reason:      reason,
+		ServiceName: s.serviceName,
+		Version:     serviceVersion(),
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		data.Hostname = hostname
+	}
+
+	if ctx != nil {
+		if transactionID, err := shared.GetKeyFromContext(ctx, shared.KeyTransactionID); err == nil {
+			data.TransactionID = *transactionID
+		}
+	}
+
+	if request != nil {
+		header := request.Header.Clone()
+		header.Del("Authorization")
+		header.Del("Cookie")
+
+		data.Request = &TemplateRequest{
+			Method:     request.Method,
+			Proto:      request.Proto,
+			Host:       request.Host,
+			Path:       request.URL.Path,
+			Query:      request.URL.RawQuery,
+			RemoteAddr: request.RemoteAddr,
+			UserAgent:  request.UserAgent(),
+			Header:     header,
+		}
+	}
+
+	return data
+}
+
+// RenderPage - render the named template, falling back to the server default and then the built in template when a template is missing or fails
+func (s *Server) RenderPage(name string, data TemplateData) string {
+	return s.renderPage(name, nil, data)
+}
+
+// renderPage - candidates in order: name in the template directory, page, the directory default, the server template, the built in template
+func (s *Server) renderPage(name string, page *template.Template, data TemplateData) string {
+	method := "server.renderPage"
+
+	candidates := make([]*template.Template, 0, 5)
+	templates := s.routing().templates
+
+	if templates != nil && len(name) > 0 {
+		candidates = append(candidates, templates.lookup(name))
+	}
+	candidates = append(candidates, page)
+	if templates != nil {
+		candidates = append(candidates, templates.lookup(templates.defaultName))
+	}
+	candidates = append(candidates, s.responseTemplate, builtinTemplate)
+
+	var buffer bytes.Buffer
+
+	for _, candidate := range candidates {
+		if candidate == nil {
+			continue
+		}
+
+		buffer.Reset()
+
+		err := candidate.Execute(&buffer, data)
+		if err == nil {
+			return buffer.String()
+		}
+
+		log.WithFields(shared.GetFields(context.Background(), shared.EventTypeError, false, "template", candidate.Name(), shared.KeyErrorMessage, err.Error())).Errorf("%s error executing template, trying fallback", method)
+	}
+
+	return ""
+}
+
+// compileTemplate - parse source with html/template, legacy placeholders are mapped to the data model
+func compileTemplate(name string, source string) (*template.Template, error) {
+	return template.New(name).Parse(legacyPlaceholders.Replace(source))
+}
+
+// templateSet is the parsed content of the template directory, reloaded when the directory changes
+type templateSet struct {
+	directory   string
+	defaultName string
+	interval    time.Duration
+
+	mu        sync.RWMutex
+	templates *template.Template
+	stamp     string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newTemplateSet(cfg config.Templates) (*templateSet, error) {
+	t := templateSet{
+		directory:   cfg.Directory,
+		defaultName: cfg.Default,
+	}
+
+	if len(t.defaultName) == 0 {
+		t.defaultName = DefaultTemplateName
+	}
+
+	if len(cfg.ReloadInterval) > 0 {
+		interval, err := time.ParseDuration(cfg.ReloadInterval)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid template reloadInterval '%s'", cfg.ReloadInterval)
+		}
+		t.interval = interval
+	}
+
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+
+	if t.lookup(t.defaultName) == nil {
+		return nil, fmt.Errorf("default template '%s' not found in '%s'", t.defaultName, t.directory)
+	}
+
+	return &t, nil
+}
+
+// files - template files in the directory, sorted by name
+func (t *templateSet) files() ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(t.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !hasTemplateExtension(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, info)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	return files, nil
+}
+
+func hasTemplateExtension(name string) bool {
+	for _, extension := range templateExtensions {
+		if strings.EqualFold(filepath.Ext(name), extension) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fingerprint - changes whenever a template file is added, removed or modified
+func fingerprint(files []os.FileInfo) string {
+	hash := sha256.New()
+	for _, file := range files {
+		fmt.Fprintf(hash, "%s:%d:%d\n", file.Name(), file.Size(), file.ModTime().UnixNano())
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// load - parse every template file into one set so templates can include each other, the current set is kept when any file fails
+func (t *templateSet) load() error {
+	files, err := t.files()
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return fmt.Errorf("no templates found in '%s'", t.directory)
+	}
+
+	root := template.New("")
+	for _, file := range files {
+		source, err := os.ReadFile(filepath.Join(t.directory, file.Name()))
+		if err != nil {
+			return err
+		}
+		if _, err = root.New(file.Name()).Parse(legacyPlaceholders.Replace(string(source))); err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	t.templates = root
+	t.stamp = fingerprint(files)
+	t.mu.Unlock()
+
+	return nil
+}
+
+// lookup - template by file name, nil when not found
+func (t *templateSet) lookup(name string) *template.Template {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.templates == nil {
+		return nil
+	}
+
+	return t.templates.Lookup(name)
+}
+
+// Start - poll the directory and reload on change, nothing to do when hot reload is disabled
+func (t *templateSet) Start(ctx context.Context) {
+	if t.interval <= 0 || t.stop != nil {
+		return
+	}
+
+	t.stop = make(chan struct{})
+	t.done = make(chan struct{})
+
+	go t.watch(ctx)
+}
+
+// Stop - stop polling
+func (t *templateSet) Stop() {
+	if t.stop == nil {
+		return
+	}
+
+	close(t.stop)
+	<-t.done
+	t.stop = nil
+}
+
+func (t *templateSet) watch(ctx context.Context) {
+	method := "server.templateSet.watch"
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := t.reload(); err != nil {
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, "directory", t.directory, shared.KeyErrorMessage, err.Error())).Errorf("%s error reloading templates, keeping current templates", method)
+		}
+	}
+}
+
+// reload - load the directory when its fingerprint changed
+func (t *templateSet) reload() error {
+	method := "server.templateSet.reload"
+
+	files, err := t.files()
+	if err != nil {
+		return err
+	}
+
+	t.mu.RLock()
+	unchanged := t.stamp == fingerprint(files)
+	t.mu.RUnlock()
+
+	if unchanged {
+		return nil
+	}
+
+	if err = t.load(); err != nil {
+		return err
+	}
+
+	log.WithFields(shared.GetFields(context.Background(), shared.EventTypeInfo, false, "directory", t.directory)).Infof("%s templates reloaded", method)
+
+	return nil
+}
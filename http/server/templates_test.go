@@ -0,0 +1,154 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/server"
+)
+
+func createTemplateDirectory(t *testing.T, files map[string]string) string {
+	directory := t.TempDir()
+
+	for name, content := range files {
+		assert.Nil(t, os.WriteFile(filepath.Join(directory, name), []byte(content), 0600))
+	}
+
+	return directory
+}
+
+func Test_TemplateDirectory(t *testing.T) {
+	assert := assert.New(t)
+
+	directory := createTemplateDirectory(t, map[string]string{
+		"default.html": `<title>{{.Title}}</title>{{template "footer.html" .}}<main>{{.Body}}</main><p>{{with .Request}}{{.Method}} {{.Path}} {{.Query}}{{end}}</p>`,
+		"footer.html":  `<footer>{{.ServiceName}} {{.Version}} {{.Hostname}} {{.TransactionID}}</footer>`,
+		"legacy.html":  `<h1>{{page_title}}</h1>{{page_body}}`,
+		"broken.html":  `{{.Missing.Field}}`,
+		"notes.txt":    `{{ not a template`,
+	})
+
+	cfg := config.Settings{}
+	cfg.Templates = config.Templates{Directory: directory}
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+
+	hostname, _ := os.Hostname()
+
+	testCases := []struct {
+		Name        string
+		Title       string
+		Body        string
+		Expected    string
+		Description string
+	}{
+		{
+			Name:        "",
+			Title:       "<script>",
+			Body:        "<b>bold</b>",
+			Expected:    "<title>&lt;script&gt;</title><footer>TestServiceName 0.0.0-local " + hostname + " </footer><main><b>bold</b></main><p></p>",
+			Description: "default template escapes the title, body is trusted markup",
+		},
+		{
+			Name:        "legacy.html",
+			Title:       "a & b",
+			Body:        "body",
+			Expected:    "<h1>a &amp; b</h1>body",
+			Description: "legacy placeholders",
+		},
+		{
+			Name:        "broken.html",
+			Title:       "title",
+			Body:        "body",
+			Expected:    "<title>title</title><footer>TestServiceName 0.0.0-local " + hostname + " </footer><main>body</main><p></p>",
+			Description: "failing template falls back to the default",
+		},
+		{
+			Name:        "missing.html",
+			Title:       "title",
+			Body:        "body",
+			Expected:    "<title>title</title><footer>TestServiceName 0.0.0-local " + hostname + " </footer><main>body</main><p></p>",
+			Description: "unknown template falls back to the default",
+		},
+	}
+
+	for _, tc := range testCases {
+		actual := svc.RenderPage(tc.Name, svc.NewTemplateData(context.Background(), nil, http.StatusOK, "", tc.Title, tc.Body))
+
+		assert.Equal(tc.Expected, actual, tc.Description)
+	}
+}
+
+func Test_TemplateRequestData(t *testing.T) {
+	assert := assert.New(t)
+
+	directory := createTemplateDirectory(t, map[string]string{
+		"default.html": `{{.Title}}|{{.Body}}`,
+		"api.html":     `{{.Status}} {{.Request.Method}} {{.Request.Path}} {{.Request.Query}} {{len .TransactionID}} {{index .Request.Header "Cookie"}}`,
+	})
+
+	cfg := config.Settings{}
+	cfg.Templates = config.Templates{Directory: directory}
+	cfg.VirtualHosts = []config.VirtualHost{
+		{Hosts: []string{"api.example.test"}, Template: "api.html"},
+		{Hosts: []string{"*"}},
+	}
+
+	svc := server.NewServer("TestServiceName", &cfg, nil)
+	svc.Init()
+
+	request := httptest.NewRequest(http.MethodGet, "http://api.example.test/path?q={{.}}", nil)
+	request.Header.Set("Cookie", "session=secret")
+	recorder := httptest.NewRecorder()
+
+	svc.Handler().ServeHTTP(recorder, request)
+	body, _ := io.ReadAll(recorder.Body)
+
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Equal("200 GET /path q={{.}} 36 []", string(body), "virtual host template from the directory, credentials removed")
+
+	request = httptest.NewRequest(http.MethodGet, "http://other.example.test/<b>", nil)
+	recorder = httptest.NewRecorder()
+
+	svc.Handler().ServeHTTP(recorder, request)
+	body, _ = io.ReadAll(recorder.Body)
+
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Contains(string(body), "other.example.test|Request from", "default template")
+	assert.NotContains(string(body), "<b>", "request values are escaped")
+}
+
+func Test_TemplateDirectoryInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	template := "<p>{{page_title}}</p>"
+
+	testCases := []struct {
+		Templates   config.Templates
+		Description string
+	}{
+		{Templates: config.Templates{Directory: createTemplateDirectory(t, map[string]string{"other.html": "other"})}, Description: "no default template"},
+		{Templates: config.Templates{Directory: createTemplateDirectory(t, map[string]string{"default.html": "{{ .Title"})}, Description: "parse error"},
+		{Templates: config.Templates{Directory: filepath.Join(t.TempDir(), "missing")}, Description: "missing directory"},
+		{Templates: config.Templates{Directory: createTemplateDirectory(t, map[string]string{"default.html": "ok"}), ReloadInterval: "soon"}, Description: "invalid reload interval"},
+	}
+
+	for _, tc := range testCases {
+		cfg := config.Settings{}
+		cfg.Templates = tc.Templates
+
+		svc := server.NewServer("TestServiceName", &cfg, &template)
+		svc.Init()
+
+		assert.Equal("<p>title</p>", svc.GenerateHtmlBodyFromTemplate("title", "body"), tc.Description)
+	}
+}
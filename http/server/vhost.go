@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+const (
+	DefaultVirtualHostPattern = "*"
+	wildcardPrefix            = "*."
+)
+
+// VirtualHost is a resolved virtual host with its template loaded
+type VirtualHost struct {
+	Config   config.VirtualHost
+	Template string
+	page     *template.Template // parsed Template, nil when Config.Template names a template directory entry
+}
+
+type wildcardHost struct {
+	suffix string // ".example.test"
+	vhost  *VirtualHost
+}
+
+// virtualHosts matches request hosts, exact matches win over wildcards, longer wildcards win over shorter, default last
+type virtualHosts struct {
+	exact     map[string]*VirtualHost
+	wildcards []wildcardHost
+	fallback  *VirtualHost
+}
+
+func newVirtualHosts(ctx context.Context, cfgs []config.VirtualHost, defaultTemplate string, templates *templateSet) (*virtualHosts, error) {
+	v := virtualHosts{
+		exact: make(map[string]*VirtualHost),
+	}
+
+	for _, cfg := range cfgs {
+		vhost := &VirtualHost{
+			Config:   cfg,
+			Template: defaultTemplate,
+		}
+
+		if templates == nil || templates.lookup(cfg.Template) == nil {
+			vhost.Template = shared.LoadHTMLFile(ctx, cfg.Template, defaultTemplate)
+
+			page, err := compileTemplate(cfg.Template, vhost.Template)
+			if err != nil {
+				return nil, fmt.Errorf("virtual host template '%s': %w", cfg.Template, err)
+			}
+			vhost.page = page
+		}
+
+		for _, pattern := range cfg.Hosts {
+			pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+			switch {
+			case pattern == DefaultVirtualHostPattern:
+				if v.fallback != nil {
+					return nil, fmt.Errorf("duplicate default virtual host")
+				}
+				v.fallback = vhost
+			case strings.HasPrefix(pattern, wildcardPrefix):
+				v.wildcards = append(v.wildcards, wildcardHost{suffix: pattern[1:], vhost: vhost})
+			case strings.Contains(pattern, "*"):
+				return nil, fmt.Errorf("invalid virtual host pattern: %s", pattern)
+			default:
+				if _, found := v.exact[pattern]; found {
+					return nil, fmt.Errorf("duplicate virtual host: %s", pattern)
+				}
+				v.exact[pattern] = vhost
+			}
+		}
+	}
+
+	sort.SliceStable(v.wildcards, func(i, j int) bool { return len(v.wildcards[i].suffix) > len(v.wildcards[j].suffix) })
+
+	return &v, nil
+}
+
+// match - virtual host for host (without port), nil when nothing matches
+func (v *virtualHosts) match(host string) *VirtualHost {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if vhost, found := v.exact[host]; found {
+		return vhost
+	}
+
+	for _, wildcard := range v.wildcards {
+		if strings.HasSuffix(host, wildcard.suffix) && len(host) > len(wildcard.suffix) {
+			return wildcard.vhost
+		}
+	}
+
+	return v.fallback
+}
+
+type requestRoutingKey struct{}
+
+// requestRouting is the configuration and virtual host of a request, resolved once in front of the router
+type requestRouting struct {
+	rt    *routing
+	vhost *VirtualHost // nil without virtual hosts and for the health paths
+}
+
+// routes - the virtual host routes, else the top level routes
+func (r *requestRouting) routes() []config.Route {
+	if r.vhost != nil {
+		return r.vhost.Config.Routes
+	}
+	if r.rt.config != nil {
+		return r.rt.config.Routes
+	}
+
+	return nil
+}
+
+// resolveHosts - reject requests for unknown hosts and add the virtual host headers for every route, the health paths answer any host
+func (s *Server) resolveHosts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		resolved, ok := s.resolveRequest(responseWriter, request)
+		if !ok {
+			return
+		}
+
+		next.ServeHTTP(responseWriter, request.WithContext(context.WithValue(request.Context(), requestRoutingKey{}, resolved)))
+	})
+}
+
+// requestRoutingFor - routing resolved by resolveHosts, or resolved now for requests that did not pass through it, false when rejected
+func (s *Server) requestRoutingFor(responseWriter http.ResponseWriter, request *http.Request) (*requestRouting, bool) {
+	if resolved, found := request.Context().Value(requestRoutingKey{}).(*requestRouting); found {
+		return resolved, true
+	}
+
+	return s.resolveRequest(responseWriter, request)
+}
+
+// resolveRequest - one configuration for the whole request, Reload may swap it at any time. Unknown hosts get GetUnknownHostStatus.
+func (s *Server) resolveRequest(responseWriter http.ResponseWriter, request *http.Request) (*requestRouting, bool) {
+	resolved := &requestRouting{rt: s.routing()}
+
+	if resolved.rt.virtualHosts == nil || s.metricsGroup(request.URL.Path) == MetricsGroupHealth {
+		return resolved, true
+	}
+
+	ctx := shared.CreateRequestContext(request, "server.resolveRequest")
+
+	resolved.vhost = resolved.rt.resolveVirtualHost(ctx, request)
+	if resolved.vhost == nil {
+		httpStatusCode, httpStatusMessage, htmlMessage := s.CreateRequestResponseDetails(ctx, request, s.GetUnknownHostStatus(), fmt.Sprintf("unknown host '%s'", request.Host))
+		s.DoErrorResponse(ctx, responseWriter, request, httpStatusCode, htmlMessage, errors.New(httpStatusMessage))
+		return nil, false
+	}
+
+	for key, value := range resolved.vhost.Config.Headers {
+		responseWriter.Header().Set(key, value)
+	}
+
+	return resolved, true
+}
@@ -0,0 +1,123 @@
+package upstream
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// balancer selects a target, key is only used by key based strategies
+type balancer interface {
+	next(key string, now time.Time) *Target
+}
+
+func newBalancer(strategy string, targets []*Target) (balancer, error) {
+	switch strategy {
+	case StrategyRoundRobin:
+		return &roundRobinBalancer{targets: targets}, nil
+	case StrategyLeastConnections:
+		return &leastConnectionsBalancer{targets: targets}, nil
+	case StrategyConsistentHash:
+		return newConsistentHashBalancer(targets), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStrategy, strategy)
+	}
+}
+
+// roundRobinBalancer - rotate through available targets
+type roundRobinBalancer struct {
+	targets []*Target
+	counter uint64
+}
+
+func (b *roundRobinBalancer) next(key string, now time.Time) *Target {
+	count := uint64(len(b.targets))
+
+	for idx := uint64(0); idx < count; idx++ {
+		target := b.targets[(atomic.AddUint64(&b.counter, 1)-1)%count]
+		if target.IsAvailable(now) {
+			return target
+		}
+	}
+
+	return nil
+}
+
+// leastConnectionsBalancer - available target with fewest in flight requests, ties go to the first rotated target
+type leastConnectionsBalancer struct {
+	targets []*Target
+	counter uint64
+}
+
+func (b *leastConnectionsBalancer) next(key string, now time.Time) *Target {
+	var selected *Target
+
+	count := uint64(len(b.targets))
+	start := atomic.AddUint64(&b.counter, 1) - 1
+
+	for idx := uint64(0); idx < count; idx++ {
+		target := b.targets[(start+idx)%count]
+		if !target.IsAvailable(now) {
+			continue
+		}
+
+		if selected == nil || target.ActiveConnections() < selected.ActiveConnections() {
+			selected = target
+		}
+	}
+
+	return selected
+}
+
+// consistentHashBalancer - hash ring with virtual nodes, requests with the same key land on the same target while it is available
+type consistentHashBalancer struct {
+	targets  []*Target
+	ring     []uint32
+	ringMap  map[uint32]*Target
+	fallback *roundRobinBalancer
+}
+
+func newConsistentHashBalancer(targets []*Target) *consistentHashBalancer {
+	b := consistentHashBalancer{
+		targets:  targets,
+		ringMap:  make(map[uint32]*Target, len(targets)*consistentHashReplicasPerTarget),
+		fallback: &roundRobinBalancer{targets: targets},
+	}
+
+	for _, target := range targets {
+		for replica := 0; replica < consistentHashReplicasPerTarget; replica++ {
+			hash := crc32.ChecksumIEEE([]byte(target.URL.String() + "#" + strconv.Itoa(replica)))
+			if _, found := b.ringMap[hash]; found {
+				continue
+			}
+			b.ringMap[hash] = target
+			b.ring = append(b.ring, hash)
+		}
+	}
+
+	sort.Slice(b.ring, func(i, j int) bool { return b.ring[i] < b.ring[j] })
+
+	return &b
+}
+
+func (b *consistentHashBalancer) next(key string, now time.Time) *Target {
+	if len(key) == 0 {
+		return b.fallback.next(key, now)
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(b.ring), func(i int) bool { return b.ring[i] >= hash })
+
+	// walk the ring clockwise until an available target is found
+	for idx := 0; idx < len(b.ring); idx++ {
+		target := b.ringMap[b.ring[(start+idx)%len(b.ring)]]
+		if target.IsAvailable(now) {
+			return target
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+// StartHealthChecks - start active HTTP probes, a no-op when the pool has no health check path
+func (p *Pool) StartHealthChecks(ctx context.Context) {
+	if len(p.healthCheckPath) == 0 || p.cancel != nil {
+		return
+	}
+
+	ctx, p.cancel = context.WithCancel(ctx)
+
+	for _, target := range p.targets {
+		p.wg.Add(1)
+		go p.probeLoop(ctx, target)
+	}
+}
+
+// StopHealthChecks - stop active HTTP probes and wait for them to exit
+func (p *Pool) StopHealthChecks() {
+	if p.cancel == nil {
+		return
+	}
+
+	p.cancel()
+	p.wg.Wait()
+	p.cancel = nil
+}
+
+func (p *Pool) probeLoop(ctx context.Context, target *Target) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		p.Probe(ctx, target)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Probe - run a single active health probe against target and update its health using the configured thresholds
+func (p *Pool) Probe(ctx context.Context, target *Target) {
+	method := "upstream.Pool.Probe"
+
+	err := p.probe(ctx, target)
+
+	// cancelled by StopHealthChecks or shutdown, no result rather than a failure
+	if err != nil && ctx.Err() != nil {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, shared.KeyUpstreamPool, p.name, shared.KeyUpstreamTarget, target.URL.String())).Debugf("%s probe cancelled", method)
+		return
+	}
+
+	if err == nil {
+		atomic.StoreInt64(&target.probeFailures, 0)
+		target.lastProbeError.Store("")
+		if atomic.AddInt64(&target.probeSuccesses, 1) >= p.healthyThreshold && !target.IsHealthy() {
+			target.setHealthy(true)
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, shared.KeyUpstreamPool, p.name, shared.KeyUpstreamTarget, target.URL.String())).Infof("%s target is healthy", method)
+		}
+		return
+	}
+
+	atomic.StoreInt64(&target.probeSuccesses, 0)
+	target.lastProbeError.Store(err.Error())
+	if atomic.AddInt64(&target.probeFailures, 1) >= p.unhealthyThreshold && target.IsHealthy() {
+		target.setHealthy(false)
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyUpstreamPool, p.name, shared.KeyUpstreamTarget, target.URL.String(), shared.KeyErrorMessage, err.Error())).Warnf("%s target is unhealthy", method)
+	}
+}
+
+func (p *Pool) probe(ctx context.Context, target *Target) error {
+	probeURL := *target.URL
+	probeURL.Path = strings.TrimSuffix(probeURL.Path, "/") + "/" + strings.TrimPrefix(p.healthCheckPath, "/")
+	probeURL.RawQuery = ""
+
+	ctx, cancel := context.WithTimeout(ctx, p.healthCheckTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := p.probeClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if p.expectedStatus > 0 {
+		if response.StatusCode != p.expectedStatus {
+			return fmt.Errorf("unexpected status code %d, expected %d", response.StatusCode, p.expectedStatus)
+		}
+	} else if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	return nil
+}
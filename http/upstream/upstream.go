@@ -0,0 +1,467 @@
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+const (
+	StrategyRoundRobin       string = "roundRobin"
+	StrategyLeastConnections string = "leastConnections"
+	StrategyConsistentHash   string = "consistentHash"
+
+	DefaultHealthCheckInterval      = 10 * time.Second
+	DefaultHealthCheckTimeout       = 2 * time.Second
+	DefaultHealthyThreshold         = 2
+	DefaultUnhealthyThreshold       = 3
+	DefaultEjectionDuration         = 30 * time.Second
+	DefaultMaxEjectionPercent       = 50
+	HttpHeader_XUpstreamTarget      = "X-Upstream-Target"
+	HttpHeader_XUpstreamPool        = "X-Upstream-Pool"
+	consistentHashReplicasPerTarget = 100
+)
+
+var (
+	ErrNoTargets        = errors.New("upstream pool has no targets")
+	ErrNoHealthyTargets = errors.New("upstream pool has no healthy targets")
+	ErrUnknownStrategy  = errors.New("unknown upstream balancing strategy")
+)
+
+// Target is a single backend of a pool
+type Target struct {
+	URL   *url.URL
+	proxy *httputil.ReverseProxy
+
+	activeConnections   int64
+	healthy             int32 // set by active health probes, 1 == healthy
+	consecutiveFailures int64 // passive outlier detection
+	ejectedUntil        int64 // unix nano, 0 == not ejected
+	probeSuccesses      int64
+	probeFailures       int64
+	totalRequests       int64
+	totalFailures       int64
+	lastProbeError      atomic.Value // string
+}
+
+// TargetStatus is a point in time view of a target, used for reporting
+type TargetStatus struct {
+	URL                 string `json:"url"`
+	Healthy             bool   `json:"healthy"`
+	Ejected             bool   `json:"ejected"`
+	EjectedUntil        string `json:"ejectedUntil,omitempty"`
+	ActiveConnections   int64  `json:"activeConnections"`
+	ConsecutiveFailures int64  `json:"consecutiveFailures"`
+	TotalRequests       int64  `json:"totalRequests"`
+	TotalFailures       int64  `json:"totalFailures"`
+	LastProbeError      string `json:"lastProbeError,omitempty"`
+}
+
+// PoolStatus is a point in time view of a pool, used for reporting
+type PoolStatus struct {
+	Name             string         `json:"name"`
+	Strategy         string         `json:"strategy"`
+	Healthy          bool           `json:"healthy"`
+	AvailableTargets int            `json:"availableTargets"`
+	Targets          []TargetStatus `json:"targets"`
+}
+
+// IsHealthy - true when the last active probes passed
+func (t *Target) IsHealthy() bool {
+	return atomic.LoadInt32(&t.healthy) == 1
+}
+
+// IsEjected - true while passive outlier detection has the target ejected
+func (t *Target) IsEjected(now time.Time) bool {
+	until := atomic.LoadInt64(&t.ejectedUntil)
+	return until > 0 && now.UnixNano() < until
+}
+
+// IsAvailable - target can receive traffic
+func (t *Target) IsAvailable(now time.Time) bool {
+	return t.IsHealthy() && !t.IsEjected(now)
+}
+
+// ActiveConnections - number of in flight requests to the target
+func (t *Target) ActiveConnections() int64 {
+	return atomic.LoadInt64(&t.activeConnections)
+}
+
+func (t *Target) setHealthy(healthy bool) {
+	if healthy {
+		atomic.StoreInt32(&t.healthy, 1)
+	} else {
+		atomic.StoreInt32(&t.healthy, 0)
+	}
+}
+
+func (t *Target) status(now time.Time) TargetStatus {
+	status := TargetStatus{
+		URL:                 t.URL.String(),
+		Healthy:             t.IsHealthy(),
+		Ejected:             t.IsEjected(now),
+		ActiveConnections:   t.ActiveConnections(),
+		ConsecutiveFailures: atomic.LoadInt64(&t.consecutiveFailures),
+		TotalRequests:       atomic.LoadInt64(&t.totalRequests),
+		TotalFailures:       atomic.LoadInt64(&t.totalFailures),
+	}
+
+	if status.Ejected {
+		status.EjectedUntil = time.Unix(0, atomic.LoadInt64(&t.ejectedUntil)).UTC().Format(shared.TimestampFormat)
+	}
+
+	if value, ok := t.lastProbeError.Load().(string); ok {
+		status.LastProbeError = value
+	}
+
+	return status
+}
+
+// Pool is a named group of targets with a balancing strategy, active health checks and passive outlier ejection
+type Pool struct {
+	name       string
+	strategy   string
+	hashHeader string
+	targets    []*Target
+	balancer   balancer
+
+	healthCheckPath     string
+	healthCheckInterval time.Duration
+	healthCheckTimeout  time.Duration
+	expectedStatus      int
+	healthyThreshold    int64
+	unhealthyThreshold  int64
+	probeClient         *http.Client
+
+	outlierFailures    int64
+	ejectionDuration   time.Duration
+	maxEjectionPercent int
+	ejectionLock       sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPool - create pool from config, transport is used for forwarded requests (nil == http.DefaultTransport).
+// Active probes always use http.DefaultTransport so they are never short circuited by an outbound breaker.
+func NewPool(cfg config.UpstreamPool, transport http.RoundTripper) (*Pool, error) {
+	if len(cfg.Targets) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoTargets, cfg.Name)
+	}
+
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	pool := Pool{
+		name:                cfg.Name,
+		strategy:            cfg.Strategy,
+		hashHeader:          cfg.HashHeader,
+		healthCheckPath:     cfg.HealthCheck.Path,
+		healthCheckInterval: parseDuration(cfg.HealthCheck.Interval, DefaultHealthCheckInterval),
+		healthCheckTimeout:  parseDuration(cfg.HealthCheck.Timeout, DefaultHealthCheckTimeout),
+		expectedStatus:      cfg.HealthCheck.ExpectedStatus,
+		healthyThreshold:    int64(cfg.HealthCheck.HealthyThreshold),
+		unhealthyThreshold:  int64(cfg.HealthCheck.UnhealthyThreshold),
+		outlierFailures:     int64(cfg.OutlierDetection.ConsecutiveFailures),
+		ejectionDuration:    parseDuration(cfg.OutlierDetection.EjectionDuration, DefaultEjectionDuration),
+		maxEjectionPercent:  cfg.OutlierDetection.MaxEjectionPercent,
+	}
+
+	if len(pool.strategy) == 0 {
+		pool.strategy = StrategyRoundRobin
+	}
+	if pool.healthyThreshold <= 0 {
+		pool.healthyThreshold = DefaultHealthyThreshold
+	}
+	if pool.unhealthyThreshold <= 0 {
+		pool.unhealthyThreshold = DefaultUnhealthyThreshold
+	}
+	if pool.maxEjectionPercent <= 0 || pool.maxEjectionPercent > 100 {
+		pool.maxEjectionPercent = DefaultMaxEjectionPercent
+	}
+
+	pool.probeClient = &http.Client{
+		Transport: http.DefaultTransport,
+		Timeout:   pool.healthCheckTimeout,
+	}
+
+	for _, rawURL := range cfg.Targets {
+		targetURL, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("upstream pool %s target %s: %w", cfg.Name, rawURL, err)
+		}
+		if len(targetURL.Scheme) == 0 || len(targetURL.Host) == 0 {
+			return nil, fmt.Errorf("upstream pool %s target %s: scheme and host are required", cfg.Name, rawURL)
+		}
+
+		target := &Target{URL: targetURL, healthy: 1}
+		target.proxy = pool.newReverseProxy(target, transport)
+		pool.targets = append(pool.targets, target)
+	}
+
+	var err error
+	pool.balancer, err = newBalancer(pool.strategy, pool.targets)
+	if err != nil {
+		return nil, fmt.Errorf("upstream pool %s: %w", cfg.Name, err)
+	}
+
+	return &pool, nil
+}
+
+// GetName - pool name
+func (p *Pool) GetName() string {
+	return p.name
+}
+
+// GetTargets - pool targets
+func (p *Pool) GetTargets() []*Target {
+	return p.targets
+}
+
+// Next - select the target for the request using the pool's balancing strategy
+func (p *Pool) Next(request *http.Request) (*Target, error) {
+	key := ""
+	if len(p.hashHeader) > 0 && request != nil {
+		key = request.Header.Get(p.hashHeader)
+	}
+
+	target := p.balancer.next(key, time.Now())
+	if target == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoHealthyTargets, p.name)
+	}
+
+	return target, nil
+}
+
+// ServeHTTP - forward the request to the next available target
+func (p *Pool) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	method := "upstream.Pool.ServeHTTP"
+	ctx := request.Context()
+
+	target, err := p.Next(request)
+	if err != nil {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s no target available", method)
+		http.Error(responseWriter, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, shared.KeyUpstreamPool, p.name, shared.KeyUpstreamTarget, target.URL.String())).Debugf("%s forwarding", method)
+
+	atomic.AddInt64(&target.activeConnections, 1)
+	atomic.AddInt64(&target.totalRequests, 1)
+	defer atomic.AddInt64(&target.activeConnections, -1)
+
+	responseWriter.Header().Set(HttpHeader_XUpstreamPool, p.name)
+	responseWriter.Header().Set(HttpHeader_XUpstreamTarget, target.URL.Host)
+
+	target.proxy.ServeHTTP(responseWriter, request)
+}
+
+func (p *Pool) newReverseProxy(target *Target, transport http.RoundTripper) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target.URL)
+	proxy.Transport = transport
+
+	proxy.ModifyResponse = func(response *http.Response) error {
+		if response.StatusCode >= http.StatusInternalServerError {
+			p.recordFailure(target)
+		} else {
+			p.recordSuccess(target)
+		}
+
+		return nil
+	}
+
+	proxy.ErrorHandler = func(responseWriter http.ResponseWriter, request *http.Request, err error) {
+		method := "upstream.Pool.ErrorHandler"
+		log.WithFields(shared.GetFields(request.Context(), shared.EventTypeError, false, shared.KeyUpstreamPool, p.name, shared.KeyUpstreamTarget, target.URL.String(), shared.KeyErrorMessage, err.Error())).Errorf("%s forwarding error", method)
+
+		p.recordFailure(target)
+
+		responseWriter.WriteHeader(http.StatusBadGateway)
+	}
+
+	return proxy
+}
+
+func (p *Pool) recordSuccess(target *Target) {
+	atomic.StoreInt64(&target.consecutiveFailures, 0)
+}
+
+// recordFailure - passive outlier detection, eject target after consecutive failures unless too many targets are already ejected
+func (p *Pool) recordFailure(target *Target) {
+	atomic.AddInt64(&target.totalFailures, 1)
+	failures := atomic.AddInt64(&target.consecutiveFailures, 1)
+
+	if p.outlierFailures <= 0 || failures < p.outlierFailures {
+		return
+	}
+
+	p.ejectionLock.Lock()
+	defer p.ejectionLock.Unlock()
+
+	now := time.Now()
+	if target.IsEjected(now) {
+		return
+	}
+
+	ejected := 0
+	for _, t := range p.targets {
+		if t.IsEjected(now) {
+			ejected++
+		}
+	}
+
+	if (ejected+1)*100 > len(p.targets)*p.maxEjectionPercent {
+		log.WithFields(shared.GetFields(context.Background(), shared.EventTypeInfo, false, shared.KeyUpstreamPool, p.name, shared.KeyUpstreamTarget, target.URL.String())).Warnf("upstream.Pool.recordFailure max ejection percent reached, not ejecting")
+		return
+	}
+
+	atomic.StoreInt64(&target.ejectedUntil, now.Add(p.ejectionDuration).UnixNano())
+	atomic.StoreInt64(&target.consecutiveFailures, 0)
+
+	log.WithFields(shared.GetFields(context.Background(), shared.EventTypeInfo, false, shared.KeyUpstreamPool, p.name, shared.KeyUpstreamTarget, target.URL.String())).Warnf("upstream.Pool.recordFailure target ejected for %s", p.ejectionDuration.String())
+}
+
+// Status - point in time view of the pool
+func (p *Pool) Status() PoolStatus {
+	now := time.Now()
+
+	status := PoolStatus{
+		Name:     p.name,
+		Strategy: p.strategy,
+		Targets:  make([]TargetStatus, 0, len(p.targets)),
+	}
+
+	for _, target := range p.targets {
+		if target.IsAvailable(now) {
+			status.AvailableTargets++
+		}
+		status.Targets = append(status.Targets, target.status(now))
+	}
+
+	status.Healthy = status.AvailableTargets > 0
+
+	return status
+}
+
+// Registry holds all configured pools by name
+type Registry struct {
+	pools map[string]*Pool
+}
+
+// NewRegistry - create pools from config
+func NewRegistry(cfgs []config.UpstreamPool, transport http.RoundTripper) (*Registry, error) {
+	registry := Registry{
+		pools: make(map[string]*Pool, len(cfgs)),
+	}
+
+	for _, cfg := range cfgs {
+		if _, found := registry.pools[cfg.Name]; found {
+			return nil, fmt.Errorf("duplicate upstream pool name: %s", cfg.Name)
+		}
+
+		pool, err := NewPool(cfg, transport)
+		if err != nil {
+			return nil, err
+		}
+
+		registry.pools[cfg.Name] = pool
+	}
+
+	return &registry, nil
+}
+
+// Get - pool by name
+func (r *Registry) Get(name string) (*Pool, bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	pool, found := r.pools[name]
+	return pool, found
+}
+
+// Start - start active health checks for all pools
+func (r *Registry) Start(ctx context.Context) {
+	for _, pool := range r.pools {
+		pool.StartHealthChecks(ctx)
+	}
+}
+
+// Stop - stop active health checks for all pools
+func (r *Registry) Stop() {
+	for _, pool := range r.pools {
+		pool.StopHealthChecks()
+	}
+}
+
+// Status - point in time view of all pools sorted by name
+func (r *Registry) Status() []PoolStatus {
+	if r == nil {
+		return []PoolStatus{}
+	}
+
+	results := make([]PoolStatus, 0, len(r.pools))
+
+	for _, pool := range r.pools {
+		results = append(results, pool.Status())
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	return results
+}
+
+// HealthHandler - report pool and target health as JSON, 503 when any pool has no available targets
+func (r *Registry) HealthHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	method := "upstream.Registry.HealthHandler"
+	ctx := shared.CreateRequestContext(request, method)
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s entering", method)
+
+	statuses := r.Status()
+
+	httpStatusCode := http.StatusOK
+	for _, status := range statuses {
+		if !status.Healthy {
+			httpStatusCode = http.StatusServiceUnavailable
+		}
+	}
+
+	body, err := json.Marshal(statuses)
+	if err != nil {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s json.Marshal error", method)
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	responseWriter.Header().Set(shared.HttpHeader_ContentType, shared.ContentType_ApplicationJSON)
+	responseWriter.WriteHeader(httpStatusCode)
+	_, _ = responseWriter.Write(body)
+}
+
+func parseDuration(value string, defaultValue time.Duration) time.Duration {
+	if len(value) == 0 {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return defaultValue
+	}
+
+	return duration
+}
@@ -0,0 +1,242 @@
+package upstream_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/upstream"
+)
+
+const (
+	testTimeout = 2 * time.Second
+	testTick    = 10 * time.Millisecond
+)
+
+func createTestBackend(name string, status *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		code := http.StatusOK
+		if status != nil {
+			code = int(atomic.LoadInt32(status))
+		}
+		responseWriter.Header().Set("X-Backend", name)
+		responseWriter.WriteHeader(code)
+	}))
+}
+
+func createTestPool(t *testing.T, strategy string, targets ...string) *upstream.Pool {
+	cfg := config.UpstreamPool{
+		Name:       "test",
+		Targets:    targets,
+		Strategy:   strategy,
+		HashHeader: "X-User",
+	}
+
+	pool, err := upstream.NewPool(cfg, nil)
+	assert.Nil(t, err, "NewPool error should be nil")
+
+	return pool
+}
+
+func Test_NewPool_Errors(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		Cfg         config.UpstreamPool
+		Description string
+	}{
+		{
+			Cfg:         config.UpstreamPool{Name: "empty"},
+			Description: "no targets",
+		},
+		{
+			Cfg:         config.UpstreamPool{Name: "bad", Targets: []string{"localhost"}},
+			Description: "target without scheme",
+		},
+		{
+			Cfg:         config.UpstreamPool{Name: "strategy", Targets: []string{"http://localhost:1"}, Strategy: "random"},
+			Description: "unknown strategy",
+		},
+	}
+
+	for _, tc := range testCases {
+		actual, err := upstream.NewPool(tc.Cfg, nil)
+
+		assert.NotNil(err, tc.Description)
+		assert.Nil(actual, tc.Description)
+	}
+}
+
+func Test_RoundRobin(t *testing.T) {
+	assert := assert.New(t)
+
+	pool := createTestPool(t, upstream.StrategyRoundRobin, "http://a.test", "http://b.test", "http://c.test")
+
+	counts := map[string]int{}
+	for idx := 0; idx < 9; idx++ {
+		target, err := pool.Next(nil)
+		assert.Nil(err)
+		counts[target.URL.Host]++
+	}
+
+	assert.Equal(map[string]int{"a.test": 3, "b.test": 3, "c.test": 3}, counts)
+}
+
+func Test_LeastConnections(t *testing.T) {
+	assert := assert.New(t)
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+
+	pool := createTestPool(t, upstream.StrategyLeastConnections, slow.URL, "http://idle.test")
+
+	// hold a connection open on the first target, then verify new requests go to the idle target
+	done := make(chan struct{})
+	go func() {
+		pool.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		close(done)
+	}()
+
+	assert.Eventually(func() bool { return pool.GetTargets()[0].ActiveConnections() == 1 }, testTimeout, testTick)
+
+	for idx := 0; idx < 5; idx++ {
+		next, err := pool.Next(nil)
+		assert.Nil(err)
+		assert.Equal("idle.test", next.URL.Host)
+	}
+
+	close(release)
+	<-done
+}
+
+func Test_ConsistentHash(t *testing.T) {
+	assert := assert.New(t)
+
+	pool := createTestPool(t, upstream.StrategyConsistentHash, "http://a.test", "http://b.test", "http://c.test")
+
+	for _, user := range []string{"alice", "bob", "carol", "dave"} {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("X-User", user)
+
+		first, err := pool.Next(request)
+		assert.Nil(err)
+
+		for idx := 0; idx < 5; idx++ {
+			next, _ := pool.Next(request)
+			assert.Equal(first, next, user)
+		}
+	}
+}
+
+func Test_OutlierEjection(t *testing.T) {
+	assert := assert.New(t)
+
+	failing := int32(http.StatusInternalServerError)
+	bad := createTestBackend("bad", &failing)
+	defer bad.Close()
+	good := createTestBackend("good", nil)
+	defer good.Close()
+
+	cfg := config.UpstreamPool{
+		Name:    "outlier",
+		Targets: []string{bad.URL, good.URL},
+	}
+	cfg.OutlierDetection.ConsecutiveFailures = 2
+	cfg.OutlierDetection.EjectionDuration = "1m"
+
+	pool, err := upstream.NewPool(cfg, nil)
+	assert.Nil(err)
+
+	for idx := 0; idx < 4; idx++ {
+		pool.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	status := pool.Status()
+	assert.True(status.Targets[0].Ejected, "failing target should be ejected")
+	assert.False(status.Targets[1].Ejected, "good target should not be ejected")
+	assert.Equal(1, status.AvailableTargets)
+
+	for idx := 0; idx < 3; idx++ {
+		recorder := httptest.NewRecorder()
+		pool.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal("good", recorder.Header().Get("X-Backend"))
+	}
+}
+
+func Test_ActiveHealthCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	healthStatus := int32(http.StatusServiceUnavailable)
+	backend := createTestBackend("backend", &healthStatus)
+	defer backend.Close()
+
+	cfg := config.UpstreamPool{
+		Name:    "probe",
+		Targets: []string{backend.URL},
+	}
+	cfg.HealthCheck.Path = "/health"
+	cfg.HealthCheck.HealthyThreshold = 1
+	cfg.HealthCheck.UnhealthyThreshold = 1
+
+	pool, err := upstream.NewPool(cfg, nil)
+	assert.Nil(err)
+
+	target := pool.GetTargets()[0]
+
+	pool.Probe(context.Background(), target)
+	assert.False(target.IsHealthy(), "target should be unhealthy after failed probe")
+
+	_, err = pool.Next(nil)
+	assert.ErrorIs(err, upstream.ErrNoHealthyTargets)
+
+	recorder := httptest.NewRecorder()
+	pool.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(http.StatusServiceUnavailable, recorder.Code)
+
+	atomic.StoreInt32(&healthStatus, http.StatusOK)
+	pool.Probe(context.Background(), target)
+	assert.True(target.IsHealthy(), "target should be healthy after passing probe")
+
+	atomic.StoreInt32(&healthStatus, http.StatusServiceUnavailable)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	pool.Probe(cancelled, target)
+	assert.True(target.IsHealthy(), "cancelled probe is not a failure")
+}
+
+func Test_RegistryHealthHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	registry, err := upstream.NewRegistry([]config.UpstreamPool{
+		{Name: "b", Targets: []string{"http://b.test"}},
+		{Name: "a", Targets: []string{"http://a.test"}},
+	}, nil)
+	assert.Nil(err)
+
+	recorder := httptest.NewRecorder()
+	registry.HealthHandler(recorder, httptest.NewRequest(http.MethodGet, "/healthz/upstreams", nil))
+
+	assert.Equal(http.StatusOK, recorder.Code)
+
+	var statuses []upstream.PoolStatus
+	assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &statuses))
+	assert.Len(statuses, 2)
+	assert.Equal("a", statuses[0].Name)
+	assert.Equal("b", statuses[1].Name)
+
+	_, err = upstream.NewRegistry([]config.UpstreamPool{
+		{Name: "a", Targets: []string{"http://a.test"}},
+		{Name: "a", Targets: []string{"http://b.test"}},
+	}, nil)
+	assert.NotNil(err, "duplicate pool names should error")
+}
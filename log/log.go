@@ -38,6 +38,26 @@ func EnableECS() {
 	zerolog.ErrorStackMarshaler = apmzerolog.MarshalErrorStack
 }
 
+// SetLevel sets the global level of every logger, logrus level names such as warning are accepted
+func SetLevel(level string) error {
+	if level == "warning" {
+		level = zerolog.LevelWarnValue
+	}
+
+	parsed, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	zerolog.SetGlobalLevel(parsed)
+	return nil
+}
+
+// GetLevel returns the global level name
+func GetLevel() string {
+	return zerolog.GlobalLevel().String()
+}
+
 type Option func(l zerolog.Logger) zerolog.Logger
 
 func Client(ip string, user string) Option {
@@ -284,6 +304,7 @@ type eventType string
 
 var (
 	AccessType   eventType = "access"
+	ChangeType   eventType = "change"
 	CreationType eventType = "creation"
 	DeletionType eventType = "deletion"
 
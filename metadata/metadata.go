@@ -7,11 +7,15 @@ import (
 	"net/http"
 	"os"
 	"sync"
+	"time"
 
 	metadata "github.com/brunoscheufler/aws-ecs-metadata-go"
 )
 
-const EnvKeyFargateMetadataURI = "ECS_CONTAINER_METADATA_URI_V4"
+const (
+	EnvKeyFargateMetadataURI = "ECS_CONTAINER_METADATA_URI_V4"
+	DefaultClientTimeout     = 5 * time.Second
+)
 
 var (
 	ErrMetadataUnavailable = errors.New("metadata retrieval is not available")
@@ -19,7 +23,8 @@ var (
 )
 
 // Client is the HTTP client used to interact with the Fargate metadata endpoint.
-var Client = http.DefaultClient
+// The runner replaces it with the outbound client so metadata calls get breakers and retries.
+var Client = &http.Client{Timeout: DefaultClientTimeout}
 
 // Disabled returns true if metadata retrieval is unsupported.
 func Disabled() bool {
@@ -0,0 +1,338 @@
+package gometrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/log"
+)
+
+// Push exporters send the registry, named as the APM gatherer names it, every flush interval from their own goroutine.
+// A failed send is counted in {prefix}.exporter.{name}.errors, logged when the exporter starts failing and dropped.
+
+const (
+	ExporterStatsD   string = "statsd"
+	ExporterGraphite string = "graphite"
+
+	DefaultFlushInterval       = 10 * time.Second
+	DefaultStatsDPacketBytes   = 1432 // fits an ethernet MTU
+	DefaultExporterDialTimeout = 5 * time.Second
+)
+
+// Exporter pushes a metrics registry to StatsD or Graphite every flush interval
+type Exporter struct {
+	name     string
+	network  string
+	address  string
+	interval time.Duration
+	gatherer gatherer
+	format   func(buffer *bytes.Buffer, name string, value float64, counter bool, now time.Time)
+	maxBytes int // packet size for datagrams, zero writes everything at once
+	errors   metrics.Counter
+	logger   *log.Logger
+
+	mu       sync.Mutex // one flush at a time
+	conn     net.Conn
+	failing  bool
+	previous map[string]float64 // counter values at the last flush, StatsD sends the difference
+	stop     chan struct{}
+	done     sync.WaitGroup
+}
+
+// NewStatsDExporter - StatsD exporter with DogStatsD tags, nil without an address
+func (gm *GoMetrics) NewStatsDExporter(cfg config.StatsD, logger *log.Logger) (*Exporter, error) {
+	if len(cfg.Address) == 0 {
+		return nil, nil
+	}
+
+	tags := ""
+	if len(cfg.Tags) > 0 {
+		tags = "|#" + strings.Join(cfg.Tags, ",")
+	}
+
+	e, err := gm.newExporter(ExporterStatsD, "udp", cfg.Address, cfg.FlushInterval, cfg.Percentiles, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	e.maxBytes = DefaultStatsDPacketBytes
+	e.previous = map[string]float64{}
+	e.format = func(buffer *bytes.Buffer, name string, value float64, counter bool, now time.Time) {
+		name = sanitizeStatsDName(joinName(cfg.Prefix, name))
+
+		if counter {
+			delta := value - e.previous[name]
+			if delta < 0 {
+				// the counter was cleared
+				delta = value
+			}
+			e.previous[name] = value
+			if delta == 0 {
+				return
+			}
+			fmt.Fprintf(buffer, "%s:%s|c%s\n", name, formatExportValue(delta), tags)
+			return
+		}
+
+		if value < 0 {
+			// a signed gauge value is a relative change, reset to zero first
+			fmt.Fprintf(buffer, "%s:0|g%s\n", name, tags)
+		}
+		fmt.Fprintf(buffer, "%s:%s|g%s\n", name, formatExportValue(value), tags)
+	}
+
+	return e, nil
+}
+
+// NewGraphiteExporter - Graphite plaintext exporter, nil without an address
+func (gm *GoMetrics) NewGraphiteExporter(cfg config.Graphite, logger *log.Logger) (*Exporter, error) {
+	if len(cfg.Address) == 0 {
+		return nil, nil
+	}
+
+	e, err := gm.newExporter(ExporterGraphite, "tcp", cfg.Address, cfg.FlushInterval, cfg.Percentiles, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	e.format = func(buffer *bytes.Buffer, name string, value float64, counter bool, now time.Time) {
+		fmt.Fprintf(buffer, "%s %s %d\n", sanitizeGraphiteName(joinName(cfg.Prefix, name)), formatExportValue(value), now.Unix())
+	}
+
+	return e, nil
+}
+
+func (gm *GoMetrics) newExporter(name string, network string, address string, flushInterval string, percentiles []float64, logger *log.Logger) (*Exporter, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("%s exporter address: %w", name, err)
+	}
+
+	interval := DefaultFlushInterval
+	if len(flushInterval) > 0 {
+		var err error
+		if interval, err = time.ParseDuration(flushInterval); err != nil {
+			return nil, fmt.Errorf("%s exporter flush interval: %w", name, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("%s exporter flush interval must be positive", name)
+		}
+	}
+
+	for _, percentile := range percentiles {
+		if percentile <= 0 || percentile >= 1 {
+			return nil, fmt.Errorf("%s exporter percentile %v must be between 0 and 1", name, percentile)
+		}
+	}
+	if len(percentiles) == 0 {
+		percentiles = DefaultPercentiles
+	}
+
+	if logger == nil {
+		logger = &log.DefaultLogger
+	}
+
+	return &Exporter{
+		name:     name,
+		network:  network,
+		address:  address,
+		interval: interval,
+		gatherer: gatherer{registry: gm.registry, percentiles: percentiles},
+		errors:   gm.CreateCounter(gm.CreateMetricName(fmt.Sprintf("exporter.%s.errors", name))),
+		logger:   logger,
+	}, nil
+}
+
+func joinName(prefix string, name string) string {
+	if len(prefix) == 0 {
+		return name
+	}
+
+	return strings.TrimSuffix(prefix, ".") + "." + name
+}
+
+var statsDNameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+
+func sanitizeStatsDName(name string) string {
+	return statsDNameReplacer.Replace(name)
+}
+
+func sanitizeGraphiteName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func formatExportValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// Start - flush every interval until Stop
+func (e *Exporter) Start() {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.stop != nil {
+		return
+	}
+	e.stop = make(chan struct{})
+
+	e.done.Add(1)
+	go e.run(e.stop)
+}
+
+// Stop - flush once more and close the connection
+func (e *Exporter) Stop() {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	stop := e.stop
+	e.stop = nil
+	e.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	e.done.Wait()
+
+	_ = e.Flush()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn != nil {
+		e.conn.Close()
+		e.conn = nil
+	}
+}
+
+func (e *Exporter) run(stop chan struct{}) {
+	defer e.done.Done()
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		_ = e.Flush()
+	}
+}
+
+// Flush - send the registry now, errors are counted and logged when the exporter starts failing
+func (e *Exporter) Flush() error {
+	if e == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	lines := []string{}
+	e.gatherer.visit(func(name string, value float64, counter bool) {
+		buffer := bytes.Buffer{}
+		e.format(&buffer, name, value, counter, now)
+		if buffer.Len() > 0 {
+			lines = append(lines, buffer.String())
+		}
+	})
+
+	err := e.send(lines)
+	if err != nil {
+		e.errors.Inc(1)
+		if e.conn != nil {
+			e.conn.Close()
+			e.conn = nil
+		}
+		if !e.failing {
+			e.logger.Warn().
+				Tags(log.ApplicationTag).
+				Str("exporter", e.name).
+				Str("address", e.address).
+				Err(err).
+				Msg("metrics exporter send failed")
+		}
+		e.failing = true
+		return err
+	}
+
+	if e.failing {
+		e.logger.Info().
+			Tags(log.ApplicationTag).
+			Str("exporter", e.name).
+			Str("address", e.address).
+			Msg("metrics exporter recovered")
+	}
+	e.failing = false
+
+	return nil
+}
+
+// send - write lines in packets of maxBytes, or all at once, dialing when there is no connection
+func (e *Exporter) send(lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	if e.conn == nil {
+		conn, err := net.DialTimeout(e.network, e.address, DefaultExporterDialTimeout)
+		if err != nil {
+			return err
+		}
+		e.conn = conn
+	}
+
+	// a slow or stuck receiver costs at most one interval
+	if err := e.conn.SetWriteDeadline(time.Now().Add(e.interval)); err != nil {
+		return err
+	}
+
+	var errs []string
+	write := func(payload []byte) {
+		if _, err := e.conn.Write(payload); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	payload := bytes.Buffer{}
+	for _, line := range lines {
+		if e.maxBytes > 0 && payload.Len() > 0 && payload.Len()+len(line) > e.maxBytes {
+			write(payload.Bytes())
+			payload.Reset()
+		}
+		payload.WriteString(line)
+	}
+	if payload.Len() > 0 {
+		write(payload.Bytes())
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package gometrics_test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/gometrics"
+)
+
+func readPackets(t *testing.T, conn net.PacketConn) string {
+	packets := []string{}
+	buffer := make([]byte, 65536)
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			break
+		}
+		assert.LessOrEqual(t, n, gometrics.DefaultStatsDPacketBytes)
+		packets = append(packets, string(buffer[:n]))
+	}
+
+	return strings.Join(packets, "")
+}
+
+func Test_NewExporters(t *testing.T) {
+	assert := assert.New(t)
+
+	gm := gometrics.NewGoMetrics(metrics.NewRegistry(), "go-http-server")
+
+	testCases := []struct {
+		StatsD      config.StatsD
+		ExpectNil   bool
+		ExpectError bool
+		Description string
+	}{
+		{StatsD: config.StatsD{}, ExpectNil: true, Description: "disabled without an address"},
+		{StatsD: config.StatsD{Address: "127.0.0.1:8125"}, Description: "defaults"},
+		{StatsD: config.StatsD{Address: "localhost"}, ExpectNil: true, ExpectError: true, Description: "no port"},
+		{StatsD: config.StatsD{Address: "127.0.0.1:8125", FlushInterval: "0s"}, ExpectNil: true, ExpectError: true, Description: "zero flush interval"},
+		{StatsD: config.StatsD{Address: "127.0.0.1:8125", Percentiles: []float64{99}}, ExpectNil: true, ExpectError: true, Description: "percentile out of range"},
+	}
+
+	for _, tc := range testCases {
+		exporter, err := gm.NewStatsDExporter(tc.StatsD, nil)
+		assert.Equal(tc.ExpectError, err != nil, tc.Description)
+		assert.Equal(tc.ExpectNil, exporter == nil, tc.Description)
+	}
+
+	exporter, err := gm.NewGraphiteExporter(config.Graphite{}, nil)
+	assert.Nil(err)
+	assert.Nil(exporter, "graphite disabled without an address")
+	exporter.Start()
+	exporter.Stop()
+	assert.Nil(exporter.Flush())
+}
+
+func Test_StatsDExporter(t *testing.T) {
+	assert := assert.New(t)
+
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.Nil(err) {
+		return
+	}
+	defer listener.Close()
+
+	registry := metrics.NewRegistry()
+	gm := gometrics.NewGoMetrics(registry, "go-http-server")
+	gm.TrackedMetrics.HTTPService.Status2xx.Inc(5)
+	gm.TrackedMetrics.ServiceRequest.Update(2 * time.Second)
+	metrics.GetOrRegisterGauge("go-http-server.temperature", registry).Update(-3)
+
+	exporter, err := gm.NewStatsDExporter(config.StatsD{Address: listener.LocalAddr().String(), Prefix: "prod", Tags: []string{"env:prod", "region:west"}, Percentiles: []float64{0.99}}, nil)
+	assert.Nil(err)
+
+	assert.Nil(exporter.Flush())
+	text := readPackets(t, listener)
+	assert.Contains(text, "prod.go-http-server.http.service.response.status.2xx:5|c|#env:prod,region:west\n")
+	assert.Contains(text, "prod.go-http-server.http.service.request.percentile.99:2000000000|g|#env:prod,region:west\n")
+	assert.Contains(text, "prod.go-http-server.temperature:0|g|#env:prod,region:west\nprod.go-http-server.temperature:-3|g|#env:prod,region:west\n", "negative gauges reset first")
+	assert.NotContains(text, "percentile.95", "configured percentiles only")
+
+	gm.TrackedMetrics.HTTPService.Status2xx.Inc(2)
+	assert.Nil(exporter.Flush())
+	text = readPackets(t, listener)
+	assert.Contains(text, "prod.go-http-server.http.service.response.status.2xx:2|c|", "counters send the difference")
+	assert.NotContains(text, "status.4xx:", "unchanged counters are not sent")
+}
+
+func Test_GraphiteExporter(t *testing.T) {
+	assert := assert.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.Nil(err) {
+		return
+	}
+
+	lines := make(chan string, 1024)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	registry := metrics.NewRegistry()
+	gm := gometrics.NewGoMetrics(registry, "go-http-server")
+	gm.TrackedMetrics.ShadowErrors.Inc(4)
+
+	exporter, err := gm.NewGraphiteExporter(config.Graphite{Address: listener.Addr().String(), Prefix: "prod.", FlushInterval: "20ms"}, nil)
+	assert.Nil(err)
+
+	exporter.Start()
+
+	found := false
+	timeout := time.After(5 * time.Second)
+	for !found {
+		select {
+		case line := <-lines:
+			fields := strings.Fields(line)
+			assert.Len(fields, 3, line)
+			found = strings.HasPrefix(line, "prod.go-http-server.http.shadow.errors 4 ")
+		case <-timeout:
+			t.Fatal("graphite line not received")
+		}
+	}
+
+	exporter.Stop()
+	listener.Close()
+
+	errors := metrics.GetOrRegisterCounter("go-http-server.exporter.graphite.errors", registry)
+	before := errors.Count()
+	assert.NotNil(exporter.Flush(), "listener closed")
+	assert.NotNil(exporter.Flush())
+	assert.Equal(before+2, errors.Count(), "send errors counted")
+}
@@ -9,6 +9,9 @@ import (
 	"go.elastic.co/apm/v2"
 )
 
+// DefaultPercentiles are reported for histograms and timers
+var DefaultPercentiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
 type gatherer struct {
 	registry    metrics.Registry
 	percentiles []float64
@@ -18,19 +21,28 @@ type gatherer struct {
 func WrapRegistry(reg metrics.Registry) apm.MetricsGatherer {
 	return gatherer{
 		registry:    reg,
-		percentiles: []float64{0.5, 0.75, 0.95, 0.99, 0.999},
+		percentiles: DefaultPercentiles,
 	}
 }
 
 // GatherMetrics gathers metrics into m.
 func (g gatherer) GatherMetrics(ctx context.Context, met *apm.Metrics) error {
+	g.visit(func(name string, value float64, counter bool) {
+		met.Add(name, nil, value)
+	})
+
+	return nil
+}
+
+// visit calls add with every value GatherMetrics reports, counter is true for the counts of metrics.Counter.
+func (g gatherer) visit(add func(name string, value float64, counter bool)) {
 	g.registry.Each(func(name string, v interface{}) {
 		switch v := v.(type) {
 		case metrics.Counter: // results and values should be consistant with https://github.com/rcrowley/go-metrics/blob/cf1acfcdf4751e0554ffa765d03e479ec491cad6/exp/exp.go#L81
-			met.Add(name, nil, float64(v.Count()))
+			add(name, float64(v.Count()), true)
+		case metrics.Gauge: // results and values should be consistant with https://github.com/rcrowley/go-metrics/blob/cf1acfcdf4751e0554ffa765d03e479ec491cad6/exp/exp.go#L86
+			add(name, float64(v.Value()), false)
 			/* Not needed yet, but I don't wanto just delete working code
-			case metrics.Gauge: // results and values should be consistant with https://github.com/rcrowley/go-metrics/blob/cf1acfcdf4751e0554ffa765d03e479ec491cad6/exp/exp.go#L86
-				met.Add(name, nil, float64(v.Value()))
 			case metrics.GaugeFloat64: // results and values should be consistant with https://github.com/rcrowley/go-metrics/blob/cf1acfcdf4751e0554ffa765d03e479ec491cad6/exp/exp.go#L90
 				met.Add(name, nil, v.Value())
 			case metrics.Histogram: // results and values should be consistant with https://github.com/rcrowley/go-metrics/blob/cf1acfcdf4751e0554ffa765d03e479ec491cad6/exp/exp.go#L94
@@ -59,28 +71,26 @@ func (g gatherer) GatherMetrics(ctx context.Context, met *apm.Metrics) error {
 		case metrics.Timer: // results and values should be consistant with https://github.com/rcrowley/go-metrics/blob/cf1acfcdf4751e0554ffa765d03e479ec491cad6/exp/exp.go#L118
 			t := v.Snapshot()
 			ps := t.Percentiles(g.percentiles)
-			met.Add(name+".count", nil, float64(t.Count()))
-			met.Add(name+".max", nil, float64(t.Max()))
-			met.Add(name+".mean", nil, t.Mean())
-			met.Add(name+".min", nil, float64(t.Min()))
-			met.Add(name+".std_dev", nil, float64(t.StdDev()))
-			met.Add(name+".mean_rate", nil, t.RateMean())
-			met.Add(name+".sum", nil, float64(t.Sum()))
-			met.Add(name+".variance", nil, t.Variance())
-			met.Add(name+".one_minute", nil, t.Rate1())
-			met.Add(name+".five_minute", nil, t.Rate5())
-			met.Add(name+".fifteen_minute", nil, t.Rate15())
+			add(name+".count", float64(t.Count()), false)
+			add(name+".max", float64(t.Max()), false)
+			add(name+".mean", t.Mean(), false)
+			add(name+".min", float64(t.Min()), false)
+			add(name+".std_dev", float64(t.StdDev()), false)
+			add(name+".mean_rate", t.RateMean(), false)
+			add(name+".sum", float64(t.Sum()), false)
+			add(name+".variance", t.Variance(), false)
+			add(name+".one_minute", t.Rate1(), false)
+			add(name+".five_minute", t.Rate5(), false)
+			add(name+".fifteen_minute", t.Rate15(), false)
 			for idx := 0; idx < len(g.percentiles); idx++ {
 				displayPercent := int(g.percentiles[idx] * 100)
 				if g.percentiles[idx] > 0.99 {
 					displayPercent = int(g.percentiles[idx] * 1000)
 				}
-				met.Add(fmt.Sprintf("%s.percentile.%d", name, displayPercent), nil, t.Percentile(ps[idx]))
+				add(fmt.Sprintf("%s.percentile.%d", name, displayPercent), ps[idx], false)
 			}
 		default:
 			// TODO: other types (metrics.EWMA)
 		}
 	})
-
-	return nil
 }
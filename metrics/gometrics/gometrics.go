@@ -19,10 +19,6 @@ import (
 	"go.elastic.co/apm/v2/apmtest"
 	"go.elastic.co/apm/v2/model"
 
-	//prometheusmetrics "github.com/deathowl/go-metrics-prometheus"
-	//"github.com/prometheus/client_golang/prometheus"
-	//"github.com/prometheus/client_golang/prometheus/promhttp"
-
 	"github.com/mdonahue-godaddy/go-http-server/log"
 )
 
@@ -48,12 +44,22 @@ type IGoMetrics interface {
 	CreateMetrics()
 	CreateCounter(name string) metrics.Counter
 	CreateTimer(name string) metrics.Timer
+	CreateGauge(name string) metrics.Gauge
 	IncServiceRequest(duration time.Duration)
 	IncHealthRequest(duration time.Duration)
 	IncMetricRequest(duration time.Duration)
+	IncShadowRequest(duration time.Duration)
+	IncShadowError()
+	IncCompressionBytes(uncompressed int64, compressed int64)
+	IncConfigReload(success bool)
 	IncHTTPHealth(logger *log.Logger, httpStatusCode int, duration time.Duration)
 	IncHTTPMetric(logger *log.Logger, httpStatusCode int, duration time.Duration)
 	IncHTTPService(logger *log.Logger, httpStatusCode int, duration time.Duration)
+	IncHTTPHealthBytes(bytes int64)
+	IncHTTPMetricBytes(bytes int64)
+	IncHTTPServiceBytes(bytes int64)
+	SetRouteMaxSeries(maxSeries int)
+	IncHTTPRoute(route string, method string, httpStatusCode int, duration time.Duration)
 }
 
 type HTTPMetrics struct {
@@ -72,6 +78,7 @@ type HTTPMetrics struct {
 	Status502 metrics.Counter
 	Status503 metrics.Counter
 	StatusOOR metrics.Counter // Out-Of-Range (n < 0 or n > 599)
+	Bytes     metrics.Counter // response body bytes written
 }
 
 type HTTPBasicMetrics struct {
@@ -81,15 +88,23 @@ type HTTPBasicMetrics struct {
 	Status4xx metrics.Counter
 	Status5xx metrics.Counter
 	StatusOOR metrics.Counter // Out-Of-Range (n < 0 or n > 599)
+	Bytes     metrics.Counter // response body bytes written
 }
 
 type TrackedMetrics struct {
 	ServiceRequest metrics.Timer
 	HealthRequest  metrics.Timer
 	MetricsRequest metrics.Timer
-	HTTPService    HTTPMetrics
-	HTTPHealth     HTTPBasicMetrics
-	HTTPMetric     HTTPBasicMetrics
+	ShadowRequest  metrics.Timer
+	ShadowErrors   metrics.Counter
+	// response body bytes before and after compression, only for compressed responses
+	CompressionUncompressedBytes metrics.Counter
+	CompressionCompressedBytes   metrics.Counter
+	ConfigReloads                metrics.Counter
+	ConfigReloadFailures         metrics.Counter
+	HTTPService                  HTTPMetrics
+	HTTPHealth                   HTTPBasicMetrics
+	HTTPMetric                   HTTPBasicMetrics
 }
 
 type GoMetrics struct {
@@ -97,6 +112,7 @@ type GoMetrics struct {
 	sync.Mutex // embbed sync.Mutex to add Lock() and Unlock() for thread safety
 	registry   metrics.Registry
 	prefix     string
+	routes     routeMetrics // timers by route, method and status class
 	// Exported
 	ExpHandler     http.Handler // http server will need access to this
 	TrackedMetrics TrackedMetrics
@@ -250,18 +266,26 @@ func (gm *GoMetrics) CreateMetrics() {
 	gm.TrackedMetrics.ServiceRequest = gm.CreateTimer(gm.CreateMetricName("http.service.request"))
 	gm.TrackedMetrics.HealthRequest = gm.CreateTimer(gm.CreateMetricName("http.health.request"))
 	gm.TrackedMetrics.MetricsRequest = gm.CreateTimer(gm.CreateMetricName("http.metric.request"))
+	gm.TrackedMetrics.ShadowRequest = gm.CreateTimer(gm.CreateMetricName("http.shadow.request"))
+	gm.TrackedMetrics.ShadowErrors = gm.CreateCounter(gm.CreateMetricName("http.shadow.errors"))
+	gm.TrackedMetrics.CompressionUncompressedBytes = gm.CreateCounter(gm.CreateMetricName("http.compression.uncompressed.bytes"))
+	gm.TrackedMetrics.CompressionCompressedBytes = gm.CreateCounter(gm.CreateMetricName("http.compression.compressed.bytes"))
+	gm.TrackedMetrics.ConfigReloads = gm.CreateCounter(gm.CreateMetricName("config.reload.success"))
+	gm.TrackedMetrics.ConfigReloadFailures = gm.CreateCounter(gm.CreateMetricName("config.reload.failure"))
 	gm.TrackedMetrics.HTTPHealth.Status1xx = gm.CreateCounter(gm.CreateMetricName("http.health.response.status.1xx"))
 	gm.TrackedMetrics.HTTPHealth.Status2xx = gm.CreateCounter(gm.CreateMetricName("http.health.response.status.2xx"))
 	gm.TrackedMetrics.HTTPHealth.Status3xx = gm.CreateCounter(gm.CreateMetricName("http.health.response.status.3xx"))
 	gm.TrackedMetrics.HTTPHealth.Status4xx = gm.CreateCounter(gm.CreateMetricName("http.health.response.status.4xx"))
 	gm.TrackedMetrics.HTTPHealth.Status5xx = gm.CreateCounter(gm.CreateMetricName("http.health.response.status.5xx"))
 	gm.TrackedMetrics.HTTPHealth.StatusOOR = gm.CreateCounter(gm.CreateMetricName("http.health.response.status.oor"))
+	gm.TrackedMetrics.HTTPHealth.Bytes = gm.CreateCounter(gm.CreateMetricName("http.health.response.bytes"))
 	gm.TrackedMetrics.HTTPMetric.Status1xx = gm.CreateCounter(gm.CreateMetricName("http.metric.response.status.1xx"))
 	gm.TrackedMetrics.HTTPMetric.Status2xx = gm.CreateCounter(gm.CreateMetricName("http.metric.response.status.2xx"))
 	gm.TrackedMetrics.HTTPMetric.Status3xx = gm.CreateCounter(gm.CreateMetricName("http.metric.response.status.3xx"))
 	gm.TrackedMetrics.HTTPMetric.Status4xx = gm.CreateCounter(gm.CreateMetricName("http.metric.response.status.4xx"))
 	gm.TrackedMetrics.HTTPMetric.Status5xx = gm.CreateCounter(gm.CreateMetricName("http.metric.response.status.5xx"))
 	gm.TrackedMetrics.HTTPMetric.StatusOOR = gm.CreateCounter(gm.CreateMetricName("http.metric.response.status.oor"))
+	gm.TrackedMetrics.HTTPMetric.Bytes = gm.CreateCounter(gm.CreateMetricName("http.metric.response.bytes"))
 	gm.TrackedMetrics.HTTPService.Status1xx = gm.CreateCounter(gm.CreateMetricName("http.service.response.status.1xx"))
 	gm.TrackedMetrics.HTTPService.Status2xx = gm.CreateCounter(gm.CreateMetricName("http.service.response.status.2xx"))
 	gm.TrackedMetrics.HTTPService.Status3xx = gm.CreateCounter(gm.CreateMetricName("http.service.response.status.3xx"))
@@ -277,22 +301,30 @@ func (gm *GoMetrics) CreateMetrics() {
 	gm.TrackedMetrics.HTTPService.Status502 = gm.CreateCounter(gm.CreateMetricName("http.service.response.status.502"))
 	gm.TrackedMetrics.HTTPService.Status503 = gm.CreateCounter(gm.CreateMetricName("http.service.response.status.503"))
 	gm.TrackedMetrics.HTTPService.StatusOOR = gm.CreateCounter(gm.CreateMetricName("http.service.response.status.oor"))
+	gm.TrackedMetrics.HTTPService.Bytes = gm.CreateCounter(gm.CreateMetricName("http.service.response.bytes"))
 }
 
 func (gm *GoMetrics) ResetCounters() {
 	// Timers are histograms with a NewExpDecaySample(1028, 0.015) and cannot be cleared.
+	gm.TrackedMetrics.ShadowErrors.Clear()
+	gm.TrackedMetrics.CompressionUncompressedBytes.Clear()
+	gm.TrackedMetrics.CompressionCompressedBytes.Clear()
+	gm.TrackedMetrics.ConfigReloads.Clear()
+	gm.TrackedMetrics.ConfigReloadFailures.Clear()
 	gm.TrackedMetrics.HTTPHealth.Status1xx.Clear()
 	gm.TrackedMetrics.HTTPHealth.Status2xx.Clear()
 	gm.TrackedMetrics.HTTPHealth.Status3xx.Clear()
 	gm.TrackedMetrics.HTTPHealth.Status4xx.Clear()
 	gm.TrackedMetrics.HTTPHealth.Status5xx.Clear()
 	gm.TrackedMetrics.HTTPHealth.StatusOOR.Clear()
+	gm.TrackedMetrics.HTTPHealth.Bytes.Clear()
 	gm.TrackedMetrics.HTTPMetric.Status1xx.Clear()
 	gm.TrackedMetrics.HTTPMetric.Status2xx.Clear()
 	gm.TrackedMetrics.HTTPMetric.Status3xx.Clear()
 	gm.TrackedMetrics.HTTPMetric.Status4xx.Clear()
 	gm.TrackedMetrics.HTTPMetric.Status5xx.Clear()
 	gm.TrackedMetrics.HTTPMetric.StatusOOR.Clear()
+	gm.TrackedMetrics.HTTPMetric.Bytes.Clear()
 	gm.TrackedMetrics.HTTPService.Status1xx.Clear()
 	gm.TrackedMetrics.HTTPService.Status2xx.Clear()
 	gm.TrackedMetrics.HTTPService.Status3xx.Clear()
@@ -308,6 +340,7 @@ func (gm *GoMetrics) ResetCounters() {
 	gm.TrackedMetrics.HTTPService.Status502.Clear()
 	gm.TrackedMetrics.HTTPService.Status503.Clear()
 	gm.TrackedMetrics.HTTPService.StatusOOR.Clear()
+	gm.TrackedMetrics.HTTPService.Bytes.Clear()
 }
 
 func (gm *GoMetrics) CreateMetricName(detail string) string {
@@ -322,6 +355,10 @@ func (gm *GoMetrics) CreateTimer(name string) metrics.Timer {
 	return metrics.GetOrRegisterTimer(name, gm.registry)
 }
 
+func (gm *GoMetrics) CreateGauge(name string) metrics.Gauge {
+	return metrics.GetOrRegisterGauge(name, gm.registry)
+}
+
 func (gm *GoMetrics) IncServiceRequest(duration time.Duration) {
 	gm.TrackedMetrics.ServiceRequest.Update(duration)
 }
@@ -334,6 +371,39 @@ func (gm *GoMetrics) IncMetricRequest(duration time.Duration) {
 	gm.TrackedMetrics.MetricsRequest.Update(duration)
 }
 
+func (gm *GoMetrics) IncShadowRequest(duration time.Duration) {
+	gm.TrackedMetrics.ShadowRequest.Update(duration)
+}
+
+func (gm *GoMetrics) IncShadowError() {
+	gm.TrackedMetrics.ShadowErrors.Inc(1)
+}
+
+func (gm *GoMetrics) IncCompressionBytes(uncompressed int64, compressed int64) {
+	gm.TrackedMetrics.CompressionUncompressedBytes.Inc(uncompressed)
+	gm.TrackedMetrics.CompressionCompressedBytes.Inc(compressed)
+}
+
+func (gm *GoMetrics) IncConfigReload(success bool) {
+	if success {
+		gm.TrackedMetrics.ConfigReloads.Inc(1)
+	} else {
+		gm.TrackedMetrics.ConfigReloadFailures.Inc(1)
+	}
+}
+
+func (gm *GoMetrics) IncHTTPHealthBytes(bytes int64) {
+	gm.TrackedMetrics.HTTPHealth.Bytes.Inc(bytes)
+}
+
+func (gm *GoMetrics) IncHTTPMetricBytes(bytes int64) {
+	gm.TrackedMetrics.HTTPMetric.Bytes.Inc(bytes)
+}
+
+func (gm *GoMetrics) IncHTTPServiceBytes(bytes int64) {
+	gm.TrackedMetrics.HTTPService.Bytes.Inc(bytes)
+}
+
 func (gm *GoMetrics) IncHTTPHealth(logger *log.Logger, httpStatusCode int, duration time.Duration) {
 	gm.IncHealthRequest(duration)
 
@@ -435,17 +505,3 @@ func (gm *GoMetrics) IncHTTPService(logger *log.Logger, httpStatusCode int, dura
 		warning.Msg("unexpected service http status code")
 	}
 }
-
-/*
-func AddPrometheusClientRegistry(metricsRegistry metrics.Registry, nameSpace string, serviceName string) {
-	flushInterval := time.Duration(1 * time.Second)
-
-	prometheusClient := prometheusmetrics.NewPrometheusProvider(metricsRegistry, nameSpace, serviceName, prometheus.DefaultRegisterer, flushInterval)
-
-	go prometheusClient.UpdatePrometheusMetrics()
-}
-
-func StartPrometheusMetricsEndpoint(mux *http.ServeMux) {
-	mux.Handle("/metrics", promhttp.Handler())
-}
-*/
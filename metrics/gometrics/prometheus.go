@@ -0,0 +1,252 @@
+package gometrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rcrowley/go-metrics"
+
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+// Prometheus text exposition (version 0.0.4) and OpenMetrics 1.0 for a metrics.Registry.
+// Names have the prefix removed and are sanitized, the prefix becomes the "prefix" label.
+// Counters are counters, gauges and EWMAs are gauges, meters are a counter and rate gauges,
+// histograms are summaries and timers are summaries in seconds.
+
+const (
+	ContentType_PrometheusText string = "text/plain; version=0.0.4; charset=utf-8"
+	ContentType_OpenMetrics    string = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	MediaType_OpenMetrics      string = "application/openmetrics-text"
+
+	PrometheusPrefixLabel string = "prefix"
+
+	familyCounter = "counter"
+	familyGauge   = "gauge"
+	familySummary = "summary"
+)
+
+type promSample struct {
+	group  string   // labels of the go-metrics metric, samples are grouped by them
+	suffix string   // added to the family name, e.g. "_sum"
+	labels []string // name, value pairs
+	value  float64
+}
+
+type promFamily struct {
+	name    string
+	kind    string
+	help    string
+	samples []promSample
+}
+
+// PrometheusHandler - registry in the Prometheus text format, or OpenMetrics when the request accepts it
+func PrometheusHandler(registry metrics.Registry, prefix string) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		openMetrics := shared.NegotiateContentType(request, shared.MediaType_TextPlain, MediaType_OpenMetrics) == MediaType_OpenMetrics
+
+		contentType := ContentType_PrometheusText
+		if openMetrics {
+			contentType = ContentType_OpenMetrics
+		}
+
+		body := WritePrometheus(registry, prefix, DefaultPercentiles, openMetrics)
+
+		responseWriter.Header().Set(shared.HttpHeader_ContentType, contentType)
+		responseWriter.Header().Set("Cache-Control", "no-store")
+		_, _ = responseWriter.Write(body)
+	})
+}
+
+// PrometheusHandler - this registry in the Prometheus text format, see PrometheusHandler
+func (gm *GoMetrics) PrometheusHandler() http.Handler {
+	return PrometheusHandler(gm.registry, gm.prefix)
+}
+
+// WritePrometheus - registry in the Prometheus text format, or OpenMetrics, families ordered by name
+func WritePrometheus(registry metrics.Registry, prefix string, percentiles []float64, openMetrics bool) []byte {
+	families := map[string]*promFamily{}
+	group := ""
+
+	add := func(name string, kind string, help string, samples ...promSample) {
+		family, found := families[name]
+		if !found {
+			family = &promFamily{name: name, kind: kind, help: help}
+			families[name] = family
+		} else if family.kind != kind {
+			// sanitizing made two metrics of different types collide, the first one wins
+			return
+		}
+		for _, sample := range samples {
+			sample.group = group
+			family.samples = append(family.samples, sample)
+		}
+	}
+
+	registry.Each(func(metricName string, value interface{}) {
+		detail, labels := metricName, []string{}
+		if len(prefix) > 0 && strings.HasPrefix(metricName, prefix+".") {
+			detail = strings.TrimPrefix(metricName, prefix+".")
+			labels = []string{PrometheusPrefixLabel, prefix}
+		}
+		group = strings.Join(labels, ",")
+		name := SanitizeMetricName(detail)
+
+		switch typed := value.(type) {
+		case metrics.Counter:
+			add(strings.TrimSuffix(name, "_total"), familyCounter, detail, promSample{suffix: "_total", labels: labels, value: float64(typed.Count())})
+		case metrics.Gauge:
+			add(name, familyGauge, detail, promSample{labels: labels, value: float64(typed.Value())})
+		case metrics.GaugeFloat64:
+			add(name, familyGauge, detail, promSample{labels: labels, value: typed.Value()})
+		case metrics.EWMA:
+			add(name, familyGauge, detail, promSample{labels: labels, value: typed.Rate()})
+		case metrics.Meter:
+			snapshot := typed.Snapshot()
+			add(strings.TrimSuffix(name, "_total"), familyCounter, detail, promSample{suffix: "_total", labels: labels, value: float64(snapshot.Count())})
+			add(name+"_rate", familyGauge, detail+" events per second", rates(labels, snapshot.Rate1(), snapshot.Rate5(), snapshot.Rate15(), snapshot.RateMean())...)
+		case metrics.Histogram:
+			snapshot := typed.Snapshot()
+			add(name, familySummary, detail, summary(labels, percentiles, snapshot.Percentiles(percentiles), float64(snapshot.Sum()), snapshot.Count(), 1)...)
+		case metrics.Timer:
+			snapshot := typed.Snapshot()
+			add(name+"_seconds", familySummary, detail+" in seconds", summary(labels, percentiles, snapshot.Percentiles(percentiles), float64(snapshot.Sum()), snapshot.Count(), 1e-9)...)
+			add(name+"_rate", familyGauge, detail+" events per second", rates(labels, snapshot.Rate1(), snapshot.Rate5(), snapshot.Rate15(), snapshot.RateMean())...)
+		}
+	})
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buffer := bytes.Buffer{}
+	for _, name := range names {
+		family := families[name]
+
+		sort.SliceStable(family.samples, func(i, j int) bool { return family.samples[i].group < family.samples[j].group })
+
+		// OpenMetrics counter families drop the _total suffix of their samples, Prometheus text families keep it
+		familyName := family.name
+		if family.kind == familyCounter && !openMetrics {
+			familyName += "_total"
+		}
+
+		fmt.Fprintf(&buffer, "# HELP %s go-metrics %s\n", familyName, escapeHelp(family.help))
+		fmt.Fprintf(&buffer, "# TYPE %s %s\n", familyName, family.kind)
+
+		for _, sample := range family.samples {
+			buffer.WriteString(family.name + sample.suffix)
+			writeLabels(&buffer, sample.labels)
+			buffer.WriteString(" " + formatFloat(sample.value) + "\n")
+		}
+	}
+
+	if openMetrics {
+		buffer.WriteString("# EOF\n")
+	}
+
+	return buffer.Bytes()
+}
+
+// SanitizeMetricName - a go-metrics name as a Prometheus metric name, invalid characters become underscores
+func SanitizeMetricName(name string) string {
+	builder := strings.Builder{}
+
+	for idx, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			builder.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if idx == 0 {
+				builder.WriteRune('_')
+			}
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune('_')
+		}
+	}
+
+	if builder.Len() == 0 {
+		return "_"
+	}
+
+	return builder.String()
+}
+
+func summary(labels []string, percentiles []float64, values []float64, sum float64, count int64, scale float64) []promSample {
+	samples := make([]promSample, 0, len(percentiles)+2)
+
+	for idx, percentile := range percentiles {
+		samples = append(samples, promSample{
+			labels: append(append([]string{}, labels...), "quantile", strconv.FormatFloat(percentile, 'g', -1, 64)),
+			value:  values[idx] * scale,
+		})
+	}
+
+	samples = append(samples,
+		promSample{suffix: "_sum", labels: labels, value: sum * scale},
+		promSample{suffix: "_count", labels: labels, value: float64(count)},
+	)
+
+	return samples
+}
+
+func rates(labels []string, rate1 float64, rate5 float64, rate15 float64, rateMean float64) []promSample {
+	samples := []promSample{}
+
+	for _, rate := range []struct {
+		window string
+		value  float64
+	}{{"1m", rate1}, {"5m", rate5}, {"15m", rate15}, {"mean", rateMean}} {
+		samples = append(samples, promSample{labels: append(append([]string{}, labels...), "window", rate.window), value: rate.value})
+	}
+
+	return samples
+}
+
+func writeLabels(buffer *bytes.Buffer, labels []string) {
+	if len(labels) == 0 {
+		return
+	}
+
+	buffer.WriteString("{")
+	for idx := 0; idx+1 < len(labels); idx += 2 {
+		if idx > 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(labels[idx] + `="` + escapeLabelValue(labels[idx+1]) + `"`)
+	}
+	buffer.WriteString("}")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(value string) string {
+	return helpEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
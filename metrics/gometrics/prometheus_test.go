@@ -0,0 +1,116 @@
+package gometrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/metrics/gometrics"
+)
+
+func Test_SanitizeMetricName(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		Name        string
+		Expected    string
+		Description string
+	}{
+		{Name: "http.service.request", Expected: "http_service_request", Description: "dots"},
+		{Name: "http.service.response.status.4xx", Expected: "http_service_response_status_4xx", Description: "digits"},
+		{Name: "5xx-errors/sec", Expected: "_5xx_errors_sec", Description: "leading digit and symbols"},
+		{Name: "ns:name", Expected: "ns:name", Description: "colons kept"},
+		{Name: "", Expected: "_", Description: "empty"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(tc.Expected, gometrics.SanitizeMetricName(tc.Name), tc.Description)
+	}
+}
+
+func Test_WritePrometheus(t *testing.T) {
+	assert := assert.New(t)
+
+	registry := metrics.NewRegistry()
+	gm := gometrics.NewGoMetrics(registry, "go-http-server")
+	gm.TrackedMetrics.HTTPService.Status4xx.Inc(3)
+	gm.TrackedMetrics.ServiceRequest.Update(2 * time.Second)
+	metrics.GetOrRegisterGauge("go-http-server.connections", registry).Update(7)
+	metrics.GetOrRegisterGaugeFloat64("other.ratio", registry).Update(0.5)
+	metrics.GetOrRegisterMeter("go-http-server.events", registry).Mark(4)
+	metrics.GetOrRegisterHistogram("go-http-server.sizes", registry, metrics.NewUniformSample(10)).Update(10)
+
+	text := string(gometrics.WritePrometheus(registry, gm.GetMetricsPrefix(), gometrics.DefaultPercentiles, false))
+
+	expected := []string{
+		"# TYPE http_service_response_status_4xx_total counter\n",
+		`http_service_response_status_4xx_total{prefix="go-http-server"} 3` + "\n",
+		"# TYPE connections gauge\n",
+		`connections{prefix="go-http-server"} 7` + "\n",
+		"# TYPE other_ratio gauge\nother_ratio 0.5\n",
+		"# TYPE events_total counter\n",
+		`events_total{prefix="go-http-server"} 4` + "\n",
+		`events_rate{prefix="go-http-server",window="1m"}`,
+		"# TYPE sizes summary\n",
+		`sizes{prefix="go-http-server",quantile="0.99"} 10` + "\n",
+		"# TYPE http_service_request_seconds summary\n",
+		`http_service_request_seconds{prefix="go-http-server",quantile="0.5"} 2` + "\n",
+		`http_service_request_seconds_sum{prefix="go-http-server"} 2` + "\n",
+		`http_service_request_seconds_count{prefix="go-http-server"} 1` + "\n",
+	}
+	for _, line := range expected {
+		assert.Contains(text, line)
+	}
+	assert.NotContains(text, "# EOF")
+	assert.NotContains(text, "go-http-server.", "prefix only as a label")
+
+	families := map[string]bool{}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			name := strings.Fields(line)[2]
+			assert.False(families[name], "one TYPE line per family: "+name)
+			families[name] = true
+		}
+	}
+
+	openMetrics := string(gometrics.WritePrometheus(registry, gm.GetMetricsPrefix(), gometrics.DefaultPercentiles, true))
+	assert.Contains(openMetrics, "# TYPE events counter\n")
+	assert.Contains(openMetrics, `events_total{prefix="go-http-server"} 4`)
+	assert.True(strings.HasSuffix(openMetrics, "# EOF\n"))
+}
+
+func Test_PrometheusHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	registry := metrics.NewRegistry()
+	gm := gometrics.NewGoMetrics(registry, "go-http-server")
+	gm.TrackedMetrics.ShadowErrors.Inc(1)
+
+	testCases := []struct {
+		Accept      string
+		ContentType string
+		Description string
+	}{
+		{Accept: "", ContentType: gometrics.ContentType_PrometheusText, Description: "prometheus text by default"},
+		{Accept: "text/plain;version=0.0.4;q=0.3,*/*;q=0.1", ContentType: gometrics.ContentType_PrometheusText, Description: "prometheus scraper"},
+		{Accept: "application/openmetrics-text;version=1.0.0,text/plain;version=0.0.4;q=0.5", ContentType: gometrics.ContentType_OpenMetrics, Description: "openmetrics scraper"},
+	}
+
+	for _, tc := range testCases {
+		request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if len(tc.Accept) > 0 {
+			request.Header.Set("Accept", tc.Accept)
+		}
+
+		recorder := httptest.NewRecorder()
+		gm.PrometheusHandler().ServeHTTP(recorder, request)
+		assert.Equal(http.StatusOK, recorder.Code, tc.Description)
+		assert.Equal(tc.ContentType, recorder.Header().Get("Content-Type"), tc.Description)
+		assert.Contains(recorder.Body.String(), `http_shadow_errors_total{prefix="go-http-server"} 1`, tc.Description)
+	}
+}
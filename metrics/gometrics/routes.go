@@ -0,0 +1,152 @@
+package gometrics
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// Route metrics are a timer per route pattern, method and status class in the registry, so the exp handler,
+// the APM gatherer and the exporters report them like any other timer, e.g. go-http-server.http.service.route.status.GET.4xx.
+// Once the max series exist new routes are counted as "other", unknown methods always are.
+
+const (
+	RouteOther = "other"
+	RouteRoot  = "root"
+
+	DefaultRouteMaxSeries = 100
+)
+
+var routeMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+type routeMetrics struct {
+	sync.RWMutex
+	maxSeries int
+	series    map[string]metrics.Timer
+	count     int             // series counted against the max, other series are not
+	collapsed metrics.Counter // requests counted as other because of the max series
+}
+
+// SetRouteMaxSeries - distinct route timers before new routes are counted as other, default when zero, negative disables route metrics
+func (gm *GoMetrics) SetRouteMaxSeries(maxSeries int) {
+	gm.routes.Lock()
+	defer gm.routes.Unlock()
+
+	gm.routes.maxSeries = maxSeries
+}
+
+// IncHTTPRoute - update the timer for the route pattern, method and status class of a service request
+func (gm *GoMetrics) IncHTTPRoute(route string, method string, httpStatusCode int, duration time.Duration) {
+	if !routeMethods[method] {
+		method = RouteOther
+	}
+	route = RouteName(route)
+	class := StatusClass(httpStatusCode)
+
+	gm.routes.RLock()
+	maxSeries := gm.routes.maxSeries
+	timer, found := gm.routes.series[routeKey(route, method, class)]
+	gm.routes.RUnlock()
+
+	if maxSeries < 0 {
+		return
+	}
+
+	if !found {
+		timer = gm.routeTimer(route, method, class)
+	}
+
+	timer.Update(duration)
+}
+
+// routeTimer - create the timer for a series, as other once the max series exist
+func (gm *GoMetrics) routeTimer(route string, method string, class string) metrics.Timer {
+	gm.routes.Lock()
+	defer gm.routes.Unlock()
+
+	if gm.routes.series == nil {
+		gm.routes.series = map[string]metrics.Timer{}
+		gm.routes.collapsed = gm.CreateCounter(gm.CreateMetricName("http.service.route.collapsed"))
+	}
+
+	key := routeKey(route, method, class)
+	if timer, found := gm.routes.series[key]; found {
+		return timer
+	}
+
+	maxSeries := gm.routes.maxSeries
+	if maxSeries == 0 {
+		maxSeries = DefaultRouteMaxSeries
+	}
+
+	if route != RouteOther && gm.routes.count >= maxSeries {
+		gm.routes.collapsed.Inc(1)
+		route = RouteOther
+		key = routeKey(route, method, class)
+		if timer, found := gm.routes.series[key]; found {
+			return timer
+		}
+	}
+
+	timer := gm.CreateTimer(gm.CreateMetricName(strings.Join([]string{"http.service.route", route, method, class}, ".")))
+	gm.routes.series[key] = timer
+	if route != RouteOther {
+		gm.routes.count++
+	}
+
+	return timer
+}
+
+func routeKey(route string, method string, class string) string {
+	return route + " " + method + " " + class
+}
+
+// RouteName - a route pattern as one metric name segment, e.g. /api/v1.2/ becomes api_v1_2 and / becomes root
+func RouteName(pattern string) string {
+	name := strings.Trim(pattern, "/")
+	if len(name) == 0 {
+		if len(pattern) == 0 {
+			return RouteOther
+		}
+		return RouteRoot
+	}
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// StatusClass - 1xx to 5xx, oor when out of range
+func StatusClass(httpStatusCode int) string {
+	switch {
+	case httpStatusCode >= 100 && httpStatusCode < 200:
+		return "1xx"
+	case httpStatusCode >= 200 && httpStatusCode < 300:
+		return "2xx"
+	case httpStatusCode >= 300 && httpStatusCode < 400:
+		return "3xx"
+	case httpStatusCode >= 400 && httpStatusCode < 500:
+		return "4xx"
+	case httpStatusCode >= 500 && httpStatusCode < 600:
+		return "5xx"
+	}
+
+	return "oor"
+}
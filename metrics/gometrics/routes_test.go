@@ -0,0 +1,86 @@
+package gometrics_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+	"go.elastic.co/apm/v2"
+
+	"github.com/mdonahue-godaddy/go-http-server/metrics/gometrics"
+)
+
+func Test_RouteName(t *testing.T) {
+	assert := assert.New(t)
+
+	testCases := []struct {
+		Pattern     string
+		Expected    string
+		Description string
+	}{
+		{Pattern: "", Expected: gometrics.RouteOther, Description: "no route"},
+		{Pattern: "/", Expected: gometrics.RouteRoot, Description: "root"},
+		{Pattern: "/status/", Expected: "status", Description: "subtree"},
+		{Pattern: "/api/v1.2/users", Expected: "api_v1_2_users", Description: "separators"},
+		{Pattern: "/cookies", Expected: "cookies", Description: "path"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(tc.Expected, gometrics.RouteName(tc.Pattern), tc.Description)
+	}
+
+	assert.Equal("4xx", gometrics.StatusClass(404))
+	assert.Equal("oor", gometrics.StatusClass(600))
+}
+
+func Test_IncHTTPRoute(t *testing.T) {
+	assert := assert.New(t)
+
+	registry := metrics.NewRegistry()
+	gm := gometrics.NewGoMetrics(registry, metricPrefix)
+	gm.SetRouteMaxSeries(2)
+
+	timer := func(name string) int64 {
+		if timer, ok := registry.Get(metricPrefix + ".http.service.route." + name).(metrics.Timer); ok {
+			return timer.Count()
+		}
+		return 0
+	}
+
+	gm.IncHTTPRoute("/status/", http.MethodGet, 404, time.Millisecond)
+	gm.IncHTTPRoute("/status/", http.MethodGet, 404, time.Millisecond)
+	gm.IncHTTPRoute("/status/", http.MethodPost, 503, time.Millisecond)
+	gm.IncHTTPRoute("/bytes/", http.MethodGet, 200, time.Millisecond)
+	gm.IncHTTPRoute("/status/", "BREW", 200, time.Millisecond)
+	gm.IncHTTPRoute("", http.MethodGet, 200, time.Millisecond)
+
+	assert.Equal(int64(2), timer("status.GET.4xx"), "same series")
+	assert.Equal(int64(1), timer("status.POST.5xx"), "method and class")
+	assert.Equal(int64(0), timer("bytes.GET.2xx"), "over max series")
+	assert.Equal(int64(2), timer("other.GET.2xx"), "collapsed and unknown route")
+	assert.Equal(int64(0), timer("status.BREW.2xx"), "unknown method")
+	assert.Equal(int64(0), timer("status.other.2xx"), "unknown method over max series")
+	assert.Equal(int64(1), timer("other.other.2xx"), "unknown method collapsed")
+	assert.Equal(int64(2), registry.Get(metricPrefix+".http.service.route.collapsed").(metrics.Counter).Count())
+
+	gm.EnableExpHandler()
+	recorder := httptest.NewRecorder()
+	gm.ExpHandler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/gometrics", nil))
+	values := map[string]interface{}{}
+	assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &values))
+	assert.Equal(float64(2), values[metricPrefix+".http.service.route.status.GET.4xx.count"], "exp handler")
+
+	met := apm.Metrics{}
+	assert.Nil(gometrics.WrapRegistry(registry).GatherMetrics(context.Background(), &met))
+
+	disabled := metrics.NewRegistry()
+	gm = gometrics.NewGoMetrics(disabled, metricPrefix)
+	gm.SetRouteMaxSeries(-1)
+	gm.IncHTTPRoute("/status/", http.MethodGet, 404, time.Millisecond)
+	assert.Nil(disabled.Get(metricPrefix+".http.service.route.status.GET.4xx"), "disabled")
+}
@@ -0,0 +1,392 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+const (
+	CheckTypeTCP  string = "tcp"
+	CheckTypeHTTP string = "http"
+	CheckTypeDisk string = "disk"
+
+	DefaultCheckTimeout       = 2 * time.Second
+	DefaultCheckInterval      = 10 * time.Second
+	DefaultHealthyThreshold   = 2
+	DefaultUnhealthyThreshold = 3
+)
+
+// CheckFunc checks a dependency, a nil error passes
+type CheckFunc func(ctx context.Context) error
+
+// Check is a named CheckFunc run every Interval with a Timeout
+type Check struct {
+	Name     string
+	Func     CheckFunc
+	Timeout  time.Duration
+	Interval time.Duration
+	Critical bool // failing critical checks fail readiness, others only warn
+}
+
+// Result is the damped state of a check, Healthy only changes after the registry thresholds are reached
+type Result struct {
+	Name        string
+	Critical    bool
+	Checked     bool // false until the first run
+	Healthy     bool
+	Output      string // error from the last failed run
+	Latency     time.Duration
+	LastChecked time.Time
+	LastSuccess time.Time
+	LastFailure time.Time
+}
+
+type checkState struct {
+	check     Check
+	result    Result
+	successes int // consecutive
+	failures  int // consecutive
+}
+
+// Registry runs checks in the background and caches their results
+type Registry struct {
+	healthyThreshold   int
+	unhealthyThreshold int
+
+	mu     sync.RWMutex
+	checks []*checkState
+	stop   chan struct{}
+	done   sync.WaitGroup
+}
+
+// NewRegistry - thresholds damp flapping checks, zero uses the defaults
+func NewRegistry(healthyThreshold int, unhealthyThreshold int) *Registry {
+	r := Registry{
+		healthyThreshold:   healthyThreshold,
+		unhealthyThreshold: unhealthyThreshold,
+	}
+
+	if r.healthyThreshold <= 0 {
+		r.healthyThreshold = DefaultHealthyThreshold
+	}
+	if r.unhealthyThreshold <= 0 {
+		r.unhealthyThreshold = DefaultUnhealthyThreshold
+	}
+
+	return &r
+}
+
+// NewRegistryFromConfig - registry with the configured checks, client is used by http checks
+func NewRegistryFromConfig(cfg config.Health, client *http.Client) (*Registry, error) {
+	r := NewRegistry(cfg.HealthyThreshold, cfg.UnhealthyThreshold)
+
+	for _, checkCfg := range cfg.Checks {
+		check, err := NewCheck(checkCfg, client)
+		if err != nil {
+			return nil, err
+		}
+		if err = r.Register(check); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// NewCheck - built in check from config
+func NewCheck(cfg config.HealthCheck, client *http.Client) (Check, error) {
+	check := Check{
+		Name:     cfg.Name,
+		Critical: cfg.Critical,
+		Timeout:  DefaultCheckTimeout,
+		Interval: DefaultCheckInterval,
+	}
+
+	var err error
+	if check.Timeout, err = parseDuration(cfg.Timeout, DefaultCheckTimeout); err != nil {
+		return check, fmt.Errorf("health check '%s' timeout: %w", cfg.Name, err)
+	}
+	if check.Interval, err = parseDuration(cfg.Interval, DefaultCheckInterval); err != nil {
+		return check, fmt.Errorf("health check '%s' interval: %w", cfg.Name, err)
+	}
+
+	if len(cfg.Target) == 0 {
+		return check, fmt.Errorf("health check '%s' has no target", cfg.Name)
+	}
+
+	switch strings.ToLower(cfg.Type) {
+	case CheckTypeTCP:
+		check.Func = TCPCheck(cfg.Target)
+	case CheckTypeHTTP:
+		check.Func = HTTPCheck(client, cfg.Target)
+	case CheckTypeDisk:
+		check.Func = DiskCheck(cfg.Target, cfg.MinFreeBytes)
+	default:
+		return check, fmt.Errorf("health check '%s' has unknown type '%s'", cfg.Name, cfg.Type)
+	}
+
+	return check, nil
+}
+
+func parseDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err == nil && duration <= 0 {
+		err = errors.New("must be positive")
+	}
+
+	return duration, err
+}
+
+// TCPCheck - passes when address accepts a connection
+func TCPCheck(address string) CheckFunc {
+	return func(ctx context.Context) error {
+		dialer := net.Dialer{}
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+}
+
+// HTTPCheck - passes when a GET of url answers 2xx or 3xx, a nil client uses a client without redirects
+func HTTPCheck(client *http.Client, url string) CheckFunc {
+	if client == nil {
+		client = &http.Client{
+			CheckRedirect: func(request *http.Request, via []*http.Request) error { return http.ErrUseLastResponse },
+		}
+	}
+
+	return func(ctx context.Context) error {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		response, err := client.Do(request)
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+
+		if response.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("status %d", response.StatusCode)
+		}
+
+		return nil
+	}
+}
+
+// DiskCheck - passes when the file system holding path has at least minFreeBytes available
+func DiskCheck(path string, minFreeBytes uint64) CheckFunc {
+	return func(ctx context.Context) error {
+		free, err := diskFree(path)
+		if err != nil {
+			return err
+		}
+
+		if free < minFreeBytes {
+			return fmt.Errorf("%d bytes free, below %d", free, minFreeBytes)
+		}
+
+		return nil
+	}
+}
+
+// Register - add a check, names are unique
+func (r *Registry) Register(check Check) error {
+	if len(check.Name) == 0 || check.Func == nil {
+		return errors.New("health check needs a name and a func")
+	}
+
+	if check.Timeout <= 0 {
+		check.Timeout = DefaultCheckTimeout
+	}
+	if check.Interval <= 0 {
+		check.Interval = DefaultCheckInterval
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, state := range r.checks {
+		if state.check.Name == check.Name {
+			return fmt.Errorf("health check '%s' already registered", check.Name)
+		}
+	}
+
+	r.checks = append(r.checks, &checkState{check: check, result: Result{Name: check.Name, Critical: check.Critical}})
+
+	return nil
+}
+
+// Start - run every check now and then every interval until Stop
+func (r *Registry) Start(ctx context.Context) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stop != nil {
+		return
+	}
+	r.stop = make(chan struct{})
+
+	for _, state := range r.checks {
+		r.done.Add(1)
+		go r.watch(ctx, state, r.stop)
+	}
+}
+
+// Stop - stop the background checks, results are kept
+func (r *Registry) Stop() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	stop := r.stop
+	r.stop = nil
+	r.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		r.done.Wait()
+	}
+}
+
+func (r *Registry) watch(ctx context.Context, state *checkState, stop chan struct{}) {
+	defer r.done.Done()
+
+	ticker := time.NewTicker(state.check.Interval)
+	defer ticker.Stop()
+
+	for {
+		r.run(ctx, state)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunChecks - run every check once and wait for the results
+func (r *Registry) RunChecks(ctx context.Context) {
+	if r == nil {
+		return
+	}
+
+	r.mu.RLock()
+	states := append([]*checkState{}, r.checks...)
+	r.mu.RUnlock()
+
+	wg := sync.WaitGroup{}
+	for _, state := range states {
+		wg.Add(1)
+		go func(state *checkState) {
+			defer wg.Done()
+			r.run(ctx, state)
+		}(state)
+	}
+	wg.Wait()
+}
+
+func (r *Registry) run(ctx context.Context, state *checkState) {
+	method := "health.Registry.run"
+
+	checkCtx, cancel := context.WithTimeout(ctx, state.check.Timeout)
+	defer cancel()
+
+	start := time.Now().UTC()
+	err := state.check.Func(checkCtx)
+	latency := time.Since(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &state.result
+	wasChecked, wasHealthy := result.Checked, result.Healthy
+
+	result.Latency = latency
+	result.LastChecked = start
+
+	if err == nil {
+		state.successes++
+		state.failures = 0
+		result.LastSuccess = start
+		result.Output = ""
+		if !result.Checked || state.successes >= r.healthyThreshold {
+			result.Healthy = true
+		}
+	} else {
+		state.failures++
+		state.successes = 0
+		result.LastFailure = start
+		result.Output = err.Error()
+		if !result.Checked || state.failures >= r.unhealthyThreshold {
+			result.Healthy = false
+		}
+	}
+	result.Checked = true
+
+	if !wasChecked || wasHealthy != result.Healthy {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, "health.check", state.check.Name, "health.healthy", result.Healthy, "health.output", result.Output)).Infof("%s check state changed", method)
+	}
+}
+
+// Results - cached results ordered by name
+func (r *Registry) Results() []Result {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	results := make([]Result, 0, len(r.checks))
+	for _, state := range r.checks {
+		results = append(results, state.result)
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	return results
+}
+
+// Ready - false with a reason while a critical check is pending or failing, a nil registry is ready
+func (r *Registry) Ready() (bool, string) {
+	reasons := []string{}
+
+	for _, result := range r.Results() {
+		switch {
+		case !result.Critical:
+		case !result.Checked:
+			reasons = append(reasons, fmt.Sprintf("%s pending", result.Name))
+		case !result.Healthy:
+			reasons = append(reasons, fmt.Sprintf("%s failing: %s", result.Name, result.Output))
+		}
+	}
+
+	return len(reasons) == 0, strings.Join(reasons, "; ")
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package health
+
+import (
+	"errors"
+	"runtime"
+)
+
+// diskFree - disk checks are not supported on this platform
+func diskFree(path string) (uint64, error) {
+	return 0, errors.New("disk checks are not supported on " + runtime.GOOS)
+}
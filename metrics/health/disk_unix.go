@@ -0,0 +1,15 @@
+//go:build linux || darwin
+
+package health
+
+import "syscall"
+
+// diskFree - bytes available to unprivileged users on the file system holding path
+func diskFree(path string) (uint64, error) {
+	stat := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -5,6 +5,7 @@ import (
 	"html/template"
 	"net/http"
 	"strings"
+	"sync"
 )
 
 const (
@@ -13,17 +14,38 @@ const (
 
 	DefaultResponseTemplate string = "<!DOCTYPE HTML><html lang='en-us'><head><title>** {{page_title}} **</title></head><body>{{page_body}}</body></html>"
 	Status_Good             string = "GOOD!"
+
+	ReadinessAuto = "auto" // readiness follows the health state
+	ReadinessUp   = "up"   // readiness passes unless draining
+	ReadinessDown = "down" // readiness fails
 )
 
+// Paths are the liveness, readiness and startup endpoint paths
+type Paths struct {
+	Liveness  string
+	Readiness string
+	Startup   string
+}
+
 type Status struct {
 	IsGood bool
 	Reason string
 }
 
+// HealthEndpoints is the health state shared by every health endpoint, on any port.
+// Liveness follows Status. Startup passes once SetStarted is called and every listener added with AddListener is bound.
+// Readiness fails while draining or forced down, passes when forced up, and otherwise follows Status and the critical Checks.
 type HealthEndpoints struct {
 	ServeMux *http.ServeMux
 	BasePath string
-	Status   Status
+	Status   Status    // guarded by mu, use SetStatus and GetStatus
+	Checks   *Registry // dependency checks for readiness, nil when there are none
+
+	mu        sync.RWMutex
+	started   bool
+	listeners int    // added with AddListener and not bound yet
+	draining  string // reason, empty when not draining
+	readiness string // ReadinessAuto, ReadinessUp or ReadinessDown
 }
 
 func NewHealthEndpoints(mux *http.ServeMux, basePath string) *HealthEndpoints {
@@ -40,60 +62,173 @@ func NewHealthEndpoints(mux *http.ServeMux, basePath string) *HealthEndpoints {
 }
 
 func (s *HealthEndpoints) SetStatus(isGood bool, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.Status.IsGood = isGood
 	s.Status.Reason = reason
 }
 
-// EnableEndpoints - add liveness and readiness handlers
+func (s *HealthEndpoints) GetStatus() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.Status
+}
+
+// SetStarted - pass the startup probe
+func (s *HealthEndpoints) SetStarted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.started = true
+}
+
+// AddListener - hold the startup probe until ListenerBound is called for another listener
+func (s *HealthEndpoints) AddListener() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.listeners++
+}
+
+// ListenerBound - a listener added with AddListener is bound, or will never be
+func (s *HealthEndpoints) ListenerBound() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listeners > 0 {
+		s.listeners--
+	}
+}
+
+// SetDraining - fail readiness with reason, whatever the override
+func (s *HealthEndpoints) SetDraining(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.draining = reason
+}
+
+// GetReadinessOverride - ReadinessAuto, ReadinessUp or ReadinessDown
+func (s *HealthEndpoints) GetReadinessOverride() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.readiness) == 0 {
+		return ReadinessAuto
+	}
+
+	return s.readiness
+}
+
+// SetReadinessOverride - force readiness up or down, ReadinessAuto clears the override
+func (s *HealthEndpoints) SetReadinessOverride(override string) error {
+	switch override {
+	case ReadinessAuto, ReadinessUp, ReadinessDown:
+	default:
+		return fmt.Errorf("invalid readiness override '%s', expected %s, %s or %s", override, ReadinessAuto, ReadinessUp, ReadinessDown)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.readiness = override
+	return nil
+}
+
+// Alive - liveness and the reason it fails
+func (s *HealthEndpoints) Alive() (bool, string) {
+	status := s.GetStatus()
+
+	return status.IsGood, status.Reason
+}
+
+// Started - startup probe and the reason it fails
+func (s *HealthEndpoints) Started() (bool, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.started {
+		return false, "Starting."
+	}
+
+	if s.listeners > 0 {
+		return false, fmt.Sprintf("Waiting for %d listener(s).", s.listeners)
+	}
+
+	return true, ""
+}
+
+// Ready - readiness and the reason it fails
+func (s *HealthEndpoints) Ready() (bool, string) {
+	s.mu.RLock()
+	status, draining, readiness := s.Status, s.draining, s.readiness
+	s.mu.RUnlock()
+
+	switch {
+	case len(draining) > 0:
+		return false, draining
+	case readiness == ReadinessDown:
+		return false, "Readiness forced down."
+	case readiness == ReadinessUp:
+		return true, ""
+	case !status.IsGood:
+		return false, status.Reason
+	}
+
+	return s.Checks.Ready()
+}
+
+// EnableEndpoints - add liveness, readiness and startup handlers under BasePath
 func (s *HealthEndpoints) EnableEndpoints() {
 	s.ServeMux.HandleFunc(fmt.Sprintf("%s/liveness", s.BasePath), s.LivenessHandler)
 	s.ServeMux.HandleFunc(fmt.Sprintf("%s/readiness", s.BasePath), s.ReadinessHandler)
+	s.ServeMux.HandleFunc(fmt.Sprintf("%s/startup", s.BasePath), s.StartupHandler)
 }
 
-// LivenessHandler - liveness handler, service is alive
+// LivenessHandler - liveness handler, 503 with the reason unless Status.IsGood, see WantsReport for health+json
 func (s *HealthEndpoints) LivenessHandler(responseWriter http.ResponseWriter, request *http.Request) {
-	htmlMessage := strings.Replace(DefaultResponseTemplate, "{{page_title}}", "Liveness Status", 1)
-
-	if s.Status.IsGood {
-		responseWriter.WriteHeader(http.StatusOK)
+	if alive, reason := s.Alive(); !alive {
+		s.writeStatus(responseWriter, request, http.StatusServiceUnavailable, "Liveness Status", reason)
+		return
+	}
 
-		htmlMessage = strings.Replace(htmlMessage, "{{page_body}}", Status_Good, 1)
-	} else {
-		responseWriter.WriteHeader(http.StatusOK)
+	s.writeStatus(responseWriter, request, http.StatusOK, "Liveness Status", Status_Good)
+}
 
-		htmlMessage = strings.Replace(htmlMessage, "{{page_body}}", Status_Good, 1)
+// StartupHandler - startup handler, 503 until Started
+func (s *HealthEndpoints) StartupHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	if started, reason := s.Started(); !started {
+		s.writeStatus(responseWriter, request, http.StatusServiceUnavailable, "Startup Status", reason)
+		return
 	}
 
-	if len(htmlMessage) > 0 {
-		responseWriter.Header().Set(HttpHeader_ContentType, ContentType_TextHtml)
-
-		msg := template.New("body")
-		var err error
-		msg, err = msg.Parse(htmlMessage)
-		if err != nil {
-			return
-		}
+	s.writeStatus(responseWriter, request, http.StatusOK, "Startup Status", Status_Good)
+}
 
-		_ = msg.Execute(responseWriter, nil)
+// ReadinessHandler - readiness handler, 503 with the reason unless Ready
+func (s *HealthEndpoints) ReadinessHandler(responseWriter http.ResponseWriter, request *http.Request) {
+	if ready, reason := s.Ready(); !ready {
+		s.writeStatus(responseWriter, request, http.StatusServiceUnavailable, "Readiness Status", reason)
+		return
 	}
+
+	s.writeStatus(responseWriter, request, http.StatusOK, "Readiness Status", Status_Good)
 }
 
-// ReadinessHandler - readiness handler, service is ready
-func (s *HealthEndpoints) ReadinessHandler(responseWriter http.ResponseWriter, request *http.Request) {
-	responseWriter.WriteHeader(http.StatusOK)
+// writeStatus - health+json report when asked for one, otherwise an html status page with the body escaped
+func (s *HealthEndpoints) writeStatus(responseWriter http.ResponseWriter, request *http.Request, httpStatusCode int, title string, body string) {
+	if WantsReport(request) {
+		_ = WriteReport(responseWriter, httpStatusCode, NewReport(httpStatusCode, body, s.Checks.Report()))
+		return
+	}
 
-	htmlMessage := strings.Replace(DefaultResponseTemplate, "{{page_title}}", "Readiness Status", 1)
-	htmlMessage = strings.Replace(htmlMessage, "{{page_body}}", Status_Good, 1)
+	htmlMessage := strings.Replace(DefaultResponseTemplate, "{{page_title}}", title, 1)
+	htmlMessage = strings.Replace(htmlMessage, "{{page_body}}", template.HTMLEscapeString(body), 1)
 
-	if len(htmlMessage) > 0 {
-		responseWriter.Header().Set(HttpHeader_ContentType, ContentType_TextHtml)
-		msg := template.New("body")
-		var err error
-		msg, err = msg.Parse(htmlMessage)
-		if err != nil {
-			return
-		}
+	responseWriter.Header().Set(HttpHeader_ContentType, ContentType_TextHtml)
+	responseWriter.WriteHeader(httpStatusCode)
 
-		_ = msg.Execute(responseWriter, nil)
-	}
+	_, _ = responseWriter.Write([]byte(htmlMessage))
 }
@@ -1 +1,310 @@
 package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/health"
+)
+
+func Test_RegistryFlapDamping(t *testing.T) {
+	assert := assert.New(t)
+
+	var failing bool
+	registry := health.NewRegistry(2, 3)
+	assert.Nil(registry.Register(health.Check{
+		Name:     "database",
+		Critical: true,
+		Func: func(ctx context.Context) error {
+			if failing {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+	}))
+	assert.Nil(registry.Register(health.Check{Name: "cache", Func: func(ctx context.Context) error { return errors.New("down") }}))
+	assert.NotNil(registry.Register(health.Check{Name: "cache", Func: func(ctx context.Context) error { return nil }}), "duplicate name")
+	assert.NotNil(registry.Register(health.Check{Name: "nothing"}), "no func")
+
+	ready, reason := registry.Ready()
+	assert.False(ready, "critical checks start pending")
+	assert.Equal("database pending", reason)
+
+	registry.RunChecks(context.Background())
+	ready, _ = registry.Ready()
+	assert.True(ready, "first result applies at once, non critical failures do not fail readiness")
+
+	testCases := []struct {
+		Failing     bool
+		Expected    bool
+		Description string
+	}{
+		{Failing: true, Expected: true, Description: "first failure is damped"},
+		{Failing: true, Expected: true, Description: "second failure is damped"},
+		{Failing: true, Expected: false, Description: "third failure fails readiness"},
+		{Failing: false, Expected: false, Description: "first pass is damped"},
+		{Failing: true, Expected: false, Description: "failure resets the passes"},
+		{Failing: false, Expected: false, Description: "first pass again"},
+		{Failing: false, Expected: true, Description: "second pass passes readiness"},
+	}
+
+	for _, tc := range testCases {
+		failing = tc.Failing
+		registry.RunChecks(context.Background())
+
+		ready, _ = registry.Ready()
+		assert.Equal(tc.Expected, ready, tc.Description)
+	}
+
+	results := registry.Results()
+	assert.Equal(2, len(results))
+	assert.Equal("cache", results[0].Name)
+	assert.Equal("down", results[0].Output)
+	assert.False(results[0].Healthy)
+	assert.Equal("database", results[1].Name)
+	assert.True(results[1].Critical)
+	assert.False(results[1].LastFailure.IsZero())
+	assert.False(results[1].LastSuccess.Before(results[1].LastFailure))
+}
+
+func Test_BuiltInChecks(t *testing.T) {
+	assert := assert.New(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	defer listener.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(err)
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/bad" {
+			responseWriter.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer upstream.Close()
+
+	testCases := []struct {
+		Check       config.HealthCheck
+		Passes      bool
+		Description string
+	}{
+		{Check: config.HealthCheck{Name: "tcp", Type: "tcp", Target: listener.Addr().String()}, Passes: true, Description: "tcp listening"},
+		{Check: config.HealthCheck{Name: "tcp", Type: "tcp", Target: closedAddress}, Passes: false, Description: "tcp closed"},
+		{Check: config.HealthCheck{Name: "http", Type: "http", Target: upstream.URL + "/good"}, Passes: true, Description: "http 200"},
+		{Check: config.HealthCheck{Name: "http", Type: "HTTP", Target: upstream.URL + "/bad"}, Passes: false, Description: "http 503"},
+		{Check: config.HealthCheck{Name: "disk", Type: "disk", Target: os.TempDir()}, Passes: true, Description: "disk with space"},
+		{Check: config.HealthCheck{Name: "disk", Type: "disk", Target: os.TempDir(), MinFreeBytes: math.MaxUint64}, Passes: false, Description: "disk below minimum"},
+	}
+
+	for _, tc := range testCases {
+		check, err := health.NewCheck(tc.Check, nil)
+		assert.Nil(err, tc.Description)
+		assert.Equal(health.DefaultCheckTimeout, check.Timeout, tc.Description)
+		assert.Equal(health.DefaultCheckInterval, check.Interval, tc.Description)
+
+		err = check.Func(context.Background())
+		assert.Equal(tc.Passes, err == nil, tc.Description)
+	}
+
+	invalid := []config.HealthCheck{
+		{Name: "unknown", Type: "ping", Target: "localhost"},
+		{Name: "no target", Type: "tcp"},
+		{Name: "timeout", Type: "tcp", Target: "localhost:1", Timeout: "soon"},
+		{Name: "interval", Type: "tcp", Target: "localhost:1", Interval: "-1s"},
+	}
+
+	for _, cfg := range invalid {
+		_, err := health.NewCheck(cfg, nil)
+		assert.NotNil(err, cfg.Name)
+	}
+}
+
+func Test_RegistryStart(t *testing.T) {
+	assert := assert.New(t)
+
+	runs := make(chan struct{}, 10)
+	registry := health.NewRegistry(1, 1)
+	assert.Nil(registry.Register(health.Check{
+		Name:     "ticker",
+		Critical: true,
+		Interval: 10 * time.Millisecond,
+		Func: func(ctx context.Context) error {
+			runs <- struct{}{}
+			return nil
+		},
+	}))
+
+	registry.Start(context.Background())
+	<-runs
+	<-runs
+	registry.Stop()
+	registry.Stop()
+
+	ready, _ := registry.Ready()
+	assert.True(ready)
+
+	var nilRegistry *health.Registry
+	ready, _ = nilRegistry.Ready()
+	assert.True(ready, "no checks")
+}
+
+func Test_HealthEndpoints(t *testing.T) {
+	assert := assert.New(t)
+
+	mux := http.NewServeMux()
+	endpoints := health.NewHealthEndpoints(mux, "/healthz")
+	endpoints.EnableEndpoints()
+
+	status := func(path string) int {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder.Code
+	}
+
+	assert.Equal(http.StatusServiceUnavailable, status("/healthz/liveness"), "status not set")
+	assert.Equal(http.StatusServiceUnavailable, status("/healthz/readiness"), "status not set")
+
+	endpoints.SetStatus(true, "")
+	assert.Equal(http.StatusOK, status("/healthz/liveness"))
+	assert.Equal(http.StatusOK, status("/healthz/readiness"))
+
+	endpoints.Checks = health.NewRegistry(1, 1)
+	assert.Nil(endpoints.Checks.Register(health.Check{Name: "dependency", Critical: true, Func: func(ctx context.Context) error { return errors.New("down") }}))
+	endpoints.Checks.RunChecks(context.Background())
+	assert.Equal(http.StatusOK, status("/healthz/liveness"), "dependencies do not fail liveness")
+	assert.Equal(http.StatusServiceUnavailable, status("/healthz/readiness"), "critical check failing")
+
+	endpoints.SetStatus(false, "wedged")
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz/liveness", nil))
+	assert.Equal(http.StatusServiceUnavailable, recorder.Code)
+	assert.Contains(recorder.Body.String(), "wedged")
+}
+
+func Test_HealthReport(t *testing.T) {
+	assert := assert.New(t)
+
+	mux := http.NewServeMux()
+	endpoints := health.NewHealthEndpoints(mux, "/healthz")
+	endpoints.EnableEndpoints()
+	endpoints.SetStatus(true, "")
+	endpoints.Checks = health.NewRegistry(1, 1)
+	assert.Nil(endpoints.Checks.Register(health.Check{Name: "database", Critical: true, Func: func(ctx context.Context) error { return nil }}))
+	assert.Nil(endpoints.Checks.Register(health.Check{Name: "cache", Func: func(ctx context.Context) error { return errors.New("cache down") }}))
+	endpoints.Checks.RunChecks(context.Background())
+
+	testCases := []struct {
+		Target         string
+		Accept         string
+		ExpectedStatus int
+		ExpectedReport string
+		Description    string
+	}{
+		{Target: "/healthz/readiness", Accept: "application/health+json", ExpectedStatus: http.StatusOK, ExpectedReport: health.StatusWarn, Description: "non critical failure warns"},
+		{Target: "/healthz/readiness?verbose", ExpectedStatus: http.StatusOK, ExpectedReport: health.StatusWarn, Description: "verbose query"},
+		{Target: "/healthz/liveness", Accept: "text/html, application/health+json;q=0.5", ExpectedStatus: http.StatusOK, ExpectedReport: "", Description: "html preferred"},
+	}
+
+	for _, tc := range testCases {
+		request := httptest.NewRequest(http.MethodGet, tc.Target, nil)
+		if len(tc.Accept) > 0 {
+			request.Header.Set("Accept", tc.Accept)
+		}
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, request)
+
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+		if len(tc.ExpectedReport) == 0 {
+			assert.Equal(health.ContentType_TextHtml, recorder.Header().Get("Content-Type"), tc.Description)
+			continue
+		}
+
+		assert.Equal(health.ContentType_HealthJSON, recorder.Header().Get("Content-Type"), tc.Description)
+
+		report := health.Report{}
+		assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &report), tc.Description)
+		assert.Equal(tc.ExpectedReport, report.Status, tc.Description)
+
+		database := report.Checks["database:responseTime"]
+		assert.Equal(1, len(database), tc.Description)
+		assert.Equal(health.StatusPass, database[0].Status, tc.Description)
+		assert.Equal("ms", database[0].ObservedUnit, tc.Description)
+		assert.NotEmpty(database[0].LastSuccess, tc.Description)
+		assert.Empty(database[0].LastFailure, tc.Description)
+
+		cache := report.Checks["cache:responseTime"]
+		assert.Equal(1, len(cache), tc.Description)
+		assert.Equal(health.StatusWarn, cache[0].Status, tc.Description)
+		assert.Equal("cache down", cache[0].Output, tc.Description)
+		assert.NotEmpty(cache[0].LastFailure, tc.Description)
+	}
+
+	endpoints.SetStatus(false, "wedged")
+	request := httptest.NewRequest(http.MethodGet, "/healthz/liveness?verbose=1", nil)
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, request)
+
+	report := health.Report{}
+	assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &report))
+	assert.Equal(http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(health.StatusFail, report.Status)
+	assert.Equal("wedged", report.Output)
+}
+
+func Test_HealthState(t *testing.T) {
+	assert := assert.New(t)
+
+	state := health.NewHealthEndpoints(nil, "")
+	state.SetStatus(true, "")
+
+	started, _ := state.Started()
+	assert.False(started)
+	state.AddListener()
+	state.SetStarted()
+	started, _ = state.Started()
+	assert.False(started, "listener not bound")
+	state.ListenerBound()
+	started, _ = state.Started()
+	assert.True(started)
+
+	state.Checks = health.NewRegistry(1, 1)
+	assert.Nil(state.Checks.Register(health.Check{Name: "dependency", Critical: true, Func: func(ctx context.Context) error { return errors.New("down") }}))
+	state.Checks.RunChecks(context.Background())
+
+	testCases := []struct {
+		Override    string
+		Draining    string
+		Expected    bool
+		Description string
+	}{
+		{Override: health.ReadinessAuto, Expected: false, Description: "critical check failing"},
+		{Override: health.ReadinessUp, Expected: true, Description: "forced up"},
+		{Override: health.ReadinessDown, Expected: false, Description: "forced down"},
+		{Override: health.ReadinessUp, Draining: "draining", Expected: false, Description: "draining wins over forced up"},
+	}
+
+	for _, tc := range testCases {
+		assert.Nil(state.SetReadinessOverride(tc.Override), tc.Description)
+		state.SetDraining(tc.Draining)
+
+		ready, _ := state.Ready()
+		assert.Equal(tc.Expected, ready, tc.Description)
+	}
+
+	assert.NotNil(state.SetReadinessOverride("sideways"))
+	assert.Equal(health.ReadinessUp, state.GetReadinessOverride())
+}
@@ -0,0 +1,155 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+// Health reports in the IETF "Health Check Response Format for HTTP APIs" draft (application/health+json)
+
+const (
+	ContentType_HealthJSON string = "application/health+json"
+	MediaType_HealthJSON   string = "application/health+json"
+	QueryParameter_Verbose string = "verbose"
+
+	StatusPass string = "pass"
+	StatusWarn string = "warn"
+	StatusFail string = "fail"
+)
+
+// Report is a health+json response
+type Report struct {
+	Status      string                   `json:"status"`
+	Version     string                   `json:"version,omitempty"`
+	ReleaseID   string                   `json:"releaseId,omitempty"`
+	ServiceID   string                   `json:"serviceId,omitempty"`
+	Description string                   `json:"description,omitempty"`
+	Output      string                   `json:"output,omitempty"`
+	Checks      map[string][]CheckReport `json:"checks,omitempty"`
+}
+
+// CheckReport is a check in a Report, keyed by "{name}:responseTime"
+type CheckReport struct {
+	ComponentID   string  `json:"componentId"`
+	ComponentType string  `json:"componentType,omitempty"`
+	ObservedValue float64 `json:"observedValue"`
+	ObservedUnit  string  `json:"observedUnit"`
+	Status        string  `json:"status"`
+	Time          string  `json:"time,omitempty"`
+	Output        string  `json:"output,omitempty"`
+	Critical      bool    `json:"critical"`
+	LastSuccess   string  `json:"lastSuccess,omitempty"`
+	LastFailure   string  `json:"lastFailure,omitempty"`
+}
+
+// WantsReport - the request asks for a health+json report with Accept or ?verbose
+func WantsReport(request *http.Request) bool {
+	if request == nil {
+		return false
+	}
+
+	if _, found := request.URL.Query()[QueryParameter_Verbose]; found {
+		return true
+	}
+
+	return shared.NegotiateContentType(request, shared.MediaType_TextHtml, MediaType_HealthJSON) == MediaType_HealthJSON
+}
+
+// status - pass when healthy, fail for failing or pending critical checks, warn for the others
+func (result Result) status() string {
+	switch {
+	case result.Checked && result.Healthy:
+		return StatusPass
+	case result.Critical:
+		return StatusFail
+	default:
+		return StatusWarn
+	}
+}
+
+func formatTime(value time.Time) string {
+	if value.IsZero() {
+		return ""
+	}
+
+	return value.UTC().Format(time.RFC3339Nano)
+}
+
+// Report - check results keyed by "{name}:responseTime", latency in milliseconds
+func (r *Registry) Report() map[string][]CheckReport {
+	results := r.Results()
+	if len(results) == 0 {
+		return nil
+	}
+
+	checks := make(map[string][]CheckReport, len(results))
+	for _, result := range results {
+		output := result.Output
+		if !result.Checked {
+			output = "pending"
+		}
+
+		checks[result.Name+":responseTime"] = []CheckReport{{
+			ComponentID:   result.Name,
+			ComponentType: "component",
+			ObservedValue: float64(result.Latency.Microseconds()) / 1000,
+			ObservedUnit:  "ms",
+			Status:        result.status(),
+			Time:          formatTime(result.LastChecked),
+			Output:        output,
+			Critical:      result.Critical,
+			LastSuccess:   formatTime(result.LastSuccess),
+			LastFailure:   formatTime(result.LastFailure),
+		}}
+	}
+
+	return checks
+}
+
+// NewReport - report for a health response, fail for error statuses, otherwise warn when a check is not passing
+func NewReport(httpStatusCode int, output string, checks map[string][]CheckReport) Report {
+	report := Report{
+		Status: StatusPass,
+		Checks: checks,
+	}
+
+	if httpStatusCode >= http.StatusBadRequest {
+		report.Status = StatusFail
+		report.Output = output
+		return report
+	}
+
+	for _, items := range checks {
+		for _, item := range items {
+			if item.Status != StatusPass {
+				report.Status = StatusWarn
+			}
+		}
+	}
+
+	return report
+}
+
+// Summary - only the overall status, for reports served where anyone can ask for them
+func (report Report) Summary() Report {
+	return Report{Status: report.Status}
+}
+
+// WriteReport - write report as application/health+json
+func WriteReport(responseWriter http.ResponseWriter, httpStatusCode int, report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+
+	responseWriter.Header().Set(HttpHeader_ContentType, ContentType_HealthJSON)
+	responseWriter.Header().Set("Cache-Control", "no-store")
+	responseWriter.WriteHeader(httpStatusCode)
+
+	_, err = responseWriter.Write(body)
+	return err
+}
@@ -0,0 +1,280 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/pprof"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+const (
+	TriggerGoroutines string = "goroutines"
+	TriggerHeartbeat  string = "heartbeat"
+	TriggerGCPause    string = "gcpause"
+	TriggerHeap       string = "heap"
+
+	DefaultWatchdogInterval  = 5 * time.Second
+	DefaultHeartbeatInterval = time.Second
+)
+
+// watchdogProfiles - profiles captured when a trigger fires
+var watchdogProfiles = []string{"goroutine", "heap"}
+
+// Watchdog fails liveness while the process looks wedged: too many goroutines, a starved heartbeat goroutine,
+// long GC pauses or a heap over its soft limit. Liveness is restored once every trigger clears.
+type Watchdog struct {
+	interval           time.Duration
+	heartbeatInterval  time.Duration
+	maxHeartbeatDelay  time.Duration
+	maxGCPauseP99      time.Duration
+	maxGoroutines      int
+	heapSoftLimitBytes uint64
+	profileDirectory   string
+	state              *HealthEndpoints
+
+	lastBeat atomic.Int64 // unix nanoseconds of the last heartbeat, zero until started
+	maxLate  atomic.Int64 // latest heartbeat since the last evaluation, nanoseconds
+
+	mu      sync.Mutex
+	firing  map[string]string // trigger to reason
+	failing bool              // the watchdog failed liveness
+	stop    chan struct{}
+	done    sync.WaitGroup
+}
+
+// NewWatchdog - watchdog failing liveness on state, nil when no trigger is configured
+func NewWatchdog(cfg config.Watchdog, state *HealthEndpoints) (*Watchdog, error) {
+	if cfg.MaxGoroutines <= 0 && len(cfg.MaxHeartbeatDelay) == 0 && len(cfg.MaxGCPauseP99) == 0 && cfg.HeapSoftLimitBytes == 0 {
+		return nil, nil
+	}
+
+	if state == nil {
+		return nil, fmt.Errorf("watchdog needs a health state")
+	}
+
+	w := Watchdog{
+		maxGoroutines:      cfg.MaxGoroutines,
+		heapSoftLimitBytes: cfg.HeapSoftLimitBytes,
+		profileDirectory:   cfg.ProfileDirectory,
+		state:              state,
+		firing:             map[string]string{},
+	}
+
+	var err error
+	if w.interval, err = parseDuration(cfg.Interval, DefaultWatchdogInterval); err != nil {
+		return nil, fmt.Errorf("watchdog interval: %w", err)
+	}
+	if w.heartbeatInterval, err = parseDuration(cfg.HeartbeatInterval, DefaultHeartbeatInterval); err != nil {
+		return nil, fmt.Errorf("watchdog heartbeat interval: %w", err)
+	}
+	if w.maxHeartbeatDelay, err = parseDuration(cfg.MaxHeartbeatDelay, 0); err != nil {
+		return nil, fmt.Errorf("watchdog max heartbeat delay: %w", err)
+	}
+	if w.maxGCPauseP99, err = parseDuration(cfg.MaxGCPauseP99, 0); err != nil {
+		return nil, fmt.Errorf("watchdog max gc pause p99: %w", err)
+	}
+
+	return &w, nil
+}
+
+// Start - start the heartbeat and evaluate the triggers every interval until Stop
+func (w *Watchdog) Start(ctx context.Context) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.stop != nil {
+		return
+	}
+	w.stop = make(chan struct{})
+
+	w.lastBeat.Store(time.Now().UnixNano())
+	w.maxLate.Store(0)
+
+	w.done.Add(2)
+	go w.heartbeat(w.stop)
+	go w.watch(ctx, w.stop)
+}
+
+// Stop - stop the watchdog, liveness keeps its last state
+func (w *Watchdog) Stop() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	stop := w.stop
+	w.stop = nil
+	w.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		w.done.Wait()
+	}
+}
+
+// heartbeat - tick every heartbeatInterval, recording how late each tick was received
+func (w *Watchdog) heartbeat(stop chan struct{}) {
+	defer w.done.Done()
+
+	ticker := time.NewTicker(w.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now().UnixNano()
+		late := now - w.lastBeat.Swap(now) - int64(w.heartbeatInterval)
+
+		for {
+			previous := w.maxLate.Load()
+			if late <= previous || w.maxLate.CompareAndSwap(previous, late) {
+				break
+			}
+		}
+	}
+}
+
+func (w *Watchdog) watch(ctx context.Context, stop chan struct{}) {
+	defer w.done.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		w.Check(ctx)
+	}
+}
+
+// Check - evaluate the triggers now and update liveness, returns the reasons for the triggers firing
+func (w *Watchdog) Check(ctx context.Context) []string {
+	if w == nil {
+		return nil
+	}
+
+	method := "health.Watchdog.Check"
+
+	firing := w.evaluate()
+
+	w.mu.Lock()
+	fired := []string{}
+	for trigger, reason := range firing {
+		if _, found := w.firing[trigger]; !found {
+			fired = append(fired, trigger)
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, "health.watchdog.trigger", trigger, "health.watchdog.reason", reason)).Errorf("%s watchdog trigger fired", method)
+		}
+	}
+	for trigger := range w.firing {
+		if _, found := firing[trigger]; !found {
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, "health.watchdog.trigger", trigger)).Infof("%s watchdog trigger cleared", method)
+		}
+	}
+	w.firing = firing
+
+	reasons := make([]string, 0, len(firing))
+	for _, reason := range firing {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	if len(reasons) > 0 {
+		w.failing = true
+		w.state.SetStatus(false, "Watchdog: "+strings.Join(reasons, "; "))
+	} else if w.failing {
+		w.failing = false
+		w.state.SetStatus(true, "")
+	}
+	w.mu.Unlock()
+
+	sort.Strings(fired)
+	for _, trigger := range fired {
+		w.capture(ctx, trigger)
+	}
+
+	return reasons
+}
+
+// evaluate - reasons keyed by the triggers that are firing
+func (w *Watchdog) evaluate() map[string]string {
+	firing := map[string]string{}
+
+	if w.maxGoroutines > 0 {
+		if count := runtime.NumGoroutine(); count > w.maxGoroutines {
+			firing[TriggerGoroutines] = fmt.Sprintf("%d goroutines, over %d", count, w.maxGoroutines)
+		}
+	}
+
+	if w.maxHeartbeatDelay > 0 {
+		late := time.Duration(w.maxLate.Swap(0))
+		if lastBeat := w.lastBeat.Load(); lastBeat > 0 {
+			// a heartbeat goroutine that never runs records nothing
+			if stalled := time.Since(time.Unix(0, lastBeat)) - w.heartbeatInterval; stalled > late {
+				late = stalled
+			}
+		}
+		if late > w.maxHeartbeatDelay {
+			firing[TriggerHeartbeat] = fmt.Sprintf("heartbeat %s late, over %s", late, w.maxHeartbeatDelay)
+		}
+	}
+
+	if w.maxGCPauseP99 > 0 {
+		stats := debug.GCStats{PauseQuantiles: make([]time.Duration, 101)}
+		debug.ReadGCStats(&stats)
+		if p99 := stats.PauseQuantiles[99]; stats.NumGC > 0 && p99 > w.maxGCPauseP99 {
+			firing[TriggerGCPause] = fmt.Sprintf("gc pause p99 %s, over %s", p99, w.maxGCPauseP99)
+		}
+	}
+
+	if w.heapSoftLimitBytes > 0 {
+		memStats := runtime.MemStats{}
+		runtime.ReadMemStats(&memStats)
+		if memStats.HeapAlloc > w.heapSoftLimitBytes {
+			firing[TriggerHeap] = fmt.Sprintf("heap %d bytes, over %d", memStats.HeapAlloc, w.heapSoftLimitBytes)
+		}
+	}
+
+	return firing
+}
+
+// capture - write the goroutine and heap profiles for trigger to the profile directory, when there is one
+func (w *Watchdog) capture(ctx context.Context, trigger string) {
+	if len(w.profileDirectory) == 0 {
+		return
+	}
+
+	method := "health.Watchdog.capture"
+
+	for _, name := range watchdogProfiles {
+		fileName, err := pprof.CaptureProfile(w.profileDirectory, "watchdog-"+trigger, name)
+		if err != nil {
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error(), "health.watchdog.trigger", trigger)).Errorf("%s error capturing %s profile", method, name)
+			continue
+		}
+
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, "health.watchdog.trigger", trigger, "health.watchdog.profile", fileName)).Infof("%s captured %s profile", method, name)
+	}
+}
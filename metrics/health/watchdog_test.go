@@ -0,0 +1,148 @@
+package health_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/health"
+)
+
+func Test_NewWatchdog(t *testing.T) {
+	assert := assert.New(t)
+
+	state := health.NewHealthEndpoints(nil, "")
+
+	testCases := []struct {
+		Config      config.Watchdog
+		ExpectNil   bool
+		ExpectError bool
+		Description string
+	}{
+		{Config: config.Watchdog{}, ExpectNil: true, Description: "no triggers"},
+		{Config: config.Watchdog{ProfileDirectory: "/tmp"}, ExpectNil: true, Description: "profile directory alone is not a trigger"},
+		{Config: config.Watchdog{MaxGoroutines: 10000}, Description: "goroutines"},
+		{Config: config.Watchdog{MaxHeartbeatDelay: "1s", HeartbeatInterval: "100ms"}, Description: "heartbeat"},
+		{Config: config.Watchdog{MaxGCPauseP99: "soon"}, ExpectNil: true, ExpectError: true, Description: "invalid gc pause"},
+		{Config: config.Watchdog{MaxGoroutines: 10, Interval: "-1s"}, ExpectNil: true, ExpectError: true, Description: "negative interval"},
+	}
+
+	for _, tc := range testCases {
+		watchdog, err := health.NewWatchdog(tc.Config, state)
+		assert.Equal(tc.ExpectError, err != nil, tc.Description)
+		assert.Equal(tc.ExpectNil, watchdog == nil, tc.Description)
+	}
+
+	var watchdog *health.Watchdog
+	assert.Nil(watchdog.Check(context.Background()), "nil watchdog")
+	watchdog.Start(context.Background())
+	watchdog.Stop()
+}
+
+func Test_WatchdogTriggers(t *testing.T) {
+	assert := assert.New(t)
+
+	runtime.GC()
+
+	testCases := []struct {
+		Config      config.Watchdog
+		Trigger     string
+		Description string
+	}{
+		{Config: config.Watchdog{MaxGoroutines: 1}, Trigger: health.TriggerGoroutines, Description: "goroutine count"},
+		{Config: config.Watchdog{HeapSoftLimitBytes: 1}, Trigger: health.TriggerHeap, Description: "heap soft limit"},
+		{Config: config.Watchdog{MaxGCPauseP99: "1ns"}, Trigger: health.TriggerGCPause, Description: "gc pause p99"},
+	}
+
+	for _, tc := range testCases {
+		directory := t.TempDir()
+		tc.Config.ProfileDirectory = directory
+
+		state := health.NewHealthEndpoints(nil, "")
+		state.SetStatus(true, "")
+
+		watchdog, err := health.NewWatchdog(tc.Config, state)
+		assert.Nil(err, tc.Description)
+
+		reasons := watchdog.Check(context.Background())
+		assert.Len(reasons, 1, tc.Description)
+
+		alive, reason := state.Alive()
+		assert.False(alive, tc.Description)
+		assert.Contains(reason, "Watchdog: ", tc.Description)
+
+		for _, name := range []string{"goroutine", "heap"} {
+			files, _ := filepath.Glob(filepath.Join(directory, "watchdog-"+tc.Trigger+"-"+name+"-*.pprof"))
+			if assert.Len(files, 1, tc.Description+", "+name+" profile") {
+				info, err := os.Stat(files[0])
+				assert.Nil(err)
+				assert.NotZero(info.Size(), tc.Description+", "+name+" profile")
+			}
+		}
+
+		watchdog.Check(context.Background())
+		files, _ := os.ReadDir(directory)
+		assert.Len(files, 2, tc.Description+", profiles only captured when the trigger fires")
+	}
+}
+
+func Test_WatchdogRestoresLiveness(t *testing.T) {
+	assert := assert.New(t)
+
+	state := health.NewHealthEndpoints(nil, "")
+	state.SetStatus(true, "")
+
+	watchdog, err := health.NewWatchdog(config.Watchdog{MaxGoroutines: runtime.NumGoroutine() + 5}, state)
+	assert.Nil(err)
+
+	assert.Empty(watchdog.Check(context.Background()))
+	alive, _ := state.Alive()
+	assert.True(alive)
+
+	release := make(chan struct{})
+	for i := 0; i < 20; i++ {
+		go func() { <-release }()
+	}
+
+	assert.NotEmpty(watchdog.Check(context.Background()))
+	alive, reason := state.Alive()
+	assert.False(alive)
+	assert.Contains(reason, "goroutines")
+
+	close(release)
+	assert.Eventually(func() bool {
+		return len(watchdog.Check(context.Background())) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	alive, _ = state.Alive()
+	assert.True(alive, "liveness restored when the trigger clears")
+
+	state.SetStatus(false, "failed elsewhere")
+	watchdog.Check(context.Background())
+	_, reason = state.Alive()
+	assert.Equal("failed elsewhere", reason, "watchdog only restores liveness it failed")
+}
+
+func Test_WatchdogHeartbeat(t *testing.T) {
+	assert := assert.New(t)
+
+	state := health.NewHealthEndpoints(nil, "")
+	state.SetStatus(true, "")
+
+	watchdog, err := health.NewWatchdog(config.Watchdog{HeartbeatInterval: "1ms", MaxHeartbeatDelay: "1ns", Interval: "5ms"}, state)
+	assert.Nil(err)
+
+	watchdog.Start(context.Background())
+	defer watchdog.Stop()
+
+	assert.Eventually(func() bool {
+		alive, reason := state.Alive()
+		return !alive && strings.Contains(reason, "heartbeat")
+	}, 5*time.Second, 5*time.Millisecond, "late heartbeat fails liveness")
+}
@@ -1,12 +1,32 @@
 package pprof
 
 import (
+	"context"
+	"crypto/subtle"
+	"expvar"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/http/auth"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
 )
 
-// EnablePProfEndpoints starts pprof endpoints on mux provided.
+// Profiles are the runtime profiles served under {base}/pprof/
+var Profiles = []string{"allocs", "block", "goroutine", "heap", "mutex", "threadcreate"}
+
+// EnablePProfEndpoints starts pprof endpoints, every runtime profile and expvar vars on mux provided.
+// The endpoints leak command lines and memory contents, wrap mux with Protect when it is reachable by others.
 func EnablePProfEndpoints(mux *http.ServeMux, base string) error {
 	mux.HandleFunc(fmt.Sprintf("%s/pprof/", base), pprof.Index)
 	mux.HandleFunc(fmt.Sprintf("%s/pprof/cmdline", base), pprof.Cmdline)
@@ -14,11 +34,179 @@ func EnablePProfEndpoints(mux *http.ServeMux, base string) error {
 	mux.HandleFunc(fmt.Sprintf("%s/pprof/symbol", base), pprof.Symbol)
 	mux.HandleFunc(fmt.Sprintf("%s/pprof/trace", base), pprof.Trace)
 
-	mux.Handle(fmt.Sprintf("%s/pprof/goroutine", base), pprof.Handler("goroutine"))
-	mux.Handle(fmt.Sprintf("%s/pprof/heap", base), pprof.Handler("heap"))
-	mux.Handle(fmt.Sprintf("%s/pprof/threadcreate", base), pprof.Handler("threadcreate"))
-	mux.Handle(fmt.Sprintf("%s/pprof/block", base), pprof.Handler("block"))
-	mux.Handle(fmt.Sprintf("%s/vars", base), http.DefaultServeMux)
+	for _, name := range Profiles {
+		mux.Handle(fmt.Sprintf("%s/pprof/%s", base, name), pprof.Handler(name))
+	}
+
+	mux.Handle(fmt.Sprintf("%s/vars", base), expvar.Handler())
 
 	return nil
 }
+
+// SetProfileRates - block and mutex profiling rates from cfg, zero turns them off
+func SetProfileRates(cfg config.PProf) {
+	runtime.SetBlockProfileRate(cfg.BlockProfileRate)
+	runtime.SetMutexProfileFraction(cfg.MutexProfileFraction)
+}
+
+// Protect - require the cfg token and allowlist, with neither configured only loopback clients are allowed
+func Protect(next http.Handler, cfg config.PProf) (http.Handler, error) {
+	method := "pprof.Protect"
+
+	token := cfg.Token
+	if len(cfg.TokenFile) > 0 {
+		content, err := os.ReadFile(cfg.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(content))
+	}
+
+	allowed, err := parseAllowedIPs(cfg.AllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	loopbackOnly := len(token) == 0 && len(allowed) == 0
+
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		ip := remoteIP(request)
+
+		var reason string
+		httpStatusCode := http.StatusForbidden
+
+		switch {
+		case loopbackOnly && (ip == nil || !ip.IsLoopback()):
+			reason = "client is not loopback"
+		case len(allowed) > 0 && !containsIP(allowed, ip):
+			reason = "client is not allowed"
+		case len(token) > 0:
+			bearer, err := auth.BearerToken(request)
+			if err != nil || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+				reason = "invalid token"
+				httpStatusCode = http.StatusUnauthorized
+				responseWriter.Header().Set("WWW-Authenticate", "Bearer")
+			}
+		}
+
+		if len(reason) > 0 {
+			ctx := shared.CreateRequestContext(request, method)
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeError, true, "http.request.path", request.URL.Path)).Warnf("%s access denied, %s", method, reason)
+
+			http.Error(responseWriter, http.StatusText(httpStatusCode), httpStatusCode)
+			return
+		}
+
+		next.ServeHTTP(responseWriter, request)
+	}), nil
+}
+
+// parseAllowedIPs - addresses and CIDRs as networks
+func parseAllowedIPs(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid allowed ip '%s'", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed ip '%s': %w", value, err)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+func remoteIP(request *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CaptureProfile writes the named runtime profile, the one served by its handler, to a timestamped file in directory
+func CaptureProfile(directory string, prefix string, name string) (string, error) {
+	profile := runtimepprof.Lookup(name)
+	if profile == nil {
+		return "", fmt.Errorf("unknown profile '%s'", name)
+	}
+
+	if err := os.MkdirAll(directory, 0750); err != nil {
+		return "", err
+	}
+
+	fileName := filepath.Join(directory, fmt.Sprintf("%s-%s-%s.pprof", prefix, name, time.Now().UTC().Format("20060102T150405.000Z")))
+
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	err = profile.WriteTo(file, 0)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return fileName, err
+}
+
+// CaptureCPUProfile writes a CPU profile of duration to a timestamped file in directory, it fails while another CPU profile runs
+func CaptureCPUProfile(ctx context.Context, directory string, prefix string, duration time.Duration) (string, error) {
+	if err := os.MkdirAll(directory, 0750); err != nil {
+		return "", err
+	}
+
+	fileName := filepath.Join(directory, fmt.Sprintf("%s-cpu-%s.pprof", prefix, time.Now().UTC().Format("20060102T150405.000Z")))
+
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	if err = runtimepprof.StartCPUProfile(file); err != nil {
+		file.Close()
+		os.Remove(fileName)
+		return "", err
+	}
+
+	timer := time.NewTimer(duration)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+	case <-timer.C:
+	}
+
+	runtimepprof.StopCPUProfile()
+
+	return fileName, file.Close()
+}
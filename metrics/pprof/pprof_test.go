@@ -1 +1,107 @@
 package pprof_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/pprof"
+)
+
+func Test_CaptureProfile(t *testing.T) {
+	assert := assert.New(t)
+
+	directory := filepath.Join(t.TempDir(), "profiles")
+
+	testCases := []struct {
+		Name        string
+		ExpectError bool
+		Description string
+	}{
+		{Name: "goroutine", Description: "goroutine profile"},
+		{Name: "heap", Description: "heap profile"},
+		{Name: "nothing", ExpectError: true, Description: "unknown profile"},
+	}
+
+	for _, tc := range testCases {
+		fileName, err := pprof.CaptureProfile(directory, "test", tc.Name)
+		assert.Equal(tc.ExpectError, err != nil, tc.Description)
+		if tc.ExpectError {
+			continue
+		}
+
+		assert.True(strings.HasPrefix(filepath.Base(fileName), "test-"+tc.Name+"-"), tc.Description)
+		info, err := os.Stat(fileName)
+		assert.Nil(err, tc.Description)
+		assert.NotZero(info.Size(), tc.Description)
+	}
+}
+
+func Test_EnablePProfEndpoints(t *testing.T) {
+	assert := assert.New(t)
+
+	mux := http.NewServeMux()
+	assert.Nil(pprof.EnablePProfEndpoints(mux, "/debugz"))
+
+	for _, path := range []string{"/debugz/pprof/", "/debugz/pprof/allocs", "/debugz/pprof/mutex", "/debugz/pprof/block", "/debugz/pprof/heap", "/debugz/vars"} {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(http.StatusOK, recorder.Code, path)
+	}
+}
+
+func Test_Protect(t *testing.T) {
+	assert := assert.New(t)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.Nil(os.WriteFile(tokenFile, []byte("file-token\n"), 0600))
+
+	ok := http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {})
+
+	testCases := []struct {
+		Config         config.PProf
+		RemoteAddr     string
+		Token          string
+		ExpectedStatus int
+		Description    string
+	}{
+		{Config: config.PProf{}, RemoteAddr: "127.0.0.1:1234", ExpectedStatus: http.StatusOK, Description: "loopback by default"},
+		{Config: config.PProf{}, RemoteAddr: "[::1]:1234", ExpectedStatus: http.StatusOK, Description: "ipv6 loopback by default"},
+		{Config: config.PProf{}, RemoteAddr: "10.0.0.1:1234", ExpectedStatus: http.StatusForbidden, Description: "remote denied by default"},
+		{Config: config.PProf{AllowedIPs: []string{"10.0.0.0/8"}}, RemoteAddr: "10.1.2.3:1234", ExpectedStatus: http.StatusOK, Description: "allowed cidr"},
+		{Config: config.PProf{AllowedIPs: []string{"10.0.0.5"}}, RemoteAddr: "10.0.0.6:1234", ExpectedStatus: http.StatusForbidden, Description: "address not allowed"},
+		{Config: config.PProf{AllowedIPs: []string{"10.0.0.5"}}, RemoteAddr: "127.0.0.1:1234", ExpectedStatus: http.StatusForbidden, Description: "allowlist replaces loopback"},
+		{Config: config.PProf{Token: "secret"}, RemoteAddr: "10.0.0.1:1234", Token: "secret", ExpectedStatus: http.StatusOK, Description: "token"},
+		{Config: config.PProf{Token: "secret"}, RemoteAddr: "127.0.0.1:1234", ExpectedStatus: http.StatusUnauthorized, Description: "missing token"},
+		{Config: config.PProf{Token: "secret", TokenFile: tokenFile}, RemoteAddr: "10.0.0.1:1234", Token: "file-token", ExpectedStatus: http.StatusOK, Description: "token file wins"},
+		{Config: config.PProf{Token: "secret", AllowedIPs: []string{"10.0.0.0/8"}}, RemoteAddr: "192.168.0.1:1234", Token: "secret", ExpectedStatus: http.StatusForbidden, Description: "token and allowlist both required"},
+	}
+
+	for _, tc := range testCases {
+		handler, err := pprof.Protect(ok, tc.Config)
+		if !assert.Nil(err, tc.Description) {
+			continue
+		}
+
+		request := httptest.NewRequest(http.MethodGet, "/debugz/pprof/", nil)
+		request.RemoteAddr = tc.RemoteAddr
+		if len(tc.Token) > 0 {
+			request.Header.Set("Authorization", "Bearer "+tc.Token)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+	}
+
+	_, err := pprof.Protect(ok, config.PProf{AllowedIPs: []string{"10.0.0.0/33"}})
+	assert.NotNil(err, "invalid cidr")
+	_, err = pprof.Protect(ok, config.PProf{TokenFile: filepath.Join(t.TempDir(), "missing")})
+	assert.NotNil(err, "missing token file")
+}
@@ -0,0 +1,448 @@
+package profiler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/process"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/pprof"
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+const (
+	ProfileCPU       string = "cpu"
+	ProfileHeap      string = "heap"
+	ProfileGoroutine string = "goroutine"
+	ProfileMutex     string = "mutex"
+
+	TriggerCPU     string = "cpu"
+	TriggerRSS     string = "rss"
+	TriggerLatency string = "latency"
+
+	FileExtension string = ".pprof"
+
+	DefaultCPUDuration     = 10 * time.Second
+	DefaultMaxFiles        = 100
+	DefaultMaxAge          = 24 * time.Hour
+	DefaultTriggerInterval = 10 * time.Second
+	DefaultCooldown        = 5 * time.Minute
+)
+
+// DefaultProfiles are captured when config.Profiler.Profiles is empty
+var DefaultProfiles = []string{ProfileCPU, ProfileHeap, ProfileGoroutine, ProfileMutex}
+
+// File is a saved profile
+type File struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Profiler captures profiles to a directory every interval and when a trigger fires, keeping them within the retention limits
+type Profiler struct {
+	directory       string
+	interval        time.Duration
+	profiles        []string
+	cpuDuration     time.Duration
+	maxFiles        int
+	maxBytes        int64
+	maxAge          time.Duration
+	triggerInterval time.Duration
+	cooldown        time.Duration
+	cpuPercent      float64
+	rssBytes        uint64
+	latencyP99      time.Duration
+	latency         metrics.Timer // service request timer for the latency trigger
+
+	capturing     sync.Mutex // one capture at a time
+	mu            sync.Mutex
+	lastTriggered time.Time
+	stop          chan struct{}
+	done          sync.WaitGroup
+}
+
+// NewProfiler - profiler from config, nil without a directory, latency is the timer for the latency trigger
+func NewProfiler(cfg config.Profiler, latency metrics.Timer) (*Profiler, error) {
+	if len(cfg.Directory) == 0 {
+		return nil, nil
+	}
+
+	p := Profiler{
+		directory:  cfg.Directory,
+		profiles:   cfg.Profiles,
+		maxFiles:   cfg.MaxFiles,
+		maxBytes:   cfg.MaxBytes,
+		cpuPercent: cfg.CPUPercent,
+		rssBytes:   cfg.RSSBytes,
+		latency:    latency,
+	}
+
+	if len(p.profiles) == 0 {
+		p.profiles = DefaultProfiles
+	}
+	for _, name := range p.profiles {
+		if name != ProfileCPU && !isProfile(name) {
+			return nil, fmt.Errorf("profiler has unknown profile '%s'", name)
+		}
+	}
+
+	if p.maxFiles <= 0 {
+		p.maxFiles = DefaultMaxFiles
+	}
+
+	var err error
+	if p.interval, err = parseDuration(cfg.Interval, 0); err != nil {
+		return nil, fmt.Errorf("profiler interval: %w", err)
+	}
+	if p.cpuDuration, err = parseDuration(cfg.CPUDuration, DefaultCPUDuration); err != nil {
+		return nil, fmt.Errorf("profiler cpu duration: %w", err)
+	}
+	if p.maxAge, err = parseDuration(cfg.MaxAge, DefaultMaxAge); err != nil {
+		return nil, fmt.Errorf("profiler max age: %w", err)
+	}
+	if p.triggerInterval, err = parseDuration(cfg.TriggerInterval, DefaultTriggerInterval); err != nil {
+		return nil, fmt.Errorf("profiler trigger interval: %w", err)
+	}
+	if p.cooldown, err = parseDuration(cfg.Cooldown, DefaultCooldown); err != nil {
+		return nil, fmt.Errorf("profiler cooldown: %w", err)
+	}
+	if p.latencyP99, err = parseDuration(cfg.LatencyP99, 0); err != nil {
+		return nil, fmt.Errorf("profiler latency p99: %w", err)
+	}
+
+	if err = os.MkdirAll(p.directory, 0750); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func parseDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err == nil && duration <= 0 {
+		err = errors.New("must be positive")
+	}
+
+	return duration, err
+}
+
+func isProfile(name string) bool {
+	for _, profile := range pprof.Profiles {
+		if profile == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Start - capture every interval and evaluate the triggers until Stop
+func (p *Profiler) Start(ctx context.Context) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stop != nil {
+		return
+	}
+	p.stop = make(chan struct{})
+
+	if p.interval > 0 {
+		p.done.Add(1)
+		go p.every(ctx, p.stop, p.interval, func(ctx context.Context) {
+			_, _ = p.Capture(ctx, "periodic")
+		})
+	}
+
+	if p.cpuPercent > 0 || p.rssBytes > 0 || (p.latencyP99 > 0 && p.latency != nil) {
+		p.done.Add(1)
+		go p.every(ctx, p.stop, p.triggerInterval, p.checkTriggers)
+	}
+}
+
+// Stop - stop capturing, a running cpu profile is cut short
+func (p *Profiler) Stop() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	stop := p.stop
+	p.stop = nil
+	p.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		p.done.Wait()
+	}
+}
+
+func (p *Profiler) every(ctx context.Context, stop chan struct{}, interval time.Duration, action func(ctx context.Context)) {
+	defer p.done.Done()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		action(ctx)
+	}
+}
+
+// Triggers - the triggers over their thresholds now
+func (p *Profiler) Triggers() []string {
+	if p == nil {
+		return nil
+	}
+
+	triggers := []string{}
+
+	if p.cpuPercent > 0 {
+		if percents, err := cpu.Percent(0, false); err == nil && len(percents) > 0 && percents[0] > p.cpuPercent {
+			triggers = append(triggers, TriggerCPU)
+		}
+	}
+
+	if p.rssBytes > 0 {
+		if proc, err := process.NewProcess(int32(os.Getpid())); err == nil {
+			if memory, err := proc.MemoryInfo(); err == nil && memory.RSS > p.rssBytes {
+				triggers = append(triggers, TriggerRSS)
+			}
+		}
+	}
+
+	if p.latencyP99 > 0 && p.latency != nil {
+		if p99 := time.Duration(p.latency.Percentile(0.99)); p99 > p.latencyP99 {
+			triggers = append(triggers, TriggerLatency)
+		}
+	}
+
+	return triggers
+}
+
+func (p *Profiler) checkTriggers(ctx context.Context) {
+	method := "profiler.Profiler.checkTriggers"
+
+	triggers := p.Triggers()
+	if len(triggers) == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	if time.Since(p.lastTriggered) < p.cooldown {
+		p.mu.Unlock()
+		return
+	}
+	p.lastTriggered = time.Now()
+	p.mu.Unlock()
+
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, "profiler.triggers", strings.Join(triggers, ","))).Infof("%s profile capture triggered", method)
+
+	_, _ = p.Capture(ctx, "trigger-"+strings.Join(triggers, "-"))
+}
+
+// Capture - capture the configured profiles with prefix and apply retention, skipped while another capture runs
+func (p *Profiler) Capture(ctx context.Context, prefix string) ([]string, error) {
+	if p == nil {
+		return nil, errors.New("profiler disabled")
+	}
+
+	method := "profiler.Profiler.Capture"
+
+	if !p.capturing.TryLock() {
+		return nil, errors.New("profile capture already running")
+	}
+	defer p.capturing.Unlock()
+
+	fileNames := []string{}
+	var errs []string
+
+	for _, name := range p.profiles {
+		var fileName string
+		var err error
+
+		if name == ProfileCPU {
+			fileName, err = pprof.CaptureCPUProfile(ctx, p.directory, prefix, p.cpuDuration)
+		} else {
+			fileName, err = pprof.CaptureProfile(p.directory, prefix, name)
+		}
+
+		if err != nil {
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error capturing %s profile", method, name)
+			errs = append(errs, fmt.Sprintf("%s: %s", name, err.Error()))
+			continue
+		}
+
+		fileNames = append(fileNames, fileName)
+	}
+
+	if err := p.Prune(); err != nil {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error removing old profiles", method)
+	}
+
+	if len(errs) > 0 {
+		return fileNames, errors.New(strings.Join(errs, "; "))
+	}
+
+	return fileNames, nil
+}
+
+// Files - saved profiles, newest first
+func (p *Profiler) Files() ([]File, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(p.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), FileExtension) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		files = append(files, File{Name: entry.Name(), Size: info.Size(), Modified: info.ModTime().UTC()})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Modified.Equal(files[j].Modified) {
+			return files[i].Name > files[j].Name
+		}
+		return files[i].Modified.After(files[j].Modified)
+	})
+
+	return files, nil
+}
+
+// Prune - remove profiles older than the max age, then the oldest beyond the max files and max bytes
+func (p *Profiler) Prune() error {
+	files, err := p.Files()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	kept := 0
+	var errs []string
+
+	for _, file := range files {
+		total += file.Size
+
+		if time.Since(file.Modified) <= p.maxAge && kept < p.maxFiles && (p.maxBytes <= 0 || total <= p.maxBytes) {
+			kept++
+			continue
+		}
+
+		total -= file.Size
+		if err := os.Remove(filepath.Join(p.directory, file.Name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Handler - GET base lists the saved profiles as JSON, GET base{name} downloads one
+func (p *Profiler) Handler(base string) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		method := "profiler.Profiler.Handler"
+
+		if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			responseWriter.Header().Set("Allow", "GET, HEAD")
+			http.Error(responseWriter, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if p == nil {
+			http.NotFound(responseWriter, request)
+			return
+		}
+
+		name := strings.TrimPrefix(request.URL.Path, base)
+		if len(name) == 0 {
+			files, err := p.Files()
+			if err != nil {
+				ctx := shared.CreateRequestContext(request, method)
+				log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error listing profiles", method)
+				http.Error(responseWriter, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			responseWriter.Header().Set("Content-Type", shared.ContentType_ApplicationJSON)
+			responseWriter.Header().Set("Cache-Control", "no-store")
+			_ = json.NewEncoder(responseWriter).Encode(files)
+			return
+		}
+
+		// names only, never paths
+		if name != filepath.Base(name) || !strings.HasSuffix(name, FileExtension) || strings.HasPrefix(name, ".") {
+			http.NotFound(responseWriter, request)
+			return
+		}
+
+		file, err := os.Open(filepath.Join(p.directory, name))
+		if err != nil {
+			http.NotFound(responseWriter, request)
+			return
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			http.NotFound(responseWriter, request)
+			return
+		}
+
+		responseWriter.Header().Set("Content-Type", "application/octet-stream")
+		responseWriter.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		http.ServeContent(responseWriter, request, name, info.ModTime(), file)
+	})
+}
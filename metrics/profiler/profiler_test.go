@@ -0,0 +1,204 @@
+package profiler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/profiler"
+)
+
+func Test_NewProfiler(t *testing.T) {
+	assert := assert.New(t)
+
+	directory := t.TempDir()
+
+	testCases := []struct {
+		Config      config.Profiler
+		ExpectNil   bool
+		ExpectError bool
+		Description string
+	}{
+		{Config: config.Profiler{}, ExpectNil: true, Description: "disabled without a directory"},
+		{Config: config.Profiler{Directory: directory}, Description: "defaults"},
+		{Config: config.Profiler{Directory: directory, Profiles: []string{"allocs", "cpu"}}, Description: "profiles"},
+		{Config: config.Profiler{Directory: directory, Profiles: []string{"memory"}}, ExpectNil: true, ExpectError: true, Description: "unknown profile"},
+		{Config: config.Profiler{Directory: directory, Interval: "often"}, ExpectNil: true, ExpectError: true, Description: "invalid interval"},
+		{Config: config.Profiler{Directory: directory, LatencyP99: "-1s"}, ExpectNil: true, ExpectError: true, Description: "negative latency"},
+	}
+
+	for _, tc := range testCases {
+		p, err := profiler.NewProfiler(tc.Config, nil)
+		assert.Equal(tc.ExpectError, err != nil, tc.Description)
+		assert.Equal(tc.ExpectNil, p == nil, tc.Description)
+	}
+
+	var p *profiler.Profiler
+	p.Start(context.Background())
+	p.Stop()
+	_, err := p.Capture(context.Background(), "nil")
+	assert.NotNil(err, "nil profiler")
+}
+
+func Test_CaptureAndRetention(t *testing.T) {
+	assert := assert.New(t)
+
+	directory := t.TempDir()
+	p, err := profiler.NewProfiler(config.Profiler{Directory: directory, Profiles: []string{"cpu", "heap", "goroutine", "mutex"}, CPUDuration: "50ms", MaxFiles: 6}, nil)
+	assert.Nil(err)
+
+	fileNames, err := p.Capture(context.Background(), "manual")
+	assert.Nil(err)
+	assert.Len(fileNames, 4)
+	for _, fileName := range fileNames {
+		info, err := os.Stat(fileName)
+		assert.Nil(err)
+		assert.NotZero(info.Size(), fileName)
+		assert.True(strings.HasPrefix(filepath.Base(fileName), "manual-"), fileName)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	fileNames, err = p.Capture(context.Background(), "again")
+	assert.Nil(err)
+	assert.Len(fileNames, 4)
+
+	files, err := p.Files()
+	assert.Nil(err)
+	assert.Len(files, 6, "max files kept")
+	for _, fileName := range fileNames {
+		_, err := os.Stat(fileName)
+		assert.Nil(err, "newest profiles kept")
+	}
+
+	old := filepath.Join(directory, "old-heap.pprof")
+	assert.Nil(os.WriteFile(old, []byte("old"), 0600))
+	assert.Nil(os.Chtimes(old, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour)))
+	other := filepath.Join(directory, "notes.txt")
+	assert.Nil(os.WriteFile(other, []byte("keep"), 0600))
+
+	assert.Nil(p.Prune())
+	_, err = os.Stat(old)
+	assert.True(os.IsNotExist(err), "profiles past max age removed")
+	_, err = os.Stat(other)
+	assert.Nil(err, "other files are left alone")
+
+	bySize, err := profiler.NewProfiler(config.Profiler{Directory: directory, MaxBytes: 1}, nil)
+	assert.Nil(err)
+	assert.Nil(bySize.Prune())
+	files, _ = bySize.Files()
+	assert.Empty(files, "max bytes")
+}
+
+func Test_Triggers(t *testing.T) {
+	assert := assert.New(t)
+
+	timer := metrics.NewTimer()
+	timer.Update(time.Second)
+
+	testCases := []struct {
+		Config      config.Profiler
+		Timer       metrics.Timer
+		Expected    []string
+		Description string
+	}{
+		{Config: config.Profiler{}, Timer: timer, Expected: []string{}, Description: "no triggers"},
+		{Config: config.Profiler{RSSBytes: 1}, Expected: []string{profiler.TriggerRSS}, Description: "rss"},
+		{Config: config.Profiler{RSSBytes: 1 << 50}, Expected: []string{}, Description: "rss under threshold"},
+		{Config: config.Profiler{LatencyP99: "10ms"}, Timer: timer, Expected: []string{profiler.TriggerLatency}, Description: "latency"},
+		{Config: config.Profiler{LatencyP99: "10ms"}, Expected: []string{}, Description: "latency without a timer"},
+		{Config: config.Profiler{LatencyP99: "1h"}, Timer: timer, Expected: []string{}, Description: "latency under threshold"},
+	}
+
+	for _, tc := range testCases {
+		tc.Config.Directory = t.TempDir()
+
+		p, err := profiler.NewProfiler(tc.Config, tc.Timer)
+		assert.Nil(err, tc.Description)
+		assert.Equal(tc.Expected, p.Triggers(), tc.Description)
+	}
+}
+
+func Test_StartCaptures(t *testing.T) {
+	assert := assert.New(t)
+
+	timer := metrics.NewTimer()
+	timer.Update(time.Second)
+
+	directory := t.TempDir()
+	p, err := profiler.NewProfiler(config.Profiler{Directory: directory, Profiles: []string{"heap"}, Interval: "20ms", TriggerInterval: "10ms", LatencyP99: "10ms"}, timer)
+	assert.Nil(err)
+
+	p.Start(context.Background())
+	defer p.Stop()
+
+	assert.Eventually(func() bool {
+		periodic, _ := filepath.Glob(filepath.Join(directory, "periodic-heap-*.pprof"))
+		triggered, _ := filepath.Glob(filepath.Join(directory, "trigger-latency-heap-*.pprof"))
+		return len(periodic) > 0 && len(triggered) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	time.Sleep(50 * time.Millisecond)
+	triggered, _ := filepath.Glob(filepath.Join(directory, "trigger-latency-heap-*.pprof"))
+	assert.Len(triggered, 1, "triggered captures wait for the cooldown")
+}
+
+func Test_Handler(t *testing.T) {
+	assert := assert.New(t)
+
+	directory := t.TempDir()
+	p, err := profiler.NewProfiler(config.Profiler{Directory: directory, Profiles: []string{"goroutine"}}, nil)
+	assert.Nil(err)
+
+	fileNames, err := p.Capture(context.Background(), "manual")
+	assert.Nil(err)
+	name := filepath.Base(fileNames[0])
+	assert.Nil(os.WriteFile(filepath.Join(t.TempDir(), "secret.pprof"), []byte("secret"), 0600))
+
+	handler := p.Handler("/debugz/profiles/")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debugz/profiles/", nil))
+	assert.Equal(http.StatusOK, recorder.Code)
+	files := []profiler.File{}
+	assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &files))
+	if assert.Len(files, 1) {
+		assert.Equal(name, files[0].Name)
+	}
+
+	testCases := []struct {
+		Method         string
+		Path           string
+		ExpectedStatus int
+		Description    string
+	}{
+		{Method: http.MethodGet, Path: "/debugz/profiles/" + name, ExpectedStatus: http.StatusOK, Description: "download"},
+		{Method: http.MethodGet, Path: "/debugz/profiles/missing.pprof", ExpectedStatus: http.StatusNotFound, Description: "missing"},
+		{Method: http.MethodGet, Path: "/debugz/profiles/../secret.pprof", ExpectedStatus: http.StatusNotFound, Description: "path traversal"},
+		{Method: http.MethodGet, Path: "/debugz/profiles/notes.txt", ExpectedStatus: http.StatusNotFound, Description: "not a profile"},
+		{Method: http.MethodDelete, Path: "/debugz/profiles/" + name, ExpectedStatus: http.StatusMethodNotAllowed, Description: "read only"},
+	}
+
+	for _, tc := range testCases {
+		request := httptest.NewRequest(tc.Method, "/", nil)
+		request.URL.Path = tc.Path
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+	}
+
+	var disabled *profiler.Profiler
+	recorder = httptest.NewRecorder()
+	disabled.Handler("/debugz/profiles/").ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debugz/profiles/", nil))
+	assert.Equal(http.StatusNotFound, recorder.Code, "disabled")
+}
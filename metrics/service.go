@@ -3,13 +3,15 @@ package metrics
 import (
 	"context"
 	"fmt"
-	"html/template"
+	"net"
 	"net/http"
-	"net/http/pprof"
-	"strings"
+	"net/url"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/health"
+	"github.com/mdonahue-godaddy/go-http-server/metrics/pprof"
 	"github.com/mdonahue-godaddy/go-http-server/shared"
 )
 
@@ -22,15 +24,24 @@ const (
 
 var (
 	DefaultDialAddress = ":8082"
+	DefaultHealthPaths = health.Paths{Liveness: "/healthz/liveness", Readiness: "/healthz/readiness", Startup: "/healthz/startup"}
 )
 
-// StartServer is the entry point into initializing a pprof server instance for the Features API
-func StartServer(ctx context.Context, dialAddress string, enableHealthCheck bool, enablePProf bool) {
+// Endpoint is an additional handler mounted on the metrics server
+type Endpoint struct {
+	Pattern string
+	Handler http.Handler
+}
+
+// StartServer is the entry point into initializing a pprof server instance for the Features API,
+// pprofCfg protects the /debugz tree, healthState and healthPaths are shared with the service port health endpoints
+// and middleware, when not nil, wraps every metrics server request. The listener is reported to healthState once bound.
+func StartServer(ctx context.Context, dialAddress string, enableHealthCheck bool, enablePProf bool, pprofCfg config.PProf, healthState *health.HealthEndpoints, healthPaths health.Paths, middleware func(http.Handler) http.Handler, endpoints ...Endpoint) {
 	method := "metrics.StartServer"
 
 	var err error
 
-	if enableHealthCheck || enablePProf {
+	if enableHealthCheck || enablePProf || len(endpoints) > 0 {
 		if dialAddress == "" {
 			dialAddress = DefaultDialAddress
 		}
@@ -40,100 +51,105 @@ func StartServer(ctx context.Context, dialAddress string, enableHealthCheck bool
 		mux := http.NewServeMux()
 
 		if enableHealthCheck {
-			err = StartHealthCheck(ctx, mux, "/healthz")
+			err = StartHealthCheck(ctx, mux, healthPaths, healthState)
 			if err != nil {
 				log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error calling StartHealthCheck()", method)
 			}
 		}
 
 		if enablePProf {
-			err = StartPProfAPI(ctx, mux, "/debugz")
+			err = StartPProfAPI(ctx, mux, "/debugz", pprofCfg)
 			if err != nil {
 				log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error calling StartPProfAPI()", method)
 			}
 		}
 
-		if err := http.ListenAndServe(dialAddress, mux); err != nil {
-			log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error calling http.ListenAndServe() for http server on Dial Address: %s", method, dialAddress)
+		for _, endpoint := range endpoints {
+			// configured health paths may take an endpoint pattern, registering it twice panics
+			if _, pattern := mux.Handler(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: endpoint.Pattern}}); pattern == endpoint.Pattern {
+				log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false)).Errorf("%s endpoint: %s is already registered, skipped", method, endpoint.Pattern)
+				continue
+			}
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Infof("%s adding endpoint: %s", method, endpoint.Pattern)
+			mux.Handle(endpoint.Pattern, endpoint.Handler)
 		}
-	}
-}
 
-// StartHealthCheck starts pprof endpoint
-func StartHealthCheck(ctx context.Context, mux *http.ServeMux, base string) error {
-	method := "service.StartHealthCheck"
-	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Infof("%s starting endpoint", method)
-
-	mux.HandleFunc(fmt.Sprintf("%s/liveness", base), livenessRequestProcessor)
-	mux.HandleFunc(fmt.Sprintf("%s/readiness", base), readinessRequestProcessor)
-
-	return nil
-}
-
-// livenessRequestProcessor - liveness processor - is the service alive
-func livenessRequestProcessor(responseWriter http.ResponseWriter, request *http.Request) {
-	method := "service.livenessRequestProcessor"
-	ctx := shared.CreateRequestContext(request, method)
-	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s starting endpoint", method)
-
-	responseWriter.WriteHeader(http.StatusOK)
-
-	htmlMessage := strings.Replace(DefaultResponseTemplate, "{{page_title}}", "Liveness Status", 1)
-	htmlMessage = strings.Replace(htmlMessage, "{{page_body}}", Status_Good, 1)
+		handler := http.Handler(mux)
+		if middleware != nil {
+			handler = middleware(mux)
+		}
 
-	if len(htmlMessage) > 0 {
-		responseWriter.Header().Set(HttpHeader_ContentType, ContentType_TextHtml)
-		msg := template.New("body")
-		var err error
-		msg, err = msg.Parse(htmlMessage)
+		listener, err := net.Listen("tcp", dialAddress)
 		if err != nil {
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error calling net.Listen() for http server on Dial Address: %s", method, dialAddress)
 			return
 		}
 
-		_ = msg.Execute(responseWriter, nil)
+		if healthState != nil {
+			healthState.ListenerBound()
+		}
+
+		if err := http.Serve(listener, handler); err != nil {
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeError, false, shared.KeyErrorMessage, err.Error())).Errorf("%s error calling http.Serve() for http server on Dial Address: %s", method, dialAddress)
+		}
+		return
 	}
-}
 
-// readinessRequestProcessor - readiness processor
-func readinessRequestProcessor(responseWriter http.ResponseWriter, request *http.Request) {
-	method := "service.readinessRequestProcessor"
-	ctx := shared.CreateRequestContext(request, method)
-	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Debugf("%s starting endpoint", method)
+	// nothing to serve, nothing to wait for
+	if healthState != nil {
+		healthState.ListenerBound()
+	}
+}
 
-	responseWriter.WriteHeader(http.StatusOK)
+// StartHealthCheck adds liveness, readiness and startup endpoints for state at paths, DefaultHealthPaths for the empty ones, a nil state always passes
+func StartHealthCheck(ctx context.Context, mux *http.ServeMux, paths health.Paths, state *health.HealthEndpoints) error {
+	method := "service.StartHealthCheck"
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Infof("%s starting endpoint", method)
 
-	htmlMessage := strings.Replace(DefaultResponseTemplate, "{{page_title}}", "Readiness Status", 1)
-	htmlMessage = strings.Replace(htmlMessage, "{{page_body}}", Status_Good, 1)
+	if len(paths.Liveness) == 0 {
+		paths.Liveness = DefaultHealthPaths.Liveness
+	}
+	if len(paths.Readiness) == 0 {
+		paths.Readiness = DefaultHealthPaths.Readiness
+	}
+	if len(paths.Startup) == 0 {
+		paths.Startup = DefaultHealthPaths.Startup
+	}
 
-	if len(htmlMessage) > 0 {
-		responseWriter.Header().Set(HttpHeader_ContentType, ContentType_TextHtml)
-		msg := template.New("body")
-		var err error
-		msg, err = msg.Parse(htmlMessage)
-		if err != nil {
-			return
-		}
+	if paths.Liveness == paths.Readiness || paths.Liveness == paths.Startup || paths.Readiness == paths.Startup {
+		return fmt.Errorf("health paths must be distinct: %s, %s, %s", paths.Liveness, paths.Readiness, paths.Startup)
+	}
 
-		_ = msg.Execute(responseWriter, nil)
+	if state == nil {
+		state = health.NewHealthEndpoints(mux, "")
+		state.SetStatus(true, "")
+		state.SetStarted()
 	}
+
+	mux.HandleFunc(paths.Liveness, state.LivenessHandler)
+	mux.HandleFunc(paths.Readiness, state.ReadinessHandler)
+	mux.HandleFunc(paths.Startup, state.StartupHandler)
+
+	return nil
 }
 
-// StartPProfAPI starts pprof endpoint
-func StartPProfAPI(ctx context.Context, mux *http.ServeMux, base string) error {
+// StartPProfAPI starts pprof endpoints under base, protected by cfg (see pprof.Protect), nothing is mounted on error
+func StartPProfAPI(ctx context.Context, mux *http.ServeMux, base string, cfg config.PProf) error {
 	method := "service.StartPProfAPI"
 	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Infof("%s starting endpoint", method)
 
-	mux.HandleFunc(fmt.Sprintf("%s/pprof/", base), pprof.Index)
-	mux.HandleFunc(fmt.Sprintf("%s/pprof/cmdline", base), pprof.Cmdline)
-	mux.HandleFunc(fmt.Sprintf("%s/pprof/profile", base), pprof.Profile)
-	mux.HandleFunc(fmt.Sprintf("%s/pprof/symbol", base), pprof.Symbol)
-	mux.HandleFunc(fmt.Sprintf("%s/pprof/trace", base), pprof.Trace)
-
-	mux.Handle(fmt.Sprintf("%s/pprof/goroutine", base), pprof.Handler("goroutine"))
-	mux.Handle(fmt.Sprintf("%s/pprof/heap", base), pprof.Handler("heap"))
-	mux.Handle(fmt.Sprintf("%s/pprof/threadcreate", base), pprof.Handler("threadcreate"))
-	mux.Handle(fmt.Sprintf("%s/pprof/block", base), pprof.Handler("block"))
-	mux.Handle(fmt.Sprintf("%s/vars", base), http.DefaultServeMux)
+	debugMux := http.NewServeMux()
+	if err := pprof.EnablePProfEndpoints(debugMux, base); err != nil {
+		return err
+	}
+
+	handler, err := pprof.Protect(debugMux, cfg)
+	if err != nil {
+		return err
+	}
+
+	pprof.SetProfileRates(cfg)
+	mux.Handle(base+"/", handler)
 
 	return nil
 }
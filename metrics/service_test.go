@@ -1 +1,45 @@
 package metrics_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/config"
+	"github.com/mdonahue-godaddy/go-http-server/metrics"
+)
+
+func Test_StartPProfAPI(t *testing.T) {
+	assert := assert.New(t)
+
+	mux := http.NewServeMux()
+	assert.Nil(metrics.StartPProfAPI(context.Background(), mux, "/debugz", config.PProf{Token: "secret"}))
+
+	testCases := []struct {
+		Path           string
+		Token          string
+		ExpectedStatus int
+		Description    string
+	}{
+		{Path: "/debugz/pprof/cmdline", ExpectedStatus: http.StatusUnauthorized, Description: "cmdline protected"},
+		{Path: "/debugz/vars", ExpectedStatus: http.StatusUnauthorized, Description: "vars protected"},
+		{Path: "/debugz/pprof/mutex", Token: "secret", ExpectedStatus: http.StatusOK, Description: "mutex profile"},
+		{Path: "/debugz/vars", Token: "secret", ExpectedStatus: http.StatusOK, Description: "vars"},
+	}
+
+	for _, tc := range testCases {
+		request := httptest.NewRequest(http.MethodGet, tc.Path, nil)
+		if len(tc.Token) > 0 {
+			request.Header.Set("Authorization", "Bearer "+tc.Token)
+		}
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, request)
+		assert.Equal(tc.ExpectedStatus, recorder.Code, tc.Description)
+	}
+
+	assert.NotNil(metrics.StartPProfAPI(context.Background(), http.NewServeMux(), "/debugz", config.PProf{AllowedIPs: []string{"nowhere"}}), "invalid allowlist")
+}
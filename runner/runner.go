@@ -9,17 +9,24 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/mdonahue-godaddy/go-http-server/config"
 	"github.com/mdonahue-godaddy/go-http-server/http/server"
+	"github.com/mdonahue-godaddy/go-http-server/metadata"
 	"github.com/mdonahue-godaddy/go-http-server/metrics"
 	"github.com/mdonahue-godaddy/go-http-server/shared"
 )
 
 const (
 	ServiceName string = "go-http-server" // do not change this value, it is used in multiple locations
+
+	DefaultConfigWatchInterval = 5 * time.Second
+
+	ExitCodeDrained = 0 // every in flight request finished
+	ExitCodeForced  = 1 // requests were cut off at the drain timeout
 )
 
 func GetAllEnvironmentVariables() map[string]string {
@@ -62,6 +69,70 @@ func SetupDefaultLogrusConfig() {
 	log.SetLevel(log.InfoLevel)
 }
 
+// ExitCode - process exit code for a shutdown, non zero when requests were cut off at the drain timeout
+func ExitCode(result server.DrainResult) int {
+	if result.Forced > 0 {
+		return ExitCodeForced
+	}
+
+	return ExitCodeDrained
+}
+
+// configWatchInterval - Service.ConfigWatchInterval with its default, zero disables watching
+func configWatchInterval(ctx context.Context, cfg *config.Settings) time.Duration {
+	if len(cfg.Service.ConfigWatchInterval) == 0 {
+		return DefaultConfigWatchInterval
+	}
+
+	interval, err := time.ParseDuration(cfg.Service.ConfigWatchInterval)
+	if err != nil || interval < 0 {
+		log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false, "value", cfg.Service.ConfigWatchInterval)).Warnf("runner.configWatchInterval invalid Service.ConfigWatchInterval, defaulting to: %s", DefaultConfigWatchInterval.String())
+		return DefaultConfigWatchInterval
+	}
+
+	return interval
+}
+
+// watchConfig - reload the server config on SIGHUP and, when interval is positive, when the file's size or modification time changes
+func watchConfig(ctx context.Context, svc *server.Server, fileName string, interval time.Duration) {
+	method := "runner.watchConfig"
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	stamp := func() string {
+		info, err := os.Stat(fileName)
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("%d/%d", info.Size(), info.ModTime().UnixNano())
+	}
+	last := stamp()
+
+	for {
+		select {
+		case <-hangups:
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Infof("%s SIGHUP received, reloading %s", method, fileName)
+		case <-ticks:
+			current := stamp()
+			if current == last || len(current) == 0 {
+				continue
+			}
+			log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Infof("%s %s changed, reloading", method, fileName)
+		}
+
+		last = stamp()
+		svc.ReloadFile(fileName)
+	}
+}
+
 // Run - do the work
 func Run() {
 	//nolint
@@ -93,17 +164,32 @@ func Run() {
 		log.SetLevel(lvl)
 	}
 
-	// start pprof & metrics services
-	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Infof("%s setup metrics pprof end point", method)
-	dialAddress := net.JoinHostPort(cfg.Metrics.HTTP.Server.IPv4Address, strconv.FormatUint(uint64(cfg.Metrics.HTTP.Server.Port), 10))
-	go metrics.StartServer(ctx, dialAddress, cfg.Metrics.HealthcheckEnabled, cfg.Metrics.PPRofEnabled)
-
 	// setup forwarding service
 	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Infof("%s setup forwarding end point", method)
 	server := server.NewServer(ServiceName, cfg, nil)
 	server.Init()
 
-	exitCode := 1
+	// metadata calls share the outbound client's breakers and retries
+	metadata.Client = server.GetOutboundClient().HTTPClient()
+
+	// start pprof & metrics services
+	log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Infof("%s setup metrics pprof end point", method)
+	dialAddress := net.JoinHostPort(cfg.Metrics.HTTP.Server.IPv4Address, strconv.FormatUint(uint64(cfg.Metrics.HTTP.Server.Port), 10))
+	endpoints := []metrics.Endpoint{
+		{Pattern: "/healthz/upstreams", Handler: server.UpstreamHealthHandler()},
+		{Pattern: "/admin/", Handler: server.AdminHandler()},
+		{Pattern: "/debugz/profiles/", Handler: server.ProfilesHandler()},
+	}
+	if cfg.Metrics.PrometheusEnabled {
+		endpoints = append(endpoints, metrics.Endpoint{Pattern: "/metrics", Handler: server.PrometheusHandler()})
+	}
+	// the startup probe waits for the metrics listener too
+	server.GetHealth().AddListener()
+	go metrics.StartServer(ctx, dialAddress, cfg.Metrics.HealthcheckEnabled, cfg.Metrics.PPRofEnabled, cfg.Metrics.PProf, server.GetHealth(), server.HealthPaths(), server.MetricsPortHandler, endpoints...)
+
+	go watchConfig(ctx, server, jsonFileName, configWatchInterval(ctx, cfg))
+
+	exitCode := ExitCodeForced
 	everlastingGobstopper := make(chan bool)
 	osSignals := make(chan os.Signal, 1)
 	signal.Notify(osSignals, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
@@ -111,7 +197,7 @@ func Run() {
 	go func() {
 		<-osSignals
 		log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Infof("%s signal received.  Shutting down service.", method)
-		server.Shutdown()
+		exitCode = ExitCode(server.Shutdown())
 		log.WithFields(shared.GetFields(ctx, shared.EventTypeInfo, false)).Infof("%s Deactivating the Everlasting Gobstopper.", method)
 		everlastingGobstopper <- true
 	}()
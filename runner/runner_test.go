@@ -8,6 +8,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/mdonahue-godaddy/go-http-server/http/server"
 	"github.com/mdonahue-godaddy/go-http-server/runner"
 )
 
@@ -73,3 +74,11 @@ func Test_SetupDefaultLogrusConfig(t *testing.T) {
 	assert.Equal(log.InfoLevel, level, "Log Level")
 	assert.Equal(log.StandardLogger().Out, os.Stdout, "Log Our is nor os.Stdout")
 }
+
+func Test_ExitCode(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(runner.ExitCodeDrained, runner.ExitCode(server.DrainResult{}), "nothing in flight")
+	assert.Equal(runner.ExitCodeDrained, runner.ExitCode(server.DrainResult{InFlight: 3, Drained: 3}), "drained")
+	assert.Equal(runner.ExitCodeForced, runner.ExitCode(server.DrainResult{InFlight: 3, Drained: 1, Forced: 2}), "cut off at the drain timeout")
+}
@@ -68,6 +68,12 @@ const (
 	KeySourceAddress string = "source.address"
 	// KeyServerAddress is
 	KeyServerAddress string = "server.address"
+	// KeyUpstreamPool is the upstream pool a request was forwarded to
+	KeyUpstreamPool string = "upstream.pool"
+	// KeyUpstreamTarget is the upstream target a request was forwarded to
+	KeyUpstreamTarget string = "upstream.target"
+	// KeyCORSOrigin is the Origin header of a cross origin request
+	KeyCORSOrigin string = "cors.origin"
 
 	// KeyRequestProto is ...
 	KeyRequestProto string = "request.proto"
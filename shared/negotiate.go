@@ -0,0 +1,126 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ContentType_ApplicationJSON string = "application/json"
+	ContentType_TextPlain       string = "text/plain; charset=utf-8"
+
+	MediaType_ApplicationJSON string = "application/json"
+	MediaType_TextHtml        string = "text/html"
+	MediaType_TextPlain       string = "text/plain"
+
+	HttpHeader_Accept = "Accept"
+)
+
+type acceptRange struct {
+	mediaType string
+	quality   float64
+	order     int
+}
+
+// parseAccept - Accept header ranges sorted by quality (highest first), more specific ranges first on ties
+func parseAccept(accept string) []acceptRange {
+	ranges := make([]acceptRange, 0)
+
+	for idx, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if value, found := params["q"]; found {
+			quality, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality, order: idx})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].quality != ranges[j].quality {
+			return ranges[i].quality > ranges[j].quality
+		}
+		return strings.Count(ranges[i].mediaType, "*") < strings.Count(ranges[j].mediaType, "*")
+	})
+
+	return ranges
+}
+
+func mediaTypeMatches(pattern string, mediaType string) bool {
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+	}
+
+	return false
+}
+
+// NegotiateContentType - best offered media type for the request Accept header.
+// The first offer is the default when Accept is missing or nothing acceptable is offered.
+func NegotiateContentType(request *http.Request, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	accept := ""
+	if request != nil {
+		accept = request.Header.Get(HttpHeader_Accept)
+	}
+
+	if len(strings.TrimSpace(accept)) == 0 {
+		return offers[0]
+	}
+
+	for _, acceptable := range parseAccept(accept) {
+		if acceptable.quality <= 0 {
+			continue
+		}
+
+		for _, offer := range offers {
+			if mediaTypeMatches(acceptable.mediaType, offer) {
+				return offer
+			}
+		}
+	}
+
+	return offers[0]
+}
+
+// WriteJSON - write value as JSON, the caller is responsible for the status code
+func WriteJSON(ctx context.Context, responseWriter http.ResponseWriter, value interface{}) error {
+	method := "shared.WriteJSON"
+	log.WithFields(GetFields(ctx, EventTypeInfo, false)).Debugf("%s entering", method)
+
+	if responseWriter == nil {
+		return errors.New("http.ResponseWriter is nil")
+	}
+
+	body, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	responseWriter.Header().Set(HttpHeader_ContentType, ContentType_ApplicationJSON)
+
+	_, err = responseWriter.Write(body)
+
+	return err
+}
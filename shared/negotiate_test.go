@@ -0,0 +1,91 @@
+package shared_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mdonahue-godaddy/go-http-server/shared"
+)
+
+func Test_NegotiateContentType(t *testing.T) {
+	assert := assert.New(t)
+
+	offers := []string{shared.MediaType_TextHtml, shared.MediaType_ApplicationJSON, shared.MediaType_TextPlain}
+
+	testCases := []struct {
+		Accept      string
+		Expected    string
+		Description string
+	}{
+		{
+			Accept:      "",
+			Expected:    shared.MediaType_TextHtml,
+			Description: "missing Accept uses first offer",
+		},
+		{
+			Accept:      "application/json",
+			Expected:    shared.MediaType_ApplicationJSON,
+			Description: "exact match",
+		},
+		{
+			Accept:      "text/html;q=0.5, application/json;q=0.9",
+			Expected:    shared.MediaType_ApplicationJSON,
+			Description: "highest quality wins",
+		},
+		{
+			Accept:      "text/*;q=0.8, text/plain",
+			Expected:    shared.MediaType_TextPlain,
+			Description: "specific range beats wildcard",
+		},
+		{
+			Accept:      "*/*",
+			Expected:    shared.MediaType_TextHtml,
+			Description: "any uses first offer",
+		},
+		{
+			Accept:      "image/png",
+			Expected:    shared.MediaType_TextHtml,
+			Description: "nothing acceptable uses first offer",
+		},
+		{
+			Accept:      "application/json;q=0, text/plain",
+			Expected:    shared.MediaType_TextPlain,
+			Description: "q=0 is not acceptable",
+		},
+	}
+
+	for _, tc := range testCases {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if len(tc.Accept) > 0 {
+			request.Header.Set(shared.HttpHeader_Accept, tc.Accept)
+		}
+
+		actual := shared.NegotiateContentType(request, offers...)
+
+		assert.Equal(tc.Expected, actual, tc.Description)
+	}
+
+	assert.Equal("", shared.NegotiateContentType(nil), "no offers")
+}
+
+func Test_WriteJSON(t *testing.T) {
+	assert := assert.New(t)
+	ctx := shared.CreateContext(context.Background(), "Test_WriteJSON_ActionName", "Test_WriteJSON_ActionType")
+
+	recorder := httptest.NewRecorder()
+	err := shared.WriteJSON(ctx, recorder, map[string]interface{}{"key": "value"})
+
+	assert.Nil(err)
+	assert.Equal(shared.ContentType_ApplicationJSON, recorder.Header().Get(shared.HttpHeader_ContentType))
+
+	actual := map[string]interface{}{}
+	assert.Nil(json.Unmarshal(recorder.Body.Bytes(), &actual))
+	assert.Equal("value", actual["key"])
+
+	assert.NotNil(shared.WriteJSON(ctx, nil, nil), "nil response writer")
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"html/template"
+	"io"
 	"net"
 	"net/http"
 	"os"
@@ -84,13 +84,8 @@ func WriteHTML(ctx context.Context, responseWriter http.ResponseWriter, html str
 	if len(html) > 0 {
 		responseWriter.Header().Set(HttpHeader_ContentType, ContentType_TextHtml)
 
-		msg := template.New("body")
-		msg, err = msg.Parse(html)
-		if err != nil {
-			return err
-		}
-
-		err = msg.Execute(responseWriter, nil)
+		// html is already rendered, parsing it again would execute any {{ }} in request values
+		_, err = io.WriteString(responseWriter, html)
 	}
 
 	return err